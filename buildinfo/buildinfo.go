@@ -0,0 +1,102 @@
+// Package buildinfo reports the version of a binary, as set at link time by janus ldflags, eg.
+//
+//	go build -ldflags "$(janus ldflags)"
+//
+// Variables not set at link time fall back to the module version and VCS information
+// embedded by go build, so binaries built without janus still report what they can.
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Set at link time with -X, eg. -X github.com/ETCDEVTeam/janus/buildinfo.Version=3.6.0
+var (
+	// Version is the version, eg. 3.6.0-beta.14
+	Version string
+	// Commit is the full commit sha1.
+	Commit string
+	// Date is the commit date, RFC3339 in UTC.
+	Date string
+	// Dirty is "true" if the work tree had uncommitted changes.
+	Dirty string
+)
+
+// Info is the version information of the binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	Dirty     bool   `json:"dirty"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get gets the version information of the binary.
+func Get() Info {
+	bi, _ := debug.ReadBuildInfo()
+	return get(bi)
+}
+
+// get merges the link time variables with 'bi', which may be nil; link time variables win.
+func get(bi *debug.BuildInfo) Info {
+	i := Info{GoVersion: runtime.Version()}
+	dirty := Dirty
+	if bi != nil {
+		if bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			i.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				i.Commit = s.Value
+			case "vcs.time":
+				i.Date = s.Value
+			case "vcs.modified":
+				if dirty == "" {
+					dirty = s.Value
+				}
+			}
+		}
+	}
+	if Version != "" {
+		i.Version = Version
+	}
+	if Commit != "" {
+		i.Commit = Commit
+	}
+	if Date != "" {
+		i.Date = Date
+	}
+	i.Dirty = dirty == "true"
+	return i
+}
+
+// String returns the information on one line, eg. 3.6.0-beta.14 (bbb06b1 2018-06-08T09:51:40Z, go1.10)
+func (i Info) String() string {
+	v := i.Version
+	if v == "" {
+		v = "unknown"
+	}
+	var details []string
+	if i.Commit != "" {
+		c := i.Commit
+		if len(c) > 7 {
+			c = c[:7]
+		}
+		if i.Dirty {
+			c += "-dirty"
+		}
+		details = append(details, c)
+	}
+	if i.Date != "" {
+		details = append(details, i.Date)
+	}
+	d := strings.Join(details, " ")
+	if d != "" {
+		d += ", "
+	}
+	return fmt.Sprintf("%s (%s%s)", v, d, i.GoVersion)
+}
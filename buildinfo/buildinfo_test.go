@@ -0,0 +1,53 @@
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	bi := &debug.BuildInfo{
+		Main: debug.Module{Path: "github.com/ethereumproject/go-ethereum", Version: "v3.5.0"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "bbb06b1aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"},
+			{Key: "vcs.time", Value: "2018-06-08T09:51:40Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+	defer func() { Version, Commit, Date, Dirty = "", "", "", "" }()
+
+	table := []struct {
+		bi                           *debug.BuildInfo
+		version, commit, date, dirty string
+		want                         Info
+	}{
+		{nil, "", "", "", "", Info{}},
+		{bi, "", "", "", "", Info{Version: "v3.5.0", Commit: "bbb06b1aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", Date: "2018-06-08T09:51:40Z", Dirty: true}},
+		{bi, "3.6.0-beta.14", "e35b683", "2018-06-09T00:00:00Z", "false", Info{Version: "3.6.0-beta.14", Commit: "e35b683", Date: "2018-06-09T00:00:00Z"}},
+		{&debug.BuildInfo{Main: debug.Module{Version: "(devel)"}}, "", "", "", "true", Info{Dirty: true}},
+	}
+	for i, tt := range table {
+		Version, Commit, Date, Dirty = tt.version, tt.commit, tt.date, tt.dirty
+		tt.want.GoVersion = runtime.Version()
+		if got := get(tt.bi); got != tt.want {
+			t.Errorf("%d: got: %+v, want: %+v", i, got, tt.want)
+		}
+	}
+}
+
+func TestInfo_String(t *testing.T) {
+	table := []struct {
+		i    Info
+		want string
+	}{
+		{Info{GoVersion: "go1.10"}, "unknown (go1.10)"},
+		{Info{Version: "3.6.0", Commit: "bbb06b1aaaa", Date: "2018-06-08T09:51:40Z", Dirty: true, GoVersion: "go1.10"},
+			"3.6.0 (bbb06b1-dirty 2018-06-08T09:51:40Z, go1.10)"},
+	}
+	for _, tt := range table {
+		if got := tt.i.String(); got != tt.want {
+			t.Errorf("got: %q, want: %q", got, tt.want)
+		}
+	}
+}
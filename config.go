@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ETCDEVTeam/janus/deploy"
+	"github.com/ETCDEVTeam/janus/gitvv"
+	"github.com/ETCDEVTeam/janus/lint"
+	"github.com/ETCDEVTeam/janus/stamp"
+)
+
+// defaultConfigFile is read from the base directory if no -config is given.
+const defaultConfigFile = ".janus.json"
+
+// janusConfig is the optional JSON config file, eg.
+//
+//	{
+//	  "version": {
+//	    "channels": [
+//	      {"branch": "master", "pre": "beta", "counter": "commits"},
+//	      {"branch": "develop", "pre": "alpha", "counter": "build"}
+//	    ]
+//	  },
+//	  "stamp": [
+//	    {"type": "json", "file": "package.json", "key": "version"},
+//	    {"type": "go", "file": "params/version.go", "key": "Version"}
+//	  ],
+//	  "lint": {"scopes": ["eth", "core"], "trailers": ["Signed-off-by"]}
+//	}
+type janusConfig struct {
+	Version *gitvv.Config `json:"version"`
+	// Stamp lists the files written by the stamp command.
+	Stamp []stamp.Target `json:"stamp"`
+	// Lint is the commit message rules of the lint-commits command.
+	Lint *lint.Rules `json:"lint"`
+}
+
+// readConfig reads the config file at 'path', or the default config file in 'dir' if path is empty.
+// A missing default config file is not an error.
+func readConfig(path, dir string) (*janusConfig, error) {
+	c := &janusConfig{}
+	if path == "" {
+		if dir == "" {
+			dir = "."
+		}
+		path = filepath.Join(dir, defaultConfigFile)
+		if _, e := os.Stat(path); os.IsNotExist(e) {
+			return c, nil
+		}
+	}
+	b, e := ioutil.ReadFile(path)
+	if e != nil {
+		return nil, e
+	}
+	if e := json.Unmarshal(b, c); e != nil {
+		return nil, e
+	}
+	if c.Version != nil {
+		if e := c.Version.Validate(); e != nil {
+			return nil, e
+		}
+	}
+	return c, nil
+}
+
+// versionOptions are the flags shared by commands computing versions.
+type versionOptions struct {
+	// dir is the last -dir given, dirs all of them.
+	dir       string
+	dirs      []string
+	config    string
+	scheme    string
+	calver    string
+	channels  stringsFlag
+	keyring   string
+	untrusted string
+}
+
+func (f *versionOptions) register(fs *flag.FlagSet) {
+	fs.Var(dirsFlag{f}, "dir", `path to base directory`)
+	fs.StringVar(&f.config, "config", "", `path to JSON config file (default: <dir>/`+defaultConfigFile+` if exists)`)
+	fs.StringVar(&f.scheme, "scheme", "", `versioning scheme of tags: semver, calver (default: semver, or from config)`)
+	fs.StringVar(&f.calver, "calver", "", `calver layout, tokens: YYYY, YY, 0Y, MM, 0M, WW, 0W, DD, 0D, MAJOR, MINOR, MICRO
+
+eg.
+-scheme calver -calver YYYY.0M.MICRO -format %V
+--> 2018.06.3
+
+Default: `+gitvv.DefaultCalVerLayout+`
+`)
+	fs.Var(&f.channels, "channel", `channel rule for %V, may be repeated, first match wins:
+
+BRANCH=PRE[:COUNTER[:BUMP]]
+
+BRANCH - branch name or pattern, eg. master, release/*
+PRE - pre-release identifier, eg. beta
+COUNTER - commits (default), build (CI build number), timestamp
+BUMP - part of last tag to increment: major, minor (default), patch
+
+eg.
+-channel master=beta -channel develop=alpha:build
+--> 3.6.0-beta.14, 3.6.0-alpha.2107
+`)
+	fs.StringVar(&f.keyring, "keyring", "", `OpenPGP keyring file (armored or binary) of keys trusted to sign tags
+if set, only tags with a valid signature from the keyring are used for versioning`)
+	fs.StringVar(&f.untrusted, "untrusted", "", `handling of unsigned or untrusted tags with -keyring:
+skip - use the nearest trusted tag instead (default)
+reject - fail`)
+}
+
+// versionConfig reads the config file and applies flags, which take precedence.
+func (f *versionOptions) versionConfig() (*gitvv.Config, error) {
+	return f.versionConfigFor(f.dir)
+}
+
+// versionConfigFor is versionConfig for base directory 'dir'.
+func (f *versionOptions) versionConfigFor(dir string) (*gitvv.Config, error) {
+	c, e := readConfig(f.config, dir)
+	if e != nil {
+		return nil, fmt.Errorf("failed to read config: %v", e)
+	}
+	vc := c.Version
+	if vc == nil {
+		vc = &gitvv.Config{}
+	}
+	var flagChannels []gitvv.Channel
+	for _, s := range f.channels {
+		ch, e := gitvv.ParseChannel(s)
+		if e != nil {
+			return nil, e
+		}
+		flagChannels = append(flagChannels, ch)
+	}
+	vc.Channels = append(flagChannels, vc.Channels...)
+	if f.scheme != "" {
+		vc.Scheme = f.scheme
+	}
+	if f.calver != "" {
+		vc.CalVer = f.calver
+	}
+	if f.keyring != "" {
+		vc.Keyring = f.keyring
+	}
+	if f.untrusted != "" {
+		vc.Untrusted = f.untrusted
+	}
+	if e := vc.Validate(); e != nil {
+		return nil, e
+	}
+	return vc, nil
+}
+
+// stringsFlag is a flag which may be given multiple times.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// clobberFlag is the -no-clobber flag, a boolean flag also taking 'skip', eg.
+// -no-clobber fails, -no-clobber=skip skips and -no-clobber=false replaces existing objects.
+// Unset, only objects of version-tagged paths aren't replaced, see deploy.ClobberAuto.
+type clobberFlag string
+
+func (c *clobberFlag) String() string {
+	return string(*c)
+}
+
+func (c *clobberFlag) IsBoolFlag() bool {
+	return true
+}
+
+func (c *clobberFlag) Set(v string) error {
+	if v == deploy.ClobberSkip {
+		*c = deploy.ClobberSkip
+		return nil
+	}
+	b, e := strconv.ParseBool(v)
+	if e != nil {
+		return fmt.Errorf("want true, false or %s", deploy.ClobberSkip)
+	}
+	*c = deploy.ClobberOverwrite
+	if b {
+		*c = deploy.ClobberFail
+	}
+	return nil
+}
+
+// dirsFlag is the -dir flag, which may be given multiple times.
+type dirsFlag struct {
+	f *versionOptions
+}
+
+func (d dirsFlag) String() string {
+	if d.f == nil {
+		return ""
+	}
+	return strings.Join(d.f.dirs, ",")
+}
+
+func (d dirsFlag) Set(v string) error {
+	d.f.dir = v
+	d.f.dirs = append(d.f.dirs, v)
+	return nil
+}
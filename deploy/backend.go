@@ -0,0 +1,196 @@
+// Package deploy uploads build artifacts to storage backends, selected by the scheme of
+// the destination, eg. gs://builds.etcdevteam.com/go-ethereum/v3.5.x or file:///srv/mirror
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotExist is returned by backends for objects which don't exist.
+var ErrNotExist = errors.New("object does not exist")
+
+// ErrExist is returned by Put with PutOptions.NoClobber for objects which exist.
+var ErrExist = errors.New("object already exists")
+
+// ErrGenerationMismatch is returned by Put with PutOptions.IfGenerationMatch for objects of another generation.
+var ErrGenerationMismatch = errors.New("object generation does not match")
+
+// Object is the metadata of a stored object.
+type Object struct {
+	// Name is the object name in the bucket, eg. go-ethereum/v3.5.x/geth.zip
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type,omitempty"`
+	Updated     time.Time `json:"updated"`
+	// Generation identifies the stored content of the object, if the backend supports it.
+	Generation int64 `json:"generation,omitempty"`
+}
+
+// PutOptions are options of an upload.
+type PutOptions struct {
+	// ContentType of the object, detected by the backend if empty.
+	ContentType string
+	// NoClobber fails the upload with ErrExist if the object exists, instead of replacing it.
+	NoClobber bool
+	// IfGenerationMatch replaces the object only if it is of this generation, see Object.Generation,
+	// else fails with ErrGenerationMismatch. Unset if 0. Not all backends support it.
+	IfGenerationMatch int64
+}
+
+// Backend stores objects in a bucket. Object names are '/' separated.
+// Backends must be safe for concurrent use.
+type Backend interface {
+	// Put uploads the content of 'r' to object 'name', replacing it if it exists,
+	// unless prevented by the preconditions of 'opts'.
+	Put(ctx context.Context, name string, r io.ReadSeeker, opts PutOptions) (*Object, error)
+	// Get gets the content of an object, or ErrNotExist. The caller closes it.
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	// Stat gets the metadata of an object, or ErrNotExist.
+	Stat(ctx context.Context, name string) (*Object, error)
+	// List lists the objects with names starting with 'prefix'.
+	List(ctx context.Context, prefix string) ([]*Object, error)
+	// Copy copies object 'src' to 'dst' in the bucket.
+	Copy(ctx context.Context, src, dst string) (*Object, error)
+	// Delete deletes an object, or returns ErrNotExist.
+	Delete(ctx context.Context, name string) error
+	// Close releases the backend's resources.
+	Close() error
+}
+
+// Options are credentials and settings for opening a backend.
+type Options struct {
+	// Key is a credentials file, which may be encrypted with $GCP_PASSWD, see DecryptKey.
+	Key string
+	// GPG decrypts Key with GPG 2 instead of openssl.
+	GPG bool
+	// Mode is the permission of files written by the file backend, DefaultFileMode if 0.
+	Mode os.FileMode
+	// Endpoint is the URL of an S3-compatible service, eg. https://minio.example.com:9000
+	Endpoint string
+	// Region is the region requests to S3 are signed for.
+	Region string
+	// PathStyle addresses S3 buckets as endpoint/bucket instead of bucket.endpoint.
+	PathStyle bool
+	// PartSize is the size of the parts of multipart uploads, the backend's default if 0.
+	PartSize int64
+	// Commit is the commit releases are created at, if their tag doesn't exist yet.
+	Commit string
+	// Draft creates releases as drafts.
+	Draft bool
+	// MkCol creates the collections (directories) of objects uploaded to http(s):// with WebDAV MKCOL.
+	MkCol bool
+	// Parallel is the number of files uploaded concurrently, DefaultParallel if 0.
+	Parallel int
+	// Retry is the policy of retrying failed operations, DefaultRetry if nil.
+	Retry *Retry
+	// ChunkSize is the size of the chunks of resumable uploads, the backend's default if 0.
+	// Negative uploads in a single request.
+	ChunkSize int64
+	// Checksums are the algorithms of manifests of checksums to upload with the files, eg. sha256, see ParseChecksums.
+	Checksums []string
+	// Signer signs the files and manifests of checksums, whose signatures are uploaded next to them.
+	Signer Signer
+	// Clobber is the policy for files whose objects exist, ClobberAuto if empty.
+	Clobber string
+	// Channels are the pre-release identifiers of channel builds, eg. beta, whose paths ClobberAuto overwrites,
+	// and Tags the release tags of the deployed repo, whose paths it doesn't. See IsVersionTagged.
+	Channels []string
+	Tags     []string
+	// IfGenerationMatch replaces the object of the only file uploaded if it is of this generation, see PutOptions.
+	IfGenerationMatch int64
+}
+
+// Signer creates detached signatures, eg. see package sign.
+type Signer interface {
+	// Sign signs the content of 'r', of file 'name'.
+	Sign(name string, r io.Reader) ([]byte, error)
+	// Ext is the extension of signature files, eg. .asc
+	Ext() string
+}
+
+// Location is a parsed deploy destination.
+type Location struct {
+	// Scheme selects the backend, eg. gs, s3, file
+	Scheme string
+	// Bucket is the bucket name, or the root directory for file.
+	Bucket string
+	// Prefix is the object name prefix files are uploaded into, eg. go-ethereum/v3.5.x
+	Prefix string
+}
+
+// SchemeGCS is the scheme of Google Cloud Storage, and of destinations without a scheme.
+const SchemeGCS = "gs"
+
+// ParseLocation parses a destination URL, eg. gs://builds.etcdevteam.com/go-ethereum/v3.5.x
+// A destination without a scheme is a GCS bucket and path, eg. builds.etcdevteam.com/go-ethereum/v3.5.x
+// For file:// the path is the directory files are copied into, eg. file:///srv/mirror/go-ethereum/v3.5.x
+func ParseLocation(to string) (Location, error) {
+	scheme := SchemeGCS
+	if i := strings.Index(to, "://"); i >= 0 {
+		scheme, to = strings.ToLower(to[:i]), to[i+3:]
+	}
+	if to == "" {
+		return Location{}, fmt.Errorf("destination %s:// has no bucket or path", scheme)
+	}
+	if scheme == SchemeFile {
+		return Location{Scheme: scheme, Bucket: filepath.Clean(filepath.FromSlash(to))}, nil
+	}
+	// eg. builds.etcdevteam.com/go-ethereum/3.5.x
+	parts := strings.SplitN(filepath.ToSlash(filepath.Clean(to)), "/", 2)
+	l := Location{Scheme: scheme, Bucket: parts[0]}
+	if len(parts) == 2 {
+		l.Prefix = strings.Trim(path.Clean(parts[1]), "/")
+	}
+	return l, nil
+}
+
+// String returns the location as a URL.
+func (l Location) String() string {
+	if l.Scheme == SchemeFile {
+		return "file://" + filepath.ToSlash(l.Bucket)
+	}
+	return l.Scheme + "://" + path.Join(l.Bucket, l.Prefix)
+}
+
+// Object gets the object name of a file uploaded to the location, eg. go-ethereum/v3.5.x/geth.zip
+func (l Location) Object(file string) string {
+	return path.Join(l.Prefix, filepath.Base(file))
+}
+
+// OpenFunc opens a backend for the bucket of a location.
+type OpenFunc func(ctx context.Context, l Location, opts Options) (Backend, error)
+
+var backends = make(map[string]OpenFunc)
+
+// Register makes a backend available by URL scheme.
+func Register(scheme string, fn OpenFunc) {
+	backends[scheme] = fn
+}
+
+// Schemes returns the registered URL schemes.
+func Schemes() []string {
+	var ss []string
+	for s := range backends {
+		ss = append(ss, s)
+	}
+	sort.Strings(ss)
+	return ss
+}
+
+// Open opens the backend for a location.
+func Open(ctx context.Context, l Location, opts Options) (Backend, error) {
+	fn, ok := backends[l.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported destination scheme '%s://', want one of: %s://", l.Scheme, strings.Join(Schemes(), ":// "))
+	}
+	return fn(ctx, l, opts)
+}
@@ -0,0 +1,218 @@
+package deploy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// checksumHashes are the supported checksum algorithms.
+var checksumHashes = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// ParseChecksums parses a comma separated list of checksum algorithms, eg. sha256,sha512
+func ParseChecksums(s string) ([]string, error) {
+	var algs []string
+	for _, alg := range strings.Split(s, ",") {
+		alg = strings.ToLower(strings.TrimSpace(alg))
+		if alg == "" {
+			continue
+		}
+		if _, ok := checksumHashes[alg]; !ok {
+			return nil, fmt.Errorf("unsupported checksum: %s, supported: sha256, sha512", alg)
+		}
+		algs = append(algs, alg)
+	}
+	return algs, nil
+}
+
+// ChecksumsName gets the name of the manifest of checksums of algorithm 'alg', eg. SHA256SUMS
+func ChecksumsName(alg string) string {
+	return strings.ToUpper(alg) + "SUMS"
+}
+
+// Checksum gets the hex checksum of algorithm 'alg' of the content of 'r'.
+func Checksum(alg string, r io.Reader) (string, error) {
+	newHash, ok := checksumHashes[alg]
+	if !ok {
+		return "", fmt.Errorf("unsupported checksum: %s", alg)
+	}
+	h := newHash()
+	if _, e := io.Copy(h, r); e != nil {
+		return "", e
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checksumFile gets the checksum of algorithm 'alg' of a file.
+func checksumFile(alg, file string) (string, error) {
+	f, e := os.Open(file)
+	if e != nil {
+		return "", e
+	}
+	defer f.Close()
+	return Checksum(alg, f)
+}
+
+// Checksums maps file names to hex checksums, as in a manifest in the format of GNU coreutils,
+// eg. sha256sum's output:
+//
+//	e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  geth.zip
+type Checksums map[string]string
+
+// ParseChecksumsManifest parses a manifest of checksums. Lines of files checksummed in binary
+// mode, eg. '<checksum> *geth.zip', and blank lines are accepted.
+func ParseChecksumsManifest(r io.Reader) (Checksums, error) {
+	c := make(Checksums)
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimRight(s.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		i := strings.Index(line, " ")
+		if i <= 0 || i+2 > len(line) || line[i+1] != ' ' && line[i+1] != '*' {
+			return nil, fmt.Errorf("line %d: invalid checksum line: %q", n, line)
+		}
+		sum, name := strings.ToLower(line[:i]), line[i+2:]
+		if _, e := hex.DecodeString(sum); e != nil || name == "" {
+			return nil, fmt.Errorf("line %d: invalid checksum line: %q", n, line)
+		}
+		c[name] = sum
+	}
+	return c, s.Err()
+}
+
+// Bytes formats the manifest, sorted by file name.
+func (c Checksums) Bytes() []byte {
+	var names []string
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s  %s\n", c[name], name)
+	}
+	return b.Bytes()
+}
+
+// manifestAttempts is the number of attempts to update a manifest, or its signature, replaced by concurrent deploys.
+const manifestAttempts = 5
+
+// putChecksums uploads the manifest of checksums of algorithm 'alg' of 'files' to 'object',
+// merged with an existing manifest so the files of other deploys to the same directory are kept.
+// The manifest is only replaced if it is of the generation it was read at, else it is read and merged again,
+// so concurrent deploys keep each other's entries. Backends without generations, eg. s3://, https:// or github://,
+// can't check that, so concurrent deploys to them may lose each other's entries.
+// The manifest's signature is uploaded too if 's' is set, of the manifest as stored.
+func putChecksums(ctx context.Context, b Backend, object, alg string, files []string, s Signer) error {
+	c := make(Checksums)
+	for _, f := range files {
+		sum, e := checksumFile(alg, f)
+		if e != nil {
+			return e
+		}
+		c[filepath.Base(f)] = sum
+	}
+
+	var manifest []byte
+	for n := 1; ; n++ {
+		var e error
+		manifest, e = updateChecksums(ctx, b, object, c)
+		if e == nil {
+			break
+		}
+		if e != ErrGenerationMismatch && e != ErrExist || n >= manifestAttempts {
+			return e
+		}
+		fmt.Printf("%s: replaced by another deploy, merging again (%d/%d)\n", object, n, manifestAttempts-1)
+	}
+	if s == nil {
+		return nil
+	}
+
+	// A concurrent deploy may replace the manifest before its signature is uploaded, whose signature
+	// would be replaced by this one, so the stored manifest is signed until it doesn't change.
+	for n := 1; ; n++ {
+		if e := putSignature(ctx, b, s, object, path.Base(object), bytes.NewReader(manifest), false); e != nil {
+			return e
+		}
+		stored, e := readObject(ctx, b, object)
+		if e != nil {
+			return e
+		}
+		if bytes.Equal(stored, manifest) {
+			return nil
+		}
+		if n >= manifestAttempts {
+			return fmt.Errorf("%s: replaced by other deploys while signing it", object)
+		}
+		manifest = stored
+	}
+}
+
+// updateChecksums merges the manifest at 'object' into 'c', uploading it on the condition that the manifest
+// wasn't replaced since it was read, or created since it was found missing.
+// It returns the uploaded manifest, or ErrGenerationMismatch or ErrExist if that failed.
+func updateChecksums(ctx context.Context, b Backend, object string, c Checksums) ([]byte, error) {
+	merged := make(Checksums)
+	for f, sum := range c {
+		merged[f] = sum
+	}
+	opts := PutOptions{ContentType: "text/plain; charset=utf-8"}
+	o, e := b.Stat(ctx, object)
+	switch e {
+	case nil:
+		// 0 if the backend has no generations.
+		opts.IfGenerationMatch = o.Generation
+		rc, e := b.Get(ctx, object)
+		if e != nil {
+			return nil, e
+		}
+		existing, e := ParseChecksumsManifest(rc)
+		rc.Close()
+		if e != nil {
+			return nil, fmt.Errorf("existing manifest: %v", e)
+		}
+		for f, sum := range existing {
+			if _, ok := merged[f]; !ok {
+				merged[f] = sum
+			}
+		}
+	case ErrNotExist:
+		opts.NoClobber = true
+	default:
+		return nil, e
+	}
+
+	manifest := merged.Bytes()
+	if _, e := b.Put(ctx, object, bytes.NewReader(manifest), opts); e != nil {
+		return nil, e
+	}
+	return manifest, nil
+}
+
+// readObject reads the content of 'object'.
+func readObject(ctx context.Context, b Backend, object string) ([]byte, error) {
+	rc, e := b.Get(ctx, object)
+	if e != nil {
+		return nil, e
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
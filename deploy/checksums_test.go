@@ -0,0 +1,143 @@
+package deploy
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseChecksums(t *testing.T) {
+	table := []struct {
+		s    string
+		want []string
+		ok   bool
+	}{
+		{"sha256", []string{"sha256"}, true},
+		{"SHA256, sha512", []string{"sha256", "sha512"}, true},
+		{"", nil, true},
+		{"md5", nil, false},
+	}
+	for _, tt := range table {
+		got, e := ParseChecksums(tt.s)
+		if (e == nil) != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got: %v, %v, want: %v", tt.s, got, e, tt.want)
+		}
+	}
+}
+
+func TestParseChecksumsManifest(t *testing.T) {
+	table := []struct {
+		manifest string
+		want     Checksums
+		ok       bool
+	}{
+		{"ab01  geth.zip\nAB02 *geth osx.zip\r\n\n", Checksums{"geth.zip": "ab01", "geth osx.zip": "ab02"}, true},
+		{"", Checksums{}, true},
+		{"ab01 geth.zip\n", nil, false},
+		{"xyz  geth.zip\n", nil, false},
+		{"ab01  \n", nil, false},
+	}
+	for _, tt := range table {
+		got, e := ParseChecksumsManifest(strings.NewReader(tt.manifest))
+		if (e == nil) != tt.ok || tt.ok && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got: %v, %v, want: %v", tt.manifest, got, e, tt.want)
+		}
+	}
+	c := Checksums{"geth-osx.zip": "ab02", "geth-linux.zip": "ab01"}
+	if got, want := string(c.Bytes()), "ab01  geth-linux.zip\nab02  geth-osx.zip\n"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
+
+func TestDeploy_checksums(t *testing.T) {
+	m := registerMem()
+	// Of a deploy of another platform, and a stale checksum of geth-linux.zip.
+	m.objects["go-ethereum/v3.5.x/SHA256SUMS"] = []byte("0000  geth-linux.zip\nabcd  geth-win.zip\n")
+	dir, e := ioutil.TempDir("", "janus-deploy")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	for _, f := range []string{"geth-linux.zip", "geth-osx.zip"} {
+		if e := ioutil.WriteFile(filepath.Join(dir, f), []byte(f), 0644); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	if e := Deploy(context.Background(), "mem://bucket/go-ethereum/v3.5.x", filepath.Join(dir, "*.zip"), Options{Checksums: []string{"sha256", "sha512"}}); e != nil {
+		t.Fatal(e)
+	}
+	// echo -n geth-linux.zip | sha256sum
+	want := "808a9e495788e3c02aa5e502bb839af7bd6b1f1063ab5b5b3ee7347cc9678a29  geth-linux.zip\n" +
+		"fb0c4ec9e59456997cb5af0af023ce2b0b42f515d73e9825da0fda695fb185b1  geth-osx.zip\n" +
+		"abcd  geth-win.zip\n"
+	if got := string(m.objects["go-ethereum/v3.5.x/SHA256SUMS"]); got != want {
+		t.Errorf("SHA256SUMS: got: %q, want: %q", got, want)
+	}
+	sha512sums, e := ParseChecksumsManifest(strings.NewReader(string(m.objects["go-ethereum/v3.5.x/SHA512SUMS"])))
+	if e != nil || len(sha512sums) != 2 || len(sha512sums["geth-osx.zip"]) != 128 {
+		t.Errorf("SHA512SUMS: got: %v, %v", sha512sums, e)
+	}
+}
+
+// racyBackend runs 'race' before the first upload of 'object', as a concurrent deploy would.
+type racyBackend struct {
+	*memBackend
+	object string
+	race   func()
+}
+
+func (b *racyBackend) Put(ctx context.Context, name string, r io.ReadSeeker, opts PutOptions) (*Object, error) {
+	if name == b.object && b.race != nil {
+		b.race()
+		b.race = nil
+	}
+	return b.memBackend.Put(ctx, name, r, opts)
+}
+
+func Test_putChecksums_concurrent(t *testing.T) {
+	dir, e := ioutil.TempDir("", "janus-deploy")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "geth-linux.zip")
+	if e := ioutil.WriteFile(file, []byte("geth-linux.zip"), 0644); e != nil {
+		t.Fatal(e)
+	}
+	linux := "808a9e495788e3c02aa5e502bb839af7bd6b1f1063ab5b5b3ee7347cc9678a29  geth-linux.zip\n"
+
+	for _, existing := range []string{"abcd  geth-win.zip\n", ""} {
+		m := &memBackend{objects: make(map[string][]byte)}
+		if existing != "" {
+			m.write("SHA256SUMS", []byte(existing))
+		}
+		// Another deploy replaces, or creates, the manifest after it was read.
+		b := &racyBackend{memBackend: m, object: "SHA256SUMS", race: func() {
+			m.write("SHA256SUMS", []byte(existing+"beef  geth-osx.zip\n"))
+		}}
+		if e := putChecksums(context.Background(), b, "SHA256SUMS", "sha256", []string{file}, testSigner{}); e != nil {
+			t.Fatal(e)
+		}
+		want := linux + "beef  geth-osx.zip\n" + existing
+		if got := string(m.objects["SHA256SUMS"]); got != want {
+			t.Errorf("existing: %q, got: %q, want: %q", existing, got, want)
+		}
+
+		// The signature is of the stored manifest, though it was replaced after the upload.
+		b = &racyBackend{memBackend: m, object: "SHA256SUMS.sig", race: func() {
+			m.write("SHA256SUMS", []byte(want+"cafe  geth-arm.zip\n"))
+		}}
+		if e := putChecksums(context.Background(), b, "SHA256SUMS", "sha256", []string{file}, testSigner{}); e != nil {
+			t.Fatal(e)
+		}
+		if got := string(m.objects["SHA256SUMS.sig"]); got != "SHA256SUMS:"+string(m.objects["SHA256SUMS"]) {
+			t.Errorf("existing: %q, got signature: %q of %q", existing, got, m.objects["SHA256SUMS"])
+		}
+	}
+}
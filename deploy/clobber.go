@@ -0,0 +1,104 @@
+package deploy
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Policies for files whose objects exist, see Options.Clobber.
+const (
+	// ClobberAuto fails for objects of version-tagged paths, which are immutable, and overwrites others, see IsVersionTagged.
+	ClobberAuto = "auto"
+	// ClobberOverwrite replaces existing objects.
+	ClobberOverwrite = "overwrite"
+	// ClobberFail fails the uploads of files whose objects exist.
+	ClobberFail = "fail"
+	// ClobberSkip skips files whose objects exist, keeping them.
+	ClobberSkip = "skip"
+)
+
+// reVersionTag matches released versions, eg. v3.5.0 or 3.6.0-rc.1, but not builds above them, eg. v3.5.0+14-adfe123
+var reVersionTag = regexp.MustCompile(`^v?\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`)
+
+// IsVersionTagged is whether an object is in a directory of a released version, eg. go-ethereum/v3.5.0/geth.zip,
+// or an asset of the release of its tag. Their objects are published, so immutable by default.
+// Directories of nightly builds aren't, eg. go-ethereum/v3.5.x, go-ethereum/nightly or go-ethereum/3.6.0-nightly.20180611093000,
+// nor those of builds of the pre-release 'channels', eg. go-ethereum/3.6.0-beta.14 for beta, unless they are of one of
+// the release 'tags', eg. v3.6.0-rc.1 for rc.
+func IsVersionTagged(object string, channels, tags []string) bool {
+	for _, dir := range strings.Split(path.Dir(object), "/") {
+		if !reVersionTag.MatchString(dir) || strings.Contains(strings.ToLower(dir), "nightly") {
+			continue
+		}
+		if isTag(dir, tags) || !isChannelBuild(dir, channels) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTag is whether a version is of one of 'tags', with or without a 'v' prefix.
+func isTag(version string, tags []string) bool {
+	for _, t := range tags {
+		if strings.TrimPrefix(t, "v") == strings.TrimPrefix(version, "v") {
+			return true
+		}
+	}
+	return false
+}
+
+// isChannelBuild is whether the pre-release of a version is that of a build of one of 'channels':
+// the identifiers of the channel, followed by a numeric counter, eg. beta.14 or rc.5.2 for rc.
+func isChannelBuild(version string, channels []string) bool {
+	i := strings.Index(version, "-")
+	if i < 0 {
+		return false
+	}
+	ids := strings.Split(version[i+1:], ".")
+	if strings.Trim(ids[len(ids)-1], "0123456789") != "" {
+		return false
+	}
+	for _, c := range channels {
+		cids := strings.Split(c, ".")
+		if len(ids) > len(cids) && strings.Join(ids[:len(cids)], ".") == c {
+			return true
+		}
+	}
+	return false
+}
+
+// checkClobber validates the policy and preconditions of 'opts' for uploading 'n' files.
+func checkClobber(opts Options, n int) error {
+	switch opts.Clobber {
+	case "", ClobberAuto, ClobberOverwrite, ClobberFail, ClobberSkip:
+	default:
+		return fmt.Errorf("unknown clobber policy '%s', want one of: %s, %s, %s, %s", opts.Clobber, ClobberAuto, ClobberOverwrite, ClobberFail, ClobberSkip)
+	}
+	if opts.IfGenerationMatch == 0 {
+		return nil
+	}
+	if n != 1 {
+		return fmt.Errorf("a generation precondition applies to a single file, got %d", n)
+	}
+	if opts.Clobber == ClobberFail || opts.Clobber == ClobberSkip {
+		return fmt.Errorf("a generation precondition replaces the object, which conflicts with the clobber policy '%s'", opts.Clobber)
+	}
+	return nil
+}
+
+// putOptions are the preconditions of uploading 'object' by the policy of 'opts'.
+// A generation precondition is a controlled overwrite, also of version-tagged paths.
+func putOptions(opts Options, object string) PutOptions {
+	switch opts.Clobber {
+	case ClobberFail, ClobberSkip:
+		return PutOptions{NoClobber: true}
+	case ClobberOverwrite:
+		return PutOptions{IfGenerationMatch: opts.IfGenerationMatch}
+	}
+	if opts.IfGenerationMatch != 0 {
+		return PutOptions{IfGenerationMatch: opts.IfGenerationMatch}
+	}
+	return PutOptions{NoClobber: IsVersionTagged(object, opts.Channels, opts.Tags)}
+}
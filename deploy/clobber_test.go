@@ -0,0 +1,118 @@
+package deploy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsVersionTagged(t *testing.T) {
+	channels := []string{"beta", "rc", "dev"}
+	tags := []string{"v3.6.0-rc.1", "3.6.0-beta.2"}
+	table := []struct {
+		object string
+		want   bool
+	}{
+		{"go-ethereum/v3.5.0/geth.zip", true},
+		{"go-ethereum/3.5.0/geth.zip", true},
+		{"v3.6.0-rc.1/geth.zip", true},
+		{"go-ethereum/v3.5.0/linux/geth.zip", true},
+		{"go-ethereum/v3.5.x/geth.zip", false},
+		{"go-ethereum/nightly/geth.zip", false},
+		{"go-ethereum/v3.5.0+14-adfe123/geth.zip", false},
+		{"go-ethereum/3.6.0-nightly.20180611093000/geth.zip", false},
+		{"go-ethereum/geth-v3.5.0.zip", false},
+		{"v3.5.0", false},
+		// Builds of channels, unless of a tag.
+		{"go-ethereum/3.6.0-beta.14/geth.zip", false},
+		{"go-ethereum/3.5.2-dev.3/geth.zip", false},
+		{"go-ethereum/3.6.0-rc.5.2/geth.zip", false},
+		{"go-ethereum/3.6.0-rc.1/geth.zip", true},
+		{"go-ethereum/v3.6.0-beta.2/geth.zip", true},
+		{"go-ethereum/3.6.0-alpha.3/geth.zip", true},
+		{"go-ethereum/3.6.0-beta/geth.zip", true},
+		{"go-ethereum/3.6.0-beta.x/geth.zip", true},
+		{"go-ethereum/3.6.0-betamax.1/geth.zip", true},
+	}
+	for _, tt := range table {
+		if got := IsVersionTagged(tt.object, channels, tags); got != tt.want {
+			t.Errorf("%s: got: %v, want: %v", tt.object, got, tt.want)
+		}
+	}
+	// Without channels, all pre-releases are versions.
+	if !IsVersionTagged("go-ethereum/3.6.0-beta.14/geth.zip", nil, nil) {
+		t.Error("3.6.0-beta.14 without channels: got: false")
+	}
+}
+
+func TestDeploy_clobber(t *testing.T) {
+	dir, e := ioutil.TempDir("", "janus-deploy")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	for _, f := range []string{"geth.zip", "geth.txt"} {
+		if e := ioutil.WriteFile(filepath.Join(dir, f), []byte("geth2"), 0644); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	table := []struct {
+		prefix  string
+		opts    Options
+		wantErr bool
+		want    string
+	}{
+		{"go-ethereum/v3.5.0", Options{}, true, "geth"},
+		{"go-ethereum/v3.5.x", Options{}, false, "geth2"},
+		{"go-ethereum/nightly", Options{}, false, "geth2"},
+		{"go-ethereum/v3.5.0", Options{Clobber: ClobberOverwrite}, false, "geth2"},
+		{"go-ethereum/v3.5.x", Options{Clobber: ClobberFail}, true, "geth"},
+		{"go-ethereum/v3.5.0", Options{Clobber: ClobberSkip}, false, "geth"},
+		{"go-ethereum/v3.5.0", Options{IfGenerationMatch: 1}, false, "geth2"},
+		{"go-ethereum/v3.5.0", Options{IfGenerationMatch: 2}, true, "geth"},
+		{"go-ethereum/v3.5.x", Options{Clobber: ClobberOverwrite, IfGenerationMatch: 2}, true, "geth"},
+		{"go-ethereum/3.6.0-beta.14", Options{}, true, "geth"},
+		{"go-ethereum/3.6.0-beta.14", Options{Channels: []string{"beta"}}, false, "geth2"},
+		{"go-ethereum/3.6.0-beta.14", Options{Channels: []string{"beta"}, Tags: []string{"v3.6.0-beta.14"}}, true, "geth"},
+	}
+	for _, tt := range table {
+		m := registerMem()
+		object := tt.prefix + "/geth.zip"
+		m.write(object, []byte("geth"))
+		e := Deploy(context.Background(), "mem://bucket/"+tt.prefix, filepath.Join(dir, "*.zip"), tt.opts)
+		if errs, ok := e.(*UploadErrors); tt.wantErr != ok || e != nil && !ok {
+			t.Errorf("%s %+v: got: %v, want error: %v", tt.prefix, tt.opts, e, tt.wantErr)
+		} else if ok && errs.Errors[0].Err != ErrExist && errs.Errors[0].Err != ErrGenerationMismatch {
+			t.Errorf("%s %+v: got: %v", tt.prefix, tt.opts, errs.Errors[0].Err)
+		}
+		if got := string(m.objects[object]); got != tt.want {
+			t.Errorf("%s %+v: got: %q, want: %q", tt.prefix, tt.opts, got, tt.want)
+		}
+	}
+
+	// Skipped files aren't listed in manifests, whose entries of them are kept.
+	m := registerMem()
+	m.write("go-ethereum/v3.5.0/geth.zip", []byte("geth"))
+	m.write("go-ethereum/v3.5.0/SHA256SUMS", []byte("abcd  geth.zip\n"))
+	if e := Deploy(context.Background(), "mem://bucket/go-ethereum/v3.5.0", filepath.Join(dir, "*.zip"), Options{Clobber: ClobberSkip, Checksums: []string{"sha256"}}); e != nil {
+		t.Fatal(e)
+	}
+	if got := string(m.objects["go-ethereum/v3.5.0/SHA256SUMS"]); got != "abcd  geth.zip\n" {
+		t.Errorf("SHA256SUMS: got: %q", got)
+	}
+
+	for _, opts := range []Options{
+		{Clobber: "nope"},
+		{Clobber: ClobberSkip, IfGenerationMatch: 1},
+	} {
+		if e := Deploy(context.Background(), "mem://bucket/go-ethereum", filepath.Join(dir, "*.zip"), opts); e == nil {
+			t.Errorf("%+v: want error", opts)
+		}
+	}
+	if e := Deploy(context.Background(), "mem://bucket/go-ethereum", filepath.Join(dir, "*"), Options{IfGenerationMatch: 1}); e == nil {
+		t.Error("want error for a generation precondition of several files")
+	}
+}
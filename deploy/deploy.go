@@ -0,0 +1,220 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultParallel is the number of files uploaded concurrently if Options.Parallel is unset.
+const DefaultParallel = 4
+
+// UploadError is the failure to upload a file.
+type UploadError struct {
+	File   string
+	Object string
+	Err    error
+}
+
+func (e *UploadError) Error() string {
+	return fmt.Sprintf("%s -> %s: %v", e.File, e.Object, e.Err)
+}
+
+// UploadErrors are the failures of a deploy, of 'Total' files.
+type UploadErrors struct {
+	Total  int
+	Errors []*UploadError
+}
+
+func (e *UploadErrors) Error() string {
+	var lines []string
+	for _, ue := range e.Errors {
+		lines = append(lines, ue.Error())
+	}
+	return fmt.Sprintf("%d of %d uploads failed:\n%s", len(e.Errors), e.Total, strings.Join(lines, "\n"))
+}
+
+// Deploy uploads the files matching the glob 'files' into the destination 'to',
+// eg. gs://builds.etcdevteam.com/go-ethereum/v3.5.x, see ParseLocation.
+// Up to 'opts.Parallel' files are uploaded concurrently. All files are attempted even if some fail,
+// which are returned as *UploadErrors.
+// With 'opts.Checksums', manifests of the checksums of the uploaded files are uploaded too, eg. SHA256SUMS.
+// With 'opts.Signer', detached signatures of the files and manifests are uploaded next to them, eg. geth.zip.asc
+// Existing objects are replaced, kept or fail the upload by 'opts.Clobber', see ClobberAuto.
+func Deploy(ctx context.Context, to, files string, opts Options) error {
+	l, e := ParseLocation(to)
+	if e != nil {
+		return e
+	}
+	files = filepath.Clean(files)
+
+	// Use glob to get matching file paths.
+	globs, e := filepath.Glob(files)
+	if e != nil {
+		return e
+	}
+	// Ensure there is something to upload
+	if len(globs) == 0 {
+		return errors.New("no files matching '-to' pattern were found")
+	}
+
+	var uploads []string
+	for _, f := range globs {
+		fi, e := os.Stat(f)
+		if e != nil {
+			return e
+		}
+		if fi.IsDir() {
+			fmt.Printf("%s is a directory, continuing", fi.Name())
+			continue
+		}
+		uploads = append(uploads, f)
+	}
+	if e := checkClobber(opts, len(uploads)); e != nil {
+		return e
+	}
+
+	b, e := Open(ctx, l, opts)
+	if e != nil {
+		return e
+	}
+	defer b.Close()
+	retry := DefaultRetry
+	if opts.Retry != nil {
+		retry = *opts.Retry
+	}
+	b = WithRetry(b, retry)
+
+	parallel := opts.Parallel
+	if parallel <= 0 {
+		parallel = DefaultParallel
+	}
+	results := make([]*UploadError, len(uploads))
+	skipped := make([]bool, len(uploads))
+	var mu sync.Mutex // serializes reports, so they don't interleave
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+
+	// Upload each file
+	for i, f := range uploads {
+		// eg.
+		// to: builds.etcdevteam.com/go-ethereum/3.5.x
+		// file: ./dist/geth.zip
+		// --> go-ethereum/3.5.x/geth.zip
+		object := l.Object(f)
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, f, object string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// Send it.
+			// The bucket is the directory of file://, eg. /srv/mirror/go-ethereum/v3.5.0
+			put := putOptions(opts, path.Join(filepath.ToSlash(l.Bucket), object))
+			e := upload(ctx, b, object, f, put, opts.Signer)
+			mu.Lock()
+			defer mu.Unlock()
+			if e == ErrExist && opts.Clobber == ClobberSkip {
+				skipped[i] = true
+				fmt.Printf("Skipped existing object:\n\tobject: %v\n\tfile: %v\n", object, f)
+				return
+			}
+			if e != nil {
+				results[i] = &UploadError{File: f, Object: object, Err: e}
+				fmt.Printf("Failed to upload (%d/%d):\n\tobject: %v\n\tfile: %v\n\terror: %v\n", i+1, len(uploads), object, f, e)
+				if e == ErrExist && opts.Clobber != ClobberFail {
+					fmt.Println("\tobjects of version-tagged paths are immutable")
+				}
+				return
+			}
+			fmt.Printf(`Successfully uploaded:
+	bucket: %v
+	object: %v
+	file: %v
+	`, l.Bucket, object, f)
+		}(i, f, object)
+	}
+	wg.Wait()
+
+	errs := &UploadErrors{Total: len(uploads)}
+	var summary, uploaded []string
+	for i, f := range uploads {
+		switch {
+		case results[i] != nil:
+			errs.Errors = append(errs.Errors, results[i])
+			summary = append(summary, "\tFAILED: "+l.Object(f))
+		case skipped[i]:
+			summary = append(summary, "\tskipped, exists: "+l.Object(f))
+		default:
+			uploaded = append(uploaded, f)
+			summary = append(summary, "\tok: "+l.Object(f))
+		}
+	}
+	fmt.Printf("\nUploaded %d of %d files to %s:\n%s\n", len(uploaded), len(uploads), l, strings.Join(summary, "\n"))
+
+	for _, alg := range opts.Checksums {
+		if len(uploaded) == 0 {
+			break
+		}
+		object := l.Object(ChecksumsName(alg))
+		if e := putChecksums(ctx, b, object, alg, uploaded, opts.Signer); e != nil {
+			errs.Total++
+			errs.Errors = append(errs.Errors, &UploadError{File: ChecksumsName(alg), Object: object, Err: e})
+			fmt.Printf("Failed to upload checksums:\n\tobject: %v\n\terror: %v\n", object, e)
+			continue
+		}
+		fmt.Printf("Uploaded checksums of %d files:\n\tobject: %v\n", len(uploaded), object)
+	}
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// upload uploads a file to an object of the backend with the preconditions of 'opts', unless 'ctx' is done,
+// and its signature if 's' is set, which is replaced unless 'opts.NoClobber'.
+func upload(ctx context.Context, b Backend, object, file string, opts PutOptions, s Signer) error {
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+	f, e := os.Open(file)
+	if e != nil {
+		return e
+	}
+	defer f.Close()
+
+	if _, e := b.Put(ctx, object, f, opts); e != nil {
+		return e
+	}
+	if s == nil {
+		return nil
+	}
+	if _, e := f.Seek(0, io.SeekStart); e != nil {
+		return e
+	}
+	e = putSignature(ctx, b, s, object, file, f, opts.NoClobber)
+	if e == ErrExist {
+		// Not of the file, which was uploaded.
+		return fmt.Errorf("signature %s: %v", object+s.Ext(), e)
+	}
+	return e
+}
+
+// putSignature uploads the signature of the content of 'r', of 'file', next to 'object',
+// failing with ErrExist if 'noClobber' and it exists.
+func putSignature(ctx context.Context, b Backend, s Signer, object, file string, r io.Reader, noClobber bool) error {
+	sig, e := s.Sign(file, r)
+	if e != nil {
+		return fmt.Errorf("sign: %v", e)
+	}
+	_, e = b.Put(ctx, object+s.Ext(), bytes.NewReader(sig), PutOptions{ContentType: "text/plain; charset=utf-8", NoClobber: noClobber})
+	return e
+}
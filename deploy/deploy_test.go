@@ -0,0 +1,276 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memBackend is an in-memory backend for tests.
+type memBackend struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	// gens are the generations of the objects, of 'gen' when written.
+	gens   map[string]int64
+	gen    int64
+	closed bool
+	// fail fails the upload of objects with names containing it, if set.
+	fail string
+	// active and maxActive count concurrent uploads.
+	active, maxActive int
+}
+
+func (m *memBackend) object(name string) *Object {
+	return &Object{Name: name, Size: int64(len(m.objects[name])), Updated: time.Unix(0, 0), Generation: m.gens[name]}
+}
+
+func (m *memBackend) Put(ctx context.Context, name string, r io.ReadSeeker, opts PutOptions) (*Object, error) {
+	m.mu.Lock()
+	m.active++
+	if m.active > m.maxActive {
+		m.maxActive = m.active
+	}
+	m.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active--
+	if m.fail != "" && strings.Contains(name, m.fail) {
+		return nil, errors.New("upload failed")
+	}
+	if _, ok := m.objects[name]; ok && opts.NoClobber {
+		return nil, ErrExist
+	}
+	if opts.IfGenerationMatch != 0 && m.gens[name] != opts.IfGenerationMatch {
+		return nil, ErrGenerationMismatch
+	}
+	b, e := ioutil.ReadAll(r)
+	if e != nil {
+		return nil, e
+	}
+	m.write(name, b)
+	return m.object(name), nil
+}
+
+// write stores an object as a new generation.
+func (m *memBackend) write(name string, b []byte) {
+	if m.gens == nil {
+		m.gens = make(map[string]int64)
+	}
+	m.gen++
+	m.objects[name] = b
+	m.gens[name] = m.gen
+}
+
+func (m *memBackend) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.objects[name]
+	if !ok {
+		return nil, ErrNotExist
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (m *memBackend) Stat(ctx context.Context, name string) (*Object, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[name]; !ok {
+		return nil, ErrNotExist
+	}
+	return m.object(name), nil
+}
+
+func (m *memBackend) List(ctx context.Context, prefix string) ([]*Object, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var objs []*Object
+	for name := range m.objects {
+		if strings.HasPrefix(name, prefix) {
+			objs = append(objs, m.object(name))
+		}
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Name < objs[j].Name })
+	return objs, nil
+}
+
+func (m *memBackend) Copy(ctx context.Context, src, dst string) (*Object, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.objects[src]
+	if !ok {
+		return nil, ErrNotExist
+	}
+	m.write(dst, b)
+	return m.object(dst), nil
+}
+
+func (m *memBackend) Delete(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[name]; !ok {
+		return ErrNotExist
+	}
+	delete(m.objects, name)
+	return nil
+}
+
+func (m *memBackend) Close() error {
+	m.closed = true
+	return nil
+}
+
+// registerMem registers a fresh in-memory backend as mem://
+func registerMem() *memBackend {
+	m := &memBackend{objects: make(map[string][]byte)}
+	Register("mem", func(ctx context.Context, l Location, opts Options) (Backend, error) {
+		return m, nil
+	})
+	return m
+}
+
+func TestParseLocation(t *testing.T) {
+	table := []struct {
+		to   string
+		want Location
+	}{
+		{"builds.etcdevteam.com/go-ethereum/v3.5.x", Location{"gs", "builds.etcdevteam.com", "go-ethereum/v3.5.x"}},
+		{"builds.etcdevteam.com", Location{"gs", "builds.etcdevteam.com", ""}},
+		{"gs://builds.etcdevteam.com/go-ethereum/v3.5.x/", Location{"gs", "builds.etcdevteam.com", "go-ethereum/v3.5.x"}},
+		{"S3://artifacts/geth//nightly", Location{"s3", "artifacts", "geth/nightly"}},
+		{"file:///srv/mirror/go-ethereum", Location{"file", filepath.FromSlash("/srv/mirror/go-ethereum"), ""}},
+	}
+	for _, tt := range table {
+		got, e := ParseLocation(tt.to)
+		if e != nil {
+			t.Errorf("%s: %v", tt.to, e)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got: %+v, want: %+v", tt.to, got, tt.want)
+		}
+	}
+	for _, to := range []string{"", "gs://", "file://"} {
+		if _, e := ParseLocation(to); e == nil {
+			t.Errorf("%q: want error", to)
+		}
+	}
+}
+
+func TestDeploy(t *testing.T) {
+	m := registerMem()
+	dir, e := ioutil.TempDir("", "janus-deploy")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	for _, f := range []string{"geth-linux.zip", "geth-osx.zip", "geth.txt"} {
+		if e := ioutil.WriteFile(filepath.Join(dir, f), []byte(f), 0644); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if e := os.Mkdir(filepath.Join(dir, "dir.zip"), 0755); e != nil {
+		t.Fatal(e)
+	}
+
+	if e := Deploy(context.Background(), "mem://bucket/go-ethereum/v3.5.x", filepath.Join(dir, "*.zip"), Options{}); e != nil {
+		t.Fatal(e)
+	}
+	want := map[string][]byte{
+		"go-ethereum/v3.5.x/geth-linux.zip": []byte("geth-linux.zip"),
+		"go-ethereum/v3.5.x/geth-osx.zip":   []byte("geth-osx.zip"),
+	}
+	if !reflect.DeepEqual(m.objects, want) {
+		t.Errorf("got: %v, want: %v", m.objects, want)
+	}
+	if !m.closed {
+		t.Error("backend not closed")
+	}
+
+	if e := Deploy(context.Background(), "mem://bucket", filepath.Join(dir, "*.none"), Options{}); e == nil {
+		t.Error("want error for no matching files")
+	}
+	if e := Deploy(context.Background(), "nope://bucket", filepath.Join(dir, "*.zip"), Options{}); e == nil {
+		t.Error("want error for unknown scheme")
+	}
+}
+
+func TestDeploy_parallel(t *testing.T) {
+	m := registerMem()
+	m.fail = "geth-3"
+	dir, e := ioutil.TempDir("", "janus-deploy")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	for i := 0; i < 8; i++ {
+		f := fmt.Sprintf("geth-%d.zip", i)
+		if e := ioutil.WriteFile(filepath.Join(dir, f), []byte(f), 0644); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	e = Deploy(context.Background(), "mem://bucket/go-ethereum", filepath.Join(dir, "*.zip"), Options{Parallel: 3})
+	errs, ok := e.(*UploadErrors)
+	if !ok {
+		t.Fatalf("got: %v, want *UploadErrors", e)
+	}
+	if errs.Total != 8 || len(errs.Errors) != 1 || errs.Errors[0].Object != "go-ethereum/geth-3.zip" {
+		t.Errorf("got: %v", errs)
+	}
+	if len(m.objects) != 7 {
+		t.Errorf("got %d objects, want 7 despite the failure", len(m.objects))
+	}
+	if m.maxActive < 2 || m.maxActive > 3 {
+		t.Errorf("got %d concurrent uploads, want 2-3", m.maxActive)
+	}
+}
+
+// testSigner "signs" content by prefixing it with the file name.
+type testSigner struct{}
+
+func (testSigner) Sign(name string, r io.Reader) ([]byte, error) {
+	b, e := ioutil.ReadAll(r)
+	return append([]byte(filepath.Base(name)+":"), b...), e
+}
+
+func (testSigner) Ext() string {
+	return ".sig"
+}
+
+func TestDeploy_sign(t *testing.T) {
+	m := registerMem()
+	dir, e := ioutil.TempDir("", "janus-deploy")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	if e := ioutil.WriteFile(filepath.Join(dir, "geth.zip"), []byte("geth"), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	if e := Deploy(context.Background(), "mem://bucket/go-ethereum", filepath.Join(dir, "*.zip"), Options{Checksums: []string{"sha256"}, Signer: testSigner{}}); e != nil {
+		t.Fatal(e)
+	}
+	if got := string(m.objects["go-ethereum/geth.zip.sig"]); got != "geth.zip:geth" {
+		t.Errorf("geth.zip.sig: got: %q", got)
+	}
+	if got, want := string(m.objects["go-ethereum/SHA256SUMS.sig"]), "SHA256SUMS:"+string(m.objects["go-ethereum/SHA256SUMS"]); got != want {
+		t.Errorf("SHA256SUMS.sig: got: %q, want: %q", got, want)
+	}
+	if len(m.objects) != 4 {
+		t.Errorf("got %d objects, want 4", len(m.objects))
+	}
+}
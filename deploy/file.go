@@ -0,0 +1,251 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SchemeFile is the scheme of the local filesystem, eg. file:///srv/mirror/go-ethereum/v3.5.x
+const SchemeFile = "file"
+
+// DefaultFileMode is the permission of files written by the file backend if Options.Mode is unset.
+const DefaultFileMode os.FileMode = 0644
+
+// tmpPrefix prefixes the temporary files written before being renamed into place.
+const tmpPrefix = ".janus-tmp-"
+
+func init() {
+	Register(SchemeFile, OpenFile)
+}
+
+// FileBackend stores objects as files under a root directory, eg. a NFS-hosted mirror.
+// Objects are written to a temporary file and renamed into place, so readers never see partial files.
+type FileBackend struct {
+	root string
+	mode os.FileMode
+}
+
+// OpenFile opens the directory of 'l' as a backend, creating it if it doesn't exist.
+func OpenFile(ctx context.Context, l Location, opts Options) (Backend, error) {
+	mode := opts.Mode
+	if mode == 0 {
+		mode = DefaultFileMode
+	}
+	b := &FileBackend{root: l.Bucket, mode: mode.Perm()}
+	if e := os.MkdirAll(b.root, b.dirMode()); e != nil {
+		return nil, e
+	}
+	return b, nil
+}
+
+// dirMode is the file mode with search permission wherever it has read permission, eg. 0644 -> 0755
+func (b *FileBackend) dirMode() os.FileMode {
+	return b.mode | (b.mode&0444)>>2
+}
+
+// path gets the file path of an object, which can't be outside of the root.
+func (b *FileBackend) path(name string) (string, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return "", errors.New("empty object name")
+	}
+	return filepath.Join(b.root, filepath.FromSlash(name)), nil
+}
+
+func (b *FileBackend) object(name string, fi os.FileInfo) *Object {
+	return &Object{
+		Name:        name,
+		Size:        fi.Size(),
+		ContentType: mime.TypeByExtension(path.Ext(name)),
+		Updated:     fi.ModTime(),
+		Generation:  fi.ModTime().UnixNano(),
+	}
+}
+
+// write writes the content of 'r' to a temporary file next to 'p' and renames it into place,
+// by the preconditions of 'opts'.
+func (b *FileBackend) write(p string, r io.Reader, opts PutOptions) error {
+	dir := filepath.Dir(p)
+	if e := os.MkdirAll(dir, b.dirMode()); e != nil {
+		return e
+	}
+	f, e := ioutil.TempFile(dir, tmpPrefix+filepath.Base(p)+"-")
+	if e != nil {
+		return e
+	}
+	tmp := f.Name()
+	if _, e := io.Copy(f, r); e != nil {
+		f.Close()
+		os.Remove(tmp)
+		return e
+	}
+	// Set the mode explicitly, since TempFile creates 0600 and the umask applies to OpenFile.
+	if e := f.Chmod(b.mode); e != nil {
+		f.Close()
+		os.Remove(tmp)
+		return e
+	}
+	if e := f.Sync(); e != nil {
+		f.Close()
+		os.Remove(tmp)
+		return e
+	}
+	if e := f.Close(); e != nil {
+		os.Remove(tmp)
+		return e
+	}
+	if e := place(tmp, p, opts); e != nil {
+		os.Remove(tmp)
+		return e
+	}
+	return nil
+}
+
+// place moves the file 'tmp' to 'p', by the preconditions of 'opts'. For NoClobber it is linked instead,
+// which fails if 'p' exists. The generation of IfGenerationMatch is checked before, which isn't atomic.
+func place(tmp, p string, opts PutOptions) error {
+	if opts.IfGenerationMatch != 0 {
+		fi, e := os.Stat(p)
+		if os.IsNotExist(e) || e == nil && fi.ModTime().UnixNano() != opts.IfGenerationMatch {
+			return ErrGenerationMismatch
+		}
+		if e != nil {
+			return e
+		}
+	}
+	if !opts.NoClobber {
+		return os.Rename(tmp, p)
+	}
+	if e := os.Link(tmp, p); e != nil {
+		if os.IsExist(e) {
+			return ErrExist
+		}
+		return e
+	}
+	os.Remove(tmp)
+	return nil
+}
+
+// Put writes the content of 'r' to the file of 'name'.
+func (b *FileBackend) Put(ctx context.Context, name string, r io.ReadSeeker, opts PutOptions) (*Object, error) {
+	p, e := b.path(name)
+	if e != nil {
+		return nil, e
+	}
+	if e := b.write(p, r, opts); e != nil {
+		return nil, e
+	}
+	return b.Stat(ctx, name)
+}
+
+// Get opens the file of 'name'.
+func (b *FileBackend) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	p, e := b.path(name)
+	if e != nil {
+		return nil, e
+	}
+	if fi, e := os.Stat(p); e == nil && fi.IsDir() {
+		return nil, ErrNotExist
+	}
+	f, e := os.Open(p)
+	if os.IsNotExist(e) {
+		return nil, ErrNotExist
+	}
+	if e != nil {
+		return nil, e
+	}
+	return f, nil
+}
+
+// Stat gets the metadata of the file of 'name'.
+func (b *FileBackend) Stat(ctx context.Context, name string) (*Object, error) {
+	p, e := b.path(name)
+	if e != nil {
+		return nil, e
+	}
+	fi, e := os.Stat(p)
+	if os.IsNotExist(e) || e == nil && fi.IsDir() {
+		return nil, ErrNotExist
+	}
+	if e != nil {
+		return nil, e
+	}
+	return b.object(name, fi), nil
+}
+
+// List lists the files under the root with names starting with 'prefix'.
+func (b *FileBackend) List(ctx context.Context, prefix string) ([]*Object, error) {
+	var objs []*Object
+	e := filepath.Walk(b.root, func(p string, fi os.FileInfo, e error) error {
+		if e != nil {
+			return e
+		}
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), tmpPrefix) {
+			return nil
+		}
+		rel, e := filepath.Rel(b.root, p)
+		if e != nil {
+			return e
+		}
+		name := filepath.ToSlash(rel)
+		if strings.HasPrefix(name, prefix) {
+			objs = append(objs, b.object(name, fi))
+		}
+		return nil
+	})
+	if e != nil {
+		return nil, e
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Name < objs[j].Name })
+	return objs, nil
+}
+
+// Copy copies the file of 'src' to that of 'dst'.
+func (b *FileBackend) Copy(ctx context.Context, src, dst string) (*Object, error) {
+	sp, e := b.path(src)
+	if e != nil {
+		return nil, e
+	}
+	dp, e := b.path(dst)
+	if e != nil {
+		return nil, e
+	}
+	f, e := os.Open(sp)
+	if os.IsNotExist(e) {
+		return nil, ErrNotExist
+	}
+	if e != nil {
+		return nil, e
+	}
+	defer f.Close()
+	if e := b.write(dp, f, PutOptions{}); e != nil {
+		return nil, e
+	}
+	return b.Stat(ctx, dst)
+}
+
+// Delete removes the file of 'name'.
+func (b *FileBackend) Delete(ctx context.Context, name string) error {
+	p, e := b.path(name)
+	if e != nil {
+		return e
+	}
+	e = os.Remove(p)
+	if os.IsNotExist(e) {
+		return ErrNotExist
+	}
+	return e
+}
+
+// Close is a no-op.
+func (b *FileBackend) Close() error {
+	return nil
+}
@@ -0,0 +1,183 @@
+package deploy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestFileBackend(t *testing.T) {
+	root, e := ioutil.TempDir("", "janus-file")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(root)
+	ctx := context.Background()
+
+	b, e := Open(ctx, Location{Scheme: SchemeFile, Bucket: filepath.Join(root, "mirror")}, Options{Mode: 0640})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer b.Close()
+
+	o, e := b.Put(ctx, "go-ethereum/v3.5.x/geth.zip", strings.NewReader("geth"), PutOptions{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if o.Name != "go-ethereum/v3.5.x/geth.zip" || o.Size != 4 || o.ContentType != "application/zip" {
+		t.Errorf("got: %+v", o)
+	}
+	p := filepath.Join(root, "mirror", "go-ethereum", "v3.5.x", "geth.zip")
+	if b, e := ioutil.ReadFile(p); e != nil || string(b) != "geth" {
+		t.Errorf("got: %q, %v", b, e)
+	}
+	if runtime.GOOS != "windows" {
+		for _, tt := range []struct {
+			path string
+			want os.FileMode
+		}{
+			{p, 0640},
+			{filepath.Dir(p), 0750},
+		} {
+			fi, e := os.Stat(tt.path)
+			if e != nil {
+				t.Fatal(e)
+			}
+			if fi.Mode().Perm() != tt.want {
+				t.Errorf("%s: got: %v, want: %v", tt.path, fi.Mode().Perm(), tt.want)
+			}
+		}
+	}
+
+	// Replace in place, and escape attempts stay under the root.
+	if _, e := b.Put(ctx, "go-ethereum/v3.5.x/geth.zip", strings.NewReader("geth2"), PutOptions{}); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := b.Put(ctx, "../../escaped.txt", strings.NewReader("x"), PutOptions{}); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := os.Stat(filepath.Join(root, "mirror", "escaped.txt")); e != nil {
+		t.Error(e)
+	}
+	if _, e := b.Copy(ctx, "go-ethereum/v3.5.x/geth.zip", "go-ethereum/latest/geth.zip"); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := b.Copy(ctx, "nope", "go-ethereum/latest/nope"); e != ErrNotExist {
+		t.Errorf("got: %v, want: %v", e, ErrNotExist)
+	}
+
+	objs, e := b.List(ctx, "go-ethereum/")
+	if e != nil {
+		t.Fatal(e)
+	}
+	var names []string
+	for _, o := range objs {
+		names = append(names, o.Name)
+		if o.Size != 5 {
+			t.Errorf("%s: got size: %d, want: 5", o.Name, o.Size)
+		}
+	}
+	if got, want := strings.Join(names, " "), "go-ethereum/latest/geth.zip go-ethereum/v3.5.x/geth.zip"; got != want {
+		t.Errorf("got: %s, want: %s", got, want)
+	}
+
+	if e := b.Delete(ctx, "go-ethereum/latest/geth.zip"); e != nil {
+		t.Fatal(e)
+	}
+	if e := b.Delete(ctx, "go-ethereum/latest/geth.zip"); e != ErrNotExist {
+		t.Errorf("got: %v, want: %v", e, ErrNotExist)
+	}
+	if _, e := b.Stat(ctx, "go-ethereum/latest/geth.zip"); e != ErrNotExist {
+		t.Errorf("got: %v, want: %v", e, ErrNotExist)
+	}
+	if _, e := b.Stat(ctx, "go-ethereum"); e != ErrNotExist {
+		t.Errorf("directory: got: %v, want: %v", e, ErrNotExist)
+	}
+}
+
+func TestFileBackend_preconditions(t *testing.T) {
+	root, e := ioutil.TempDir("", "janus-file")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(root)
+	ctx := context.Background()
+	b, e := OpenFile(ctx, Location{Scheme: SchemeFile, Bucket: root}, Options{})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	o, e := b.Put(ctx, "v3.5.0/geth.zip", strings.NewReader("geth"), PutOptions{NoClobber: true})
+	if e != nil {
+		t.Fatal(e)
+	}
+	table := []struct {
+		opts PutOptions
+		want error
+	}{
+		{PutOptions{NoClobber: true}, ErrExist},
+		{PutOptions{IfGenerationMatch: o.Generation + 1}, ErrGenerationMismatch},
+		{PutOptions{IfGenerationMatch: o.Generation}, nil},
+		// Replaced by the previous.
+		{PutOptions{IfGenerationMatch: o.Generation}, ErrGenerationMismatch},
+	}
+	for i, tt := range table {
+		if _, e := b.Put(ctx, "v3.5.0/geth.zip", strings.NewReader("geth2"), tt.opts); e != tt.want {
+			t.Errorf("%d %+v: got: %v, want: %v", i, tt.opts, e, tt.want)
+		}
+	}
+	if _, e := b.Put(ctx, "v3.5.0/nope.zip", strings.NewReader("geth"), PutOptions{IfGenerationMatch: o.Generation}); e != ErrGenerationMismatch {
+		t.Errorf("got: %v, want: %v", e, ErrGenerationMismatch)
+	}
+	if got, e := ioutil.ReadFile(filepath.Join(root, "v3.5.0", "geth.zip")); e != nil || string(got) != "geth2" {
+		t.Errorf("got: %q, %v", got, e)
+	}
+	if left, _ := filepath.Glob(filepath.Join(root, "v3.5.0", tmpPrefix+"*")); len(left) > 0 {
+		t.Errorf("temporary files left: %v", left)
+	}
+}
+
+func TestDeploy_file(t *testing.T) {
+	dir, e := ioutil.TempDir("", "janus-deploy-file")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	for _, f := range []string{"geth-linux.zip", "geth-osx.zip"} {
+		if e := ioutil.WriteFile(filepath.Join(dir, f), []byte(f), 0600); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	to := "file://" + filepath.ToSlash(filepath.Join(dir, "mirror", "go-ethereum", "v3.5.x"))
+	if e := Deploy(context.Background(), to, filepath.Join(dir, "*.zip"), Options{}); e != nil {
+		t.Fatal(e)
+	}
+	for _, f := range []string{"geth-linux.zip", "geth-osx.zip"} {
+		p := filepath.Join(dir, "mirror", "go-ethereum", "v3.5.x", f)
+		b, e := ioutil.ReadFile(p)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if string(b) != f {
+			t.Errorf("%s: got: %q, want: %q", p, b, f)
+		}
+	}
+	left, _ := filepath.Glob(filepath.Join(dir, "mirror", "go-ethereum", "v3.5.x", tmpPrefix+"*"))
+	if len(left) > 0 {
+		t.Errorf("temporary files left: %v", left)
+	}
+
+	// Version-tagged directories aren't replaced.
+	to = "file://" + filepath.ToSlash(filepath.Join(dir, "mirror", "go-ethereum", "v3.5.0"))
+	if e := Deploy(context.Background(), to, filepath.Join(dir, "*.zip"), Options{}); e != nil {
+		t.Fatal(e)
+	}
+	if e := Deploy(context.Background(), to, filepath.Join(dir, "*.zip"), Options{}); e == nil {
+		t.Error("want error replacing files of a version-tagged directory")
+	}
+}
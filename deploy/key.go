@@ -0,0 +1,89 @@
+package deploy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+var decryptedKeyFileName = "./" + strconv.Itoa(os.Getpid()) + "-gcloud.json"
+
+// DecryptKey decrypts a JSON key file encrypted with $GCP_PASSWD, using openssl, or GPG 2 if 'gpg' is set.
+// It returns the path of the decrypted key, or of 'key' itself if it isn't encrypted,
+// and a func removing the decrypted key file, which must be called when done.
+func DecryptKey(key string, gpg bool) (string, func(), error) {
+	key = filepath.Clean(key)
+
+	// Ensure key file exists.
+	if _, e := os.Stat(key); e != nil {
+		return "", nil, e
+	}
+
+	var arbitraryMap = make(map[string]interface{})
+
+	// Read key file
+	bRead, eRead := ioutil.ReadFile(key)
+	if eRead != nil {
+		return "", nil, eRead
+	}
+
+	// Attempt to unmarshal key file, checks for encryption
+	e := json.Unmarshal(bRead, &arbitraryMap)
+	if e == nil {
+		return key, func() {}, nil
+	}
+	fmt.Println("key is possibly encryped, attempting to decrypt with $GCP_PASSWD")
+
+	passwd := os.Getenv("GCP_PASSWD")
+	if passwd == "" {
+		return "", nil, errors.New("env GCP_PASSWD not set, cannot decrypt")
+	}
+	// Assume reading for decoding error is it's encrypted... attempt to decrypt
+	if gpg {
+		// use pipe to securly provide password
+		cmd := exec.Command("gpg", "--batch", "--passphrase-fd", "0", "--decrypt", "--output", decryptedKeyFileName, key)
+		writer, err := cmd.StdinPipe()
+		if err != nil {
+			return "", nil, err
+		}
+		go func() {
+			defer writer.Close()
+			io.WriteString(writer, passwd)
+		}()
+		err = cmd.Run()
+		if err != nil {
+			return "", nil, err
+		}
+	} else {
+		if decryptError := exec.Command("openssl", "aes-256-cbc", "-pass", "env:GCP_PASSWD", "-in", key, "-out", decryptedKeyFileName, "-d").Run(); decryptError != nil {
+			return "", nil, decryptError
+		}
+	}
+
+	fmt.Println("decrypted key file to: ", decryptedKeyFileName)
+	key = decryptedKeyFileName
+
+	// Only remove *unecrypted* key file
+	return key, func() {
+		key = filepath.Clean(key)
+		p, pe := filepath.Abs(key)
+		if pe != nil {
+			fmt.Println(pe)
+		} else {
+			key = p
+		}
+
+		fmt.Printf(`
+				removing key: %v
+				`, key)
+		if errRm := os.Remove(key); errRm != nil {
+			fmt.Println(errRm)
+		}
+	}, nil
+}
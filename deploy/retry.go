@@ -0,0 +1,240 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultRetries is the number of retries of failed operations if Options.Retry is unset.
+const DefaultRetries = 3
+
+// Retry is a policy of retrying operations failing with retryable errors, see IsRetryable,
+// with exponential backoff and full jitter.
+type Retry struct {
+	// Retries is the number of retries after the first attempt.
+	Retries int
+	// Initial is the maximum delay before the first retry, doubled for each further retry.
+	Initial time.Duration
+	// Max caps the maximum delay.
+	Max time.Duration
+}
+
+// DefaultRetry is the retry policy of deploys.
+var DefaultRetry = Retry{Retries: DefaultRetries, Initial: time.Second, Max: 30 * time.Second}
+
+// Backoff gets the delay before retry 'n', counting from 0: a random duration up to Initial * 2^n, capped at Max.
+func (r Retry) Backoff(n int) time.Duration {
+	d := r.Initial
+	for i := 0; i < n && d < r.Max; i++ {
+		d *= 2
+	}
+	if d > r.Max {
+		d = r.Max
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// Do calls 'fn' until it succeeds, fails with an error which isn't retryable, the retries
+// are exhausted or 'ctx' is done.
+func (r Retry) Do(ctx context.Context, what string, fn func() error) error {
+	for n := 0; ; n++ {
+		e := fn()
+		if e == nil || !IsRetryable(e) || n >= r.Retries || ctx.Err() != nil {
+			return e
+		}
+		d := r.Backoff(n)
+		fmt.Printf("%s: %v, retrying in %v (%d/%d)\n", what, e, d.Round(time.Millisecond), n+1, r.Retries)
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return e
+		case <-t.C:
+		}
+	}
+}
+
+// retryable marks an error as retryable.
+type retryable struct {
+	error
+}
+
+func (r retryable) Retryable() bool {
+	return true
+}
+
+// Retryable marks 'e' as retryable, eg. for a 503 response of a backend.
+func Retryable(e error) error {
+	if e == nil {
+		return nil
+	}
+	return retryable{e}
+}
+
+// final marks an error as not retryable.
+type final struct {
+	error
+}
+
+func (f final) Retryable() bool {
+	return false
+}
+
+// Final marks 'e' as not retryable, eg. for a failure of an operation the backend retried itself.
+func Final(e error) error {
+	if e == nil {
+		return nil
+	}
+	return final{e}
+}
+
+// IsRetryable is whether an operation failing with 'e' may succeed if retried:
+// errors with a Retryable() method returning true, eg. see Retryable, network timeouts,
+// and connections reset or closed unexpectedly. Context errors are not retryable.
+func IsRetryable(e error) bool {
+	if e == nil || e == context.Canceled || e == context.DeadlineExceeded {
+		return false
+	}
+	if r, ok := e.(interface{ Retryable() bool }); ok {
+		return r.Retryable()
+	}
+	if e == io.ErrUnexpectedEOF || errors.Is(e, io.ErrUnexpectedEOF) ||
+		errors.Is(e, syscall.ECONNRESET) || errors.Is(e, syscall.ECONNREFUSED) || errors.Is(e, syscall.EPIPE) {
+		return true
+	}
+	var ne net.Error
+	if errors.As(e, &ne) && ne.Timeout() {
+		return true
+	}
+	// Errors of net/http which don't wrap the cause.
+	s := e.Error()
+	return strings.Contains(s, "connection reset") || strings.Contains(s, "server closed idle connection") ||
+		strings.HasSuffix(s, ": EOF")
+}
+
+// retryBackend retries the operations of a backend.
+type retryBackend struct {
+	Backend
+	retry Retry
+}
+
+// WithRetry gets a backend retrying the operations of 'b' as per 'r'.
+// Put seeks its reader to the start for each attempt.
+func WithRetry(b Backend, r Retry) Backend {
+	return &retryBackend{Backend: b, retry: r}
+}
+
+// Put fails a retried upload for the preconditions of 'opts' only if the object differs from the content of 'r',
+// since a failed attempt may have stored it, eg. if the response was lost.
+func (b *retryBackend) Put(ctx context.Context, name string, r io.ReadSeeker, opts PutOptions) (*Object, error) {
+	var o *Object
+	attempts := 0
+	e := b.retry.Do(ctx, "put "+name, func() error {
+		attempts++
+		if _, e := r.Seek(0, io.SeekStart); e != nil {
+			return e
+		}
+		var e error
+		o, e = b.Backend.Put(ctx, name, r, opts)
+		return e
+	})
+	if attempts > 1 && (e == ErrExist || e == ErrGenerationMismatch) {
+		if stored, se := b.stored(ctx, name, r); se != nil {
+			fmt.Printf("put %s: %v\n", name, se)
+		} else if stored != nil {
+			fmt.Printf("put %s: %v, but with the content uploaded, stored by a failed attempt\n", name, e)
+			return stored, nil
+		}
+	}
+	return o, e
+}
+
+// stored gets the object 'name' if it is of the content of 'r', else nil.
+// Sizes are compared before the SHA-256 checksums of the contents.
+func (b *retryBackend) stored(ctx context.Context, name string, r io.ReadSeeker) (*Object, error) {
+	o, e := b.Stat(ctx, name)
+	if e != nil {
+		return nil, e
+	}
+	size, e := r.Seek(0, io.SeekEnd)
+	if e != nil {
+		return nil, e
+	}
+	if o.Size != size {
+		return nil, nil
+	}
+	if _, e := r.Seek(0, io.SeekStart); e != nil {
+		return nil, e
+	}
+	want, e := Checksum("sha256", r)
+	if e != nil {
+		return nil, e
+	}
+	rc, e := b.Get(ctx, name)
+	if e != nil {
+		return nil, e
+	}
+	defer rc.Close()
+	got, e := Checksum("sha256", rc)
+	if e != nil || got != want {
+		return nil, e
+	}
+	return o, nil
+}
+
+// Get retries opening the content, not reading it.
+func (b *retryBackend) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	e := b.retry.Do(ctx, "get "+name, func() error {
+		var e error
+		rc, e = b.Backend.Get(ctx, name)
+		return e
+	})
+	return rc, e
+}
+
+func (b *retryBackend) Stat(ctx context.Context, name string) (*Object, error) {
+	var o *Object
+	e := b.retry.Do(ctx, "stat "+name, func() error {
+		var e error
+		o, e = b.Backend.Stat(ctx, name)
+		return e
+	})
+	return o, e
+}
+
+func (b *retryBackend) List(ctx context.Context, prefix string) ([]*Object, error) {
+	var objs []*Object
+	e := b.retry.Do(ctx, "list "+prefix, func() error {
+		var e error
+		objs, e = b.Backend.List(ctx, prefix)
+		return e
+	})
+	return objs, e
+}
+
+func (b *retryBackend) Copy(ctx context.Context, src, dst string) (*Object, error) {
+	var o *Object
+	e := b.retry.Do(ctx, "copy "+src, func() error {
+		var e error
+		o, e = b.Backend.Copy(ctx, src, dst)
+		return e
+	})
+	return o, e
+}
+
+func (b *retryBackend) Delete(ctx context.Context, name string) error {
+	return b.retry.Do(ctx, "delete "+name, func() error {
+		return b.Backend.Delete(ctx, name)
+	})
+}
@@ -0,0 +1,135 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetry_Backoff(t *testing.T) {
+	r := Retry{Retries: 10, Initial: 100 * time.Millisecond, Max: time.Second}
+	for n, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		for i := 0; i < 100; i++ {
+			if d := r.Backoff(n); d <= 0 || d > max {
+				t.Fatalf("retry %d: got: %v, want: (0, %v]", n, d, max)
+			}
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	table := []struct {
+		e    error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("403 Forbidden"), false},
+		{ErrNotExist, false},
+		{context.DeadlineExceeded, false},
+		{Retryable(errors.New("503 Service Unavailable")), true},
+		{Final(io.ErrUnexpectedEOF), false},
+		{io.ErrUnexpectedEOF, true},
+		{&net.OpError{Op: "write", Net: "tcp", Err: syscall.ECONNRESET}, true},
+		{fmt.Errorf("put: %w", syscall.EPIPE), true},
+		{errors.New("read tcp 10.0.0.1:443: connection reset by peer"), true},
+	}
+	for _, tt := range table {
+		if got := IsRetryable(tt.e); got != tt.want {
+			t.Errorf("%v: got: %v, want: %v", tt.e, got, tt.want)
+		}
+	}
+}
+
+// flakyBackend fails the first 'failures' uploads with 'err', after reading part of the content,
+// or after storing it if 'stores', as if the response was lost.
+type flakyBackend struct {
+	memBackend
+	failures int
+	err      error
+	stores   bool
+	puts     int
+}
+
+func (f *flakyBackend) Put(ctx context.Context, name string, r io.ReadSeeker, opts PutOptions) (*Object, error) {
+	f.puts++
+	if f.puts <= f.failures {
+		if f.stores {
+			f.memBackend.Put(ctx, name, r, opts)
+		} else {
+			io.CopyN(ioutil.Discard, r, 2)
+		}
+		return nil, f.err
+	}
+	return f.memBackend.Put(ctx, name, r, opts)
+}
+
+func TestWithRetry(t *testing.T) {
+	retry := Retry{Retries: 2, Initial: time.Millisecond, Max: time.Millisecond}
+	table := []struct {
+		failures int
+		err      error
+		puts     int
+		ok       bool
+	}{
+		{0, nil, 1, true},
+		{2, Retryable(errors.New("503")), 3, true},
+		{3, Retryable(errors.New("503")), 3, false},
+		{1, errors.New("403"), 1, false},
+	}
+	for _, tt := range table {
+		f := &flakyBackend{memBackend: memBackend{objects: make(map[string][]byte)}, failures: tt.failures, err: tt.err}
+		b := WithRetry(f, retry)
+		_, e := b.Put(context.Background(), "geth.zip", strings.NewReader("geth"), PutOptions{})
+		if (e == nil) != tt.ok || f.puts != tt.puts {
+			t.Errorf("%d failures of %v: got: %v after %d puts, want ok: %v after %d", tt.failures, tt.err, e, f.puts, tt.ok, tt.puts)
+		}
+		// The content is read from the start again after a failure.
+		if tt.ok && string(f.objects["geth.zip"]) != "geth" {
+			t.Errorf("%d failures: got: %q", tt.failures, f.objects["geth.zip"])
+		}
+	}
+
+	// A failed attempt which stored the content satisfies the retries of a conditional upload, unlike other content.
+	table2 := []struct {
+		existing string
+		opts     PutOptions
+		ok       bool
+	}{
+		{"", PutOptions{NoClobber: true}, true},
+		{"", PutOptions{IfGenerationMatch: 1}, false},
+		{"geth1", PutOptions{IfGenerationMatch: 1}, true},
+		{"geth", PutOptions{NoClobber: true}, false},
+	}
+	for _, tt := range table2 {
+		f := &flakyBackend{memBackend: memBackend{objects: make(map[string][]byte)}, failures: 1, err: Retryable(errors.New("503")), stores: true}
+		if tt.existing != "" {
+			f.write("geth.zip", []byte(tt.existing))
+		}
+		_, e := WithRetry(f, retry).Put(context.Background(), "geth.zip", strings.NewReader("geth2"), tt.opts)
+		if (e == nil) != tt.ok {
+			t.Errorf("%q %+v: got: %v, want ok: %v", tt.existing, tt.opts, e, tt.ok)
+		}
+	}
+	// Another content of the same size isn't taken for it.
+	f := &flakyBackend{memBackend: memBackend{objects: make(map[string][]byte)}, failures: 1, err: Retryable(errors.New("503"))}
+	f.write("geth.zip", []byte("geth3"))
+	if _, e := WithRetry(f, retry).Put(context.Background(), "geth.zip", strings.NewReader("geth2"), PutOptions{NoClobber: true}); e != ErrExist {
+		t.Errorf("got: %v, want: %v", e, ErrExist)
+	}
+
+	// A done context stops retrying.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f = &flakyBackend{memBackend: memBackend{objects: make(map[string][]byte)}, failures: 3, err: Retryable(errors.New("503"))}
+	if _, e := WithRetry(f, retry).Put(ctx, "geth.zip", strings.NewReader("geth"), PutOptions{}); e == nil || f.puts != 1 {
+		t.Errorf("got: %v after %d puts, want error after 1", e, f.puts)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ETCDEVTeam/janus/gitvv"
+)
+
+// Export formats.
+const (
+	exportSh           = "sh"            // export NAME='value'
+	exportPowerShell   = "powershell"    // $env:NAME = 'value'
+	exportCmd          = "cmd"           // set "NAME=value"
+	exportDotenv       = "dotenv"        // NAME="value"
+	exportGitHubOutput = "github-output" // NAME=value, or a heredoc for multi-line values, appended to $GITHUB_OUTPUT
+	exportGitLab       = "gitlab"        // NAME=value, for a dotenv report artifact
+)
+
+var exportFormats = []string{exportSh, exportPowerShell, exportCmd, exportDotenv, exportGitHubOutput, exportGitLab}
+
+// exportVar is a variable assignment to export.
+type exportVar struct {
+	name, value string
+}
+
+// getExportVars gets the variables to export for HEAD in 'dir', with VERSION as per 'format'
+// and VERSION_BASE as per 'baseFormat', eg. v%M.%m.x
+func getExportVars(format, baseFormat, dir string, config *gitvv.Config) ([]exportVar, error) {
+	v, e := gitvv.GetVersionInfo(dir, config)
+	if e != nil {
+		return nil, e
+	}
+	return []exportVar{
+		{"VERSION", gitvv.GetVersionWithConfig(format, dir, config)},
+		{"VERSION_BASE", gitvv.GetVersionWithConfig(baseFormat, dir, config)},
+		{"TAG_OR_NIGHTLY", gitvv.GetVersionWithConfig("TAG_OR_NIGHTLY", dir, config)},
+		{"SEMVER", v.Version},
+		{"TAG", v.Tag},
+		{"MAJOR", v.Major},
+		{"MINOR", v.Minor},
+		{"PATCH", v.Patch},
+		{"COMMIT_COUNT", strconv.Itoa(v.CommitCount)},
+		{"COMMIT", v.Commit},
+		{"COMMIT_SHORT", gitvv.GetVersionWithConfig("%S", dir, config)},
+		{"DATE", v.Date},
+		{"DIRTY", strconv.FormatBool(v.Dirty)},
+		{"BRANCH", v.Branch},
+		{"TAG_SUBJECT", v.TagSubject},
+		{"TAG_MESSAGE", v.TagMessage},
+		{"TAGGER_NAME", v.TaggerName},
+		{"TAGGER_EMAIL", v.TaggerEmail},
+		{"SUBJECT", v.Subject},
+		{"AUTHOR_NAME", v.AuthorName},
+		{"AUTHOR_EMAIL", v.AuthorEmail},
+		{"COMMITTER_NAME", v.CommitterName},
+		{"COMMITTER_EMAIL", v.CommitterEmail},
+	}, nil
+}
+
+// writeExport writes the variables as assignments in the export format.
+func writeExport(w io.Writer, format string, vars []exportVar) error {
+	for _, v := range vars {
+		var s string
+		switch format {
+		case exportSh:
+			s = "export " + v.name + "='" + strings.Replace(v.value, "'", `'\''`, -1) + "'"
+		case exportPowerShell:
+			s = "$env:" + v.name + " = '" + powerShellQuotes.Replace(v.value) + "'"
+		case exportCmd:
+			// Batch files can't quote double quotes or newlines, and expand %.
+			value := strings.Replace(singleLine(v.value), `"`, "'", -1)
+			s = `set "` + v.name + "=" + strings.Replace(value, "%", "%%", -1) + `"`
+		case exportDotenv:
+			r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`)
+			s = v.name + `="` + r.Replace(v.value) + `"`
+		case exportGitHubOutput:
+			if !strings.Contains(v.value, "\n") {
+				s = v.name + "=" + v.value
+				break
+			}
+			delim, e := heredocDelimiter(v.value)
+			if e != nil {
+				return e
+			}
+			s = v.name + "<<" + delim + "\n" + v.value + "\n" + delim
+		case exportGitLab:
+			// Dotenv reports don't support multi-line values.
+			s = v.name + "=" + singleLine(v.value)
+		default:
+			return fmt.Errorf("unknown export format '%s', want one of: %s", format, strings.Join(exportFormats, ", "))
+		}
+		if _, e := fmt.Fprintln(w, s); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// powerShellQuotes doubles the single quotes of a PowerShell string, which include the typographic ones, eg. ’
+var powerShellQuotes = strings.NewReplacer("'", "''", "\u2018", "\u2018\u2018", "\u2019", "\u2019\u2019", "\u201a", "\u201a\u201a", "\u201b", "\u201b\u201b")
+
+// singleLine collapses whitespace in 's', including newlines, to single spaces.
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(strings.Replace(s, "\r", "", -1)), " ")
+}
+
+// heredocDelimiter gets a random delimiter not found in 'value'.
+func heredocDelimiter(value string) (string, error) {
+	for {
+		b := make([]byte, 8)
+		if _, e := rand.Read(b); e != nil {
+			return "", e
+		}
+		d := "EOF_" + hex.EncodeToString(b)
+		if !strings.Contains(value, d) {
+			return d, nil
+		}
+	}
+}
+
+// exportVersion writes the variables to 'path' if set, or the CI's file for the format, appending, else to 'w'.
+func exportVersion(w io.Writer, format, path string, vars []exportVar) error {
+	known := false
+	for _, f := range exportFormats {
+		known = known || f == format
+	}
+	if !known {
+		return fmt.Errorf("unknown export format '%s', want one of: %s", format, strings.Join(exportFormats, ", "))
+	}
+	if path == "" && format == exportGitHubOutput {
+		path = os.Getenv("GITHUB_OUTPUT")
+	}
+	if path == "" {
+		return writeExport(w, format, vars)
+	}
+	f, e := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if e != nil {
+		return e
+	}
+	if e := writeExport(f, format, vars); e != nil {
+		f.Close()
+		return e
+	}
+	return f.Close()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteExport(t *testing.T) {
+	table := []struct {
+		format, value, want string
+	}{
+		{exportSh, "v3.5.0", "export V='v3.5.0'\n"},
+		{exportSh, "it's $HOME `id`", `export V='it'\''s $HOME ` + "`id`'\n"},
+		{exportSh, "a\nb", "export V='a\nb'\n"},
+		{exportPowerShell, "v3.5.0", "$env:V = 'v3.5.0'\n"},
+		{exportPowerShell, "it's $env:PATH", "$env:V = 'it''s $env:PATH'\n"},
+		{exportPowerShell, "it’s ‘x‛ ‚", "$env:V = 'it’’s ‘‘x‛‛ ‚‚'\n"},
+		{exportPowerShell, `say "hi"`, `$env:V = 'say "hi"'` + "\n"},
+		{exportCmd, "v3.5.0", `set "V=v3.5.0"` + "\n"},
+		{exportCmd, `50% "done"`, `set "V=50%% 'done'"` + "\n"},
+		{exportCmd, "a\r\nb", `set "V=a b"` + "\n"},
+		{exportDotenv, "v3.5.0", `V="v3.5.0"` + "\n"},
+		{exportDotenv, `say "hi" $HOME \n`, `V="say \"hi\" \$HOME \\n"` + "\n"},
+		{exportDotenv, "a\nb", `V="a\nb"` + "\n"},
+		{exportGitHubOutput, "v3.5.0", "V=v3.5.0\n"},
+		{exportGitHubOutput, `it's "$x"`, `V=it's "$x"` + "\n"},
+		{exportGitLab, "v3.5.0", "V=v3.5.0\n"},
+		{exportGitLab, "a\n  b", "V=a b\n"},
+	}
+	for _, tt := range table {
+		var b bytes.Buffer
+		if e := writeExport(&b, tt.format, []exportVar{{"V", tt.value}}); e != nil {
+			t.Fatal(e)
+		}
+		if got := b.String(); got != tt.want {
+			t.Errorf("%s %q: got: %q, want: %q", tt.format, tt.value, got, tt.want)
+		}
+	}
+
+	// Multi-line GitHub outputs are heredocs, with a delimiter not in the value.
+	var b bytes.Buffer
+	if e := writeExport(&b, exportGitHubOutput, []exportVar{{"V", "a\nEOF\nb"}}); e != nil {
+		t.Fatal(e)
+	}
+	lines := strings.Split(b.String(), "\n")
+	if len(lines) != 6 || !strings.HasPrefix(lines[0], "V<<EOF_") || lines[4] != strings.TrimPrefix(lines[0], "V<<") ||
+		strings.Join(lines[1:4], "\n") != "a\nEOF\nb" {
+		t.Errorf("got: %q", b.String())
+	}
+
+	if e := writeExport(&b, "fish", []exportVar{{"V", "v3.5.0"}}); e == nil {
+		t.Error("want error for an unknown format")
+	}
+}
@@ -1,181 +1,351 @@
 package gcp
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
-	"io/ioutil"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"strconv"
+	"net/http"
 	"strings"
 
 	"cloud.google.com/go/storage"
+	"github.com/ETCDEVTeam/janus/deploy"
+	gax "github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
-var decryptedKeyFileName = "./" + strconv.Itoa(os.Getpid()) + "-gcloud.json"
+func init() {
+	deploy.Register(deploy.SchemeGCS, Open)
+}
 
 // Use: go run gcs-deploy.go -bucket builds.etcdevteam.com -object go-ethereum/$(cat version-base.txt)/geth-classic-$TRAVIS_OS_NAME-$(cat version-app.txt).zip -file geth-classic-linux-14.0.zip -key ./.gcloud.key
 
-// writeToGCP writes (uploads) a file or files to GCP Storage.
-// 'object' is the path at which 'file' will be written,
-// 'bucket' is the parent directory in which the object will be written.
-func writeToGCP(client *storage.Client, bucket, object, file string) error {
+// Backend is a GCP Storage bucket.
+type Backend struct {
+	client    *storage.Client
+	bucket    *storage.BucketHandle
+	chunkSize int64
+	retry     deploy.Retry
+	cleanup   func()
+}
 
-	ctx := context.Background()
-	// [START upload_file]
-	f, err := os.Open(file)
-	if err != nil {
-		return err
+// Open opens the GCP Storage bucket of 'l' using the service account JSON key of 'opts',
+// which may be encrypted, see deploy.DecryptKey.
+// Uploads are retried by the library as per 'opts.Retry', see writeToGCP.
+func Open(ctx context.Context, l deploy.Location, opts deploy.Options) (deploy.Backend, error) {
+	if opts.Key == "" {
+		return nil, errors.New("gs:// requires a service account key")
+	}
+	key, cleanup, e := deploy.DecryptKey(opts.Key, opts.GPG)
+	if e != nil {
+		return nil, e
+	}
+	client, e := storage.NewClient(ctx, option.WithServiceAccountFile(key))
+	if e != nil {
+		cleanup()
+		return nil, e
+	}
+	retry := deploy.DefaultRetry
+	if opts.Retry != nil {
+		retry = *opts.Retry
 	}
-	defer f.Close()
+	return &Backend{client: client, bucket: client.Bucket(l.Bucket), chunkSize: opts.ChunkSize, retry: retry, cleanup: cleanup}, nil
+}
 
-	// Write object to storage, ensuring basename for file/object if exists.
-	wc := client.Bucket(bucket).Object(object).NewWriter(ctx)
-	if _, err = io.Copy(wc, f); err != nil {
-		return err
+func objectFromAttrs(a *storage.ObjectAttrs) *deploy.Object {
+	return &deploy.Object{
+		Name:        a.Name,
+		Size:        a.Size,
+		ContentType: a.ContentType,
+		Updated:     a.Updated,
+		Generation:  a.Generation,
 	}
-	if err := wc.Close(); err != nil {
-		return err
-	}
-	// [END upload_file]
-	fmt.Printf(`Successfully uploaded:
-	bucket: %v
-	object: %v
-	file: %v
-	`, bucket, object, file)
-	return nil
 }
 
-// SendToGCP sends a file or files to Google Cloud Provider storage
-// using a service account JSON key
-func SendToGCP(to, files, key string, gpg bool) error {
+// digests are the checksums GCP Storage keeps of objects.
+type digests struct {
+	crc32c uint32
+	md5    []byte
+}
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// digester computes the digests of the content written to it.
+type digester struct {
+	crc32c hash.Hash32
+	md5    hash.Hash
+}
+
+func newDigester() *digester {
+	return &digester{crc32c: crc32.New(crc32c), md5: md5.New()}
+}
+
+func (d *digester) Write(p []byte) (int, error) {
+	d.crc32c.Write(p)
+	return d.md5.Write(p)
+}
+
+func (d *digester) digests() digests {
+	return digests{crc32c: d.crc32c.Sum32(), md5: d.md5.Sum(nil)}
+}
+
+// ChecksumError is the mismatch of a checksum of an uploaded object and the local content.
+type ChecksumError struct {
+	Object string
+	Hash   string
+	Local  string
+	Remote string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s: %s mismatch, local: %s, uploaded: %s", e.Object, e.Hash, e.Local, e.Remote)
+}
+
+// verify checks the digests of the uploaded object 'a' against the local digests 'd'.
+// Composite objects have no MD5, so it is only checked if present.
+func verify(a *storage.ObjectAttrs, d digests) error {
+	if a.CRC32C != d.crc32c {
+		return &ChecksumError{Object: a.Name, Hash: "CRC32C", Local: fmt.Sprintf("%08x", d.crc32c), Remote: fmt.Sprintf("%08x", a.CRC32C)}
+	}
+	if len(a.MD5) > 0 && !bytes.Equal(a.MD5, d.md5) {
+		return &ChecksumError{Object: a.Name, Hash: "MD5", Local: hex.EncodeToString(d.md5), Remote: hex.EncodeToString(a.MD5)}
+	}
+	return nil
+}
 
-	to = filepath.Clean(to)
-	files = filepath.Clean(files)
-	key = filepath.Clean(key)
+// conditions are the preconditions of an upload, or nil.
+func conditions(opts deploy.PutOptions) *storage.Conditions {
+	switch {
+	case opts.NoClobber:
+		return &storage.Conditions{DoesNotExist: true}
+	case opts.IfGenerationMatch != 0:
+		return &storage.Conditions{GenerationMatch: opts.IfGenerationMatch}
+	}
+	return nil
+}
 
-	// Ensure key file exists.
-	if _, e := os.Stat(key); e != nil {
+// preconditionError maps the failure of the preconditions of an upload to deploy.ErrExist or deploy.ErrGenerationMismatch.
+func preconditionError(e error, opts deploy.PutOptions) error {
+	var ge *googleapi.Error
+	if !errors.As(e, &ge) || ge.Code != http.StatusPreconditionFailed {
 		return e
 	}
+	if opts.NoClobber {
+		return deploy.ErrExist
+	}
+	return deploy.ErrGenerationMismatch
+}
 
-	var arbitraryMap = make(map[string]interface{})
+// retrier decides the retries of the library for an upload, at most 'max' of the errors it retries by default.
+type retrier struct {
+	max     int
+	retries int
+}
 
-	// Read key file
-	bRead, eRead := ioutil.ReadFile(key)
-	if eRead != nil {
-		return eRead
+func (r *retrier) shouldRetry(e error) bool {
+	if r.retries >= r.max || !storage.ShouldRetry(e) {
+		return false
 	}
+	r.retries++
+	return true
+}
 
-	// Attempt to unmarshal key file, checks for encryption
-	e := json.Unmarshal(bRead, &arbitraryMap)
-	if e != nil {
-		fmt.Println("key is possibly encryped, attempting to decrypt with $GCP_PASSWD")
+// retryOptions configures the retries of the library as per 'r', counted by 'rt', none if it has no retries.
+func retryOptions(r deploy.Retry, rt *retrier) []storage.RetryOption {
+	if r.Retries <= 0 {
+		return []storage.RetryOption{storage.WithPolicy(storage.RetryNever)}
+	}
+	rt.max = r.Retries
+	// Uploads aren't idempotent without preconditions, so the library doesn't retry them by default,
+	// but uploading the same file again is.
+	return []storage.RetryOption{
+		storage.WithPolicy(storage.RetryAlways),
+		storage.WithBackoff(gax.Backoff{Initial: r.Initial, Max: r.Max, Multiplier: 2}),
+		storage.WithErrorFunc(rt.shouldRetry),
+	}
+}
 
-		passwd := os.Getenv("GCP_PASSWD")
-		if passwd == "" {
-			return errors.New("env GCP_PASSWD not set, cannot decrypt")
-		}
-		// Assume reading for decoding error is it's encrypted... attempt to decrypt
-		if gpg {
-			// use pipe to securly provide password
-			cmd := exec.Command("gpg", "--batch", "--passphrase-fd", "0", "--decrypt", "--output", decryptedKeyFileName, key)
-			writer, err := cmd.StdinPipe()
-			if err != nil {
-				return err
-			}
-			go func() {
-				defer writer.Close()
-				io.WriteString(writer, passwd)
-			}()
-			err = cmd.Run()
-			if err != nil {
-				return err
-			}
-		} else {
-			if decryptError := exec.Command("openssl", "aes-256-cbc", "-pass", "env:GCP_PASSWD", "-in", key, "-out", decryptedKeyFileName, "-d").Run(); decryptError != nil {
-				return decryptError
-			}
+// writeToGCP writes (uploads) the content of 'r' to GCP Storage at 'object'.
+// With a 'chunkSize' the upload is a resumable upload session sending chunks of that size,
+// retried on transient errors as per 'retry', so a failure doesn't restart the upload. 0 uses the library default.
+// The retries are counted over the whole upload.
+// The CRC32C and MD5 of the content are sent for the server to reject corrupted uploads, and checked
+// against the stored object, see ChecksumError.
+// The preconditions of 'opts' are checked by the server, so uploads never replace objects they shouldn't.
+func writeToGCP(ctx context.Context, obj *storage.ObjectHandle, r io.ReadSeeker, opts deploy.PutOptions, chunkSize int64, retry deploy.Retry) (*storage.ObjectAttrs, error) {
+	// The digests must be sent before the content.
+	d := newDigester()
+	if _, err := io.Copy(d, r); err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	sent := d.digests()
+
+	// Canceling the context aborts the upload, which Close would complete.
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rt := &retrier{}
+	unconditional := obj.Retryer(retryOptions(retry, rt)...)
+	obj = unconditional
+	if c := conditions(opts); c != nil {
+		obj = obj.If(*c)
+	}
+	// Write object to storage, ensuring basename for file/object if exists.
+	wc := obj.NewWriter(ctx)
+	wc.ContentType = opts.ContentType
+	wc.CRC32C = sent.crc32c
+	wc.SendCRC32C = true
+	wc.MD5 = sent.md5
+	switch {
+	case chunkSize > 0:
+		wc.ChunkSize = int(chunkSize)
+	case chunkSize < 0:
+		wc.ChunkSize = 0
+	}
+	// Digest what is streamed too, in case the file changed since.
+	streamed := newDigester()
+	if _, err := io.Copy(wc, io.TeeReader(r, streamed)); err != nil {
+		cancel()
+		wc.Close()
+		return uploadedBefore(parent, unconditional, preconditionError(err, opts), rt, sent)
+	}
+	if err := wc.Close(); err != nil {
+		return uploadedBefore(parent, unconditional, preconditionError(err, opts), rt, sent)
+	}
+	if err := verify(wc.Attrs(), streamed.digests()); err != nil {
+		// Don't leave the corrupted object to be downloaded, unless it was replaced since.
+		if e := obj.If(storage.Conditions{GenerationMatch: wc.Attrs().Generation}).Delete(context.Background()); e != nil {
+			return nil, fmt.Errorf("%v, deleting the object failed: %v", err, e)
 		}
+		return nil, err
+	}
+	return wc.Attrs(), nil
+}
 
-		fmt.Println("decrypted key file to: ", decryptedKeyFileName)
-		key = decryptedKeyFileName
-
-		// Only remove *unecrypted* key file
-		defer func() {
-			key = filepath.Clean(key)
-			p, pe := filepath.Abs(key)
-			if pe != nil {
-				fmt.Println(pe)
-			} else {
-				key = p
-			}
-
-			fmt.Printf(`
-				removing key: %v
-				`, key)
-			if errRm := os.Remove(key); errRm != nil {
-				fmt.Println(errRm)
-			}
-		}()
-	}
-
-	ctx := context.Background()
-	client, err := storage.NewClient(ctx, option.WithServiceAccountFile(key))
+// uploadedBefore gets the object of 'obj' if the upload failed its preconditions with 'e' after retries
+// and the object has the digests 'd' of the content, since an attempt which failed may have stored it,
+// eg. if the response was lost. Else it returns 'e'.
+func uploadedBefore(ctx context.Context, obj *storage.ObjectHandle, e error, rt *retrier, d digests) (*storage.ObjectAttrs, error) {
+	if rt.retries == 0 || e != deploy.ErrExist && e != deploy.ErrGenerationMismatch {
+		return nil, e
+	}
+	a, err := obj.Attrs(ctx)
 	if err != nil {
-		return err
+		fmt.Printf("put %s: %v\n", obj.ObjectName(), err)
+		return nil, e
+	}
+	if verify(a, d) != nil || len(a.MD5) == 0 {
+		return nil, e
 	}
+	fmt.Printf("put %s: %v, but with the content uploaded, stored by a failed attempt\n", obj.ObjectName(), e)
+	return a, nil
+}
+
+// retryable marks transient errors of the API as retryable, see deploy.IsRetryable.
+func retryable(e error) error {
+	if ge, ok := e.(*googleapi.Error); ok && (ge.Code == http.StatusTooManyRequests || ge.Code >= 500) {
+		return deploy.Retryable(e)
+	}
+	return e
+}
 
-	// Use glob to get matching file paths.
-	globs, e := filepath.Glob(files)
+// Put uploads the content of 'r' to 'name'.
+// The library retries uploads, so their failures aren't retried again, see deploy.WithRetry,
+// and checks the object of a retried upload failing its preconditions, see uploadedBefore.
+func (b *Backend) Put(ctx context.Context, name string, r io.ReadSeeker, opts deploy.PutOptions) (*deploy.Object, error) {
+	a, e := writeToGCP(ctx, b.bucket.Object(name), r, opts, b.chunkSize, b.retry)
+	if e == deploy.ErrExist || e == deploy.ErrGenerationMismatch {
+		return nil, e
+	}
 	if e != nil {
-		return e
+		return nil, deploy.Final(e)
 	}
-	// Ensure there is something to upload
-	if len(globs) == 0 {
-		return errors.New("no files matching '-to' pattern were found")
+	return objectFromAttrs(a), nil
+}
+
+// Get gets the content of 'name'.
+func (b *Backend) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	r, e := b.bucket.Object(name).NewReader(ctx)
+	if e == storage.ErrObjectNotExist {
+		return nil, deploy.ErrNotExist
 	}
-	// Upload each file
-	for _, f := range globs {
-		fi, e := os.Stat(f)
-		if e != nil {
-			return e
-		}
-		if fi.IsDir() {
-			fmt.Printf("%s is a directory, continuing", fi.Name())
-			continue
+	if e != nil {
+		return nil, retryable(e)
+	}
+	return r, nil
+}
+
+// Stat gets the metadata of 'name'.
+func (b *Backend) Stat(ctx context.Context, name string) (*deploy.Object, error) {
+	a, e := b.bucket.Object(name).Attrs(ctx)
+	if e == storage.ErrObjectNotExist {
+		return nil, deploy.ErrNotExist
+	}
+	if e != nil {
+		return nil, retryable(e)
+	}
+	return objectFromAttrs(a), nil
+}
+
+// List lists the objects with names starting with 'prefix'.
+func (b *Backend) List(ctx context.Context, prefix string) ([]*deploy.Object, error) {
+	var objs []*deploy.Object
+	it := b.bucket.Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		a, e := it.Next()
+		if e == iterator.Done {
+			return objs, nil
 		}
-		// eg.
-		// to: builds.etcdevteam.com/go-ethereum/3.5.x
-		// file: ./dist/geth.zip
-		//
-		// Set bucket as first in separator-split path
-		// eg. builds.etcdevteam.com
-		bucket := strings.Split(filepath.ToSlash(to), "/")[0]
-
-		// Get relative path of 'to' based on 'bucket'
-		// eg. go-ethereum/3.5.x
-		object, relError := filepath.Rel(bucket, to)
-		if relError != nil {
-			return relError
+		if e != nil {
+			return nil, retryable(e)
 		}
+		objs = append(objs, objectFromAttrs(a))
+	}
+}
+
+// Copy copies 'src' to 'dst' server-side.
+func (b *Backend) Copy(ctx context.Context, src, dst string) (*deploy.Object, error) {
+	a, e := b.bucket.Object(dst).CopierFrom(b.bucket.Object(src)).Run(ctx)
+	if e == storage.ErrObjectNotExist {
+		return nil, deploy.ErrNotExist
+	}
+	if e != nil {
+		return nil, retryable(e)
+	}
+	return objectFromAttrs(a), nil
+}
 
-		// Append file to 'to' path.
-		// eg. go-ethereum/3.5.x/geth.zip
-		deployObject := filepath.Join(object, filepath.Base(f))
-		// Ensure actual '/' [slash]es are used, because Windows will make them '\' [backslashes]
-		// and google won't folder-ize the path
-		deployObject = filepath.ToSlash(deployObject)
+// Delete deletes 'name'.
+func (b *Backend) Delete(ctx context.Context, name string) error {
+	e := b.bucket.Object(name).Delete(ctx)
+	if e == storage.ErrObjectNotExist {
+		return deploy.ErrNotExist
+	}
+	return retryable(e)
+}
 
-		// Send it.
-		if deployError := writeToGCP(client, bucket, deployObject, f); deployError != nil {
-			return deployError
-		}
+// Close closes the client and removes a decrypted key.
+func (b *Backend) Close() error {
+	defer b.cleanup()
+	return b.client.Close()
+}
+
+// SendToGCP sends a file or files to Google Cloud Provider storage
+// using a service account JSON key
+func SendToGCP(to, files, key string, gpg bool) error {
+	if strings.Contains(to, "://") && !strings.HasPrefix(to, deploy.SchemeGCS+"://") {
+		return fmt.Errorf("not a GCP Storage destination: %s", to)
 	}
-	return nil
+	return deploy.Deploy(context.Background(), to, files, deploy.Options{Key: key, GPG: gpg})
 }
@@ -0,0 +1,112 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/ETCDEVTeam/janus/deploy"
+	"google.golang.org/api/googleapi"
+)
+
+func TestVerify(t *testing.T) {
+	d := newDigester()
+	io.Copy(d, strings.NewReader("123456789"))
+	local := d.digests()
+	if local.crc32c != 0xe3069283 {
+		t.Fatalf("crc32c: got: %08x, want: e3069283", local.crc32c)
+	}
+	md5 := append([]byte(nil), local.md5...)
+	corrupt := append([]byte(nil), md5...)
+	corrupt[0] ^= 0xff
+
+	table := []struct {
+		attrs *storage.ObjectAttrs
+		want  string
+	}{
+		{&storage.ObjectAttrs{Name: "geth.zip", CRC32C: 0xe3069283, MD5: md5}, ""},
+		// Composite objects have no MD5.
+		{&storage.ObjectAttrs{Name: "geth.zip", CRC32C: 0xe3069283}, ""},
+		{&storage.ObjectAttrs{Name: "geth.zip", CRC32C: 0xe3069284, MD5: md5}, "geth.zip: CRC32C mismatch, local: e3069283, uploaded: e3069284"},
+		{&storage.ObjectAttrs{Name: "geth.zip", CRC32C: 0xe3069283, MD5: corrupt}, "geth.zip: MD5 mismatch"},
+	}
+	for _, tt := range table {
+		e := verify(tt.attrs, local)
+		if tt.want == "" && e != nil || tt.want != "" && (e == nil || !strings.HasPrefix(e.Error(), tt.want)) {
+			t.Errorf("%+v: got: %v, want: %s", tt.attrs, e, tt.want)
+		}
+	}
+}
+
+func TestPreconditionError(t *testing.T) {
+	failed := &googleapi.Error{Code: 412, Message: "conditionNotMet"}
+	other := errors.New("nope")
+	table := []struct {
+		e    error
+		opts deploy.PutOptions
+		want error
+	}{
+		{failed, deploy.PutOptions{NoClobber: true}, deploy.ErrExist},
+		{fmt.Errorf("upload: %w", failed), deploy.PutOptions{NoClobber: true}, deploy.ErrExist},
+		{failed, deploy.PutOptions{IfGenerationMatch: 1}, deploy.ErrGenerationMismatch},
+		{&googleapi.Error{Code: 503}, deploy.PutOptions{NoClobber: true}, nil},
+		{other, deploy.PutOptions{NoClobber: true}, other},
+	}
+	for _, tt := range table {
+		got := preconditionError(tt.e, tt.opts)
+		if tt.want == nil && got != tt.e || tt.want != nil && got != tt.want {
+			t.Errorf("%v %+v: got: %v, want: %v", tt.e, tt.opts, got, tt.want)
+		}
+	}
+	if c := conditions(deploy.PutOptions{}); c != nil {
+		t.Errorf("got: %+v, want none", c)
+	}
+	if c := conditions(deploy.PutOptions{NoClobber: true}); c == nil || !c.DoesNotExist {
+		t.Errorf("got: %+v, want DoesNotExist", c)
+	}
+	if c := conditions(deploy.PutOptions{IfGenerationMatch: 42}); c == nil || c.GenerationMatch != 42 {
+		t.Errorf("got: %+v, want GenerationMatch 42", c)
+	}
+}
+
+func TestRetrier(t *testing.T) {
+	rt := &retrier{}
+	if opts := retryOptions(deploy.Retry{Retries: 2}, rt); len(opts) != 3 || rt.max != 2 {
+		t.Fatalf("got: %d options, max: %d", len(opts), rt.max)
+	}
+	unavailable := &googleapi.Error{Code: 503}
+	for i, want := range []bool{true, true, false} {
+		if got := rt.shouldRetry(unavailable); got != want {
+			t.Errorf("retry %d: got: %v, want: %v", i, got, want)
+		}
+	}
+	rt = &retrier{max: 2}
+	if rt.shouldRetry(&googleapi.Error{Code: 403}) || rt.shouldRetry(nil) || rt.retries != 0 {
+		t.Errorf("retried an error that isn't transient, %d retries", rt.retries)
+	}
+	if opts := retryOptions(deploy.Retry{}, &retrier{}); len(opts) != 1 {
+		t.Errorf("got: %d options, want RetryNever", len(opts))
+	}
+}
+
+func TestUploadedBefore(t *testing.T) {
+	// Without retries or another error, the object isn't looked up.
+	other := errors.New("nope")
+	table := []struct {
+		e       error
+		retries int
+	}{
+		{deploy.ErrExist, 0},
+		{deploy.ErrGenerationMismatch, 0},
+		{other, 1},
+	}
+	for _, tt := range table {
+		if a, e := uploadedBefore(context.Background(), nil, tt.e, &retrier{retries: tt.retries}, digests{}); a != nil || e != tt.e {
+			t.Errorf("%v after %d retries: got: %v, %v", tt.e, tt.retries, a, e)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package gitvv
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCalVerLayout is used for the calver scheme when no layout is configured.
+const DefaultCalVerLayout = "YYYY.0M.MICRO"
+
+// CalVer layout tokens, as per https://calver.org
+//
+// YYYY - full year, eg. 2006, 2016, 2106
+// YY - short year, eg. 6, 16, 106
+// 0Y - zero-padded year, eg. 06, 16, 106
+//
+// In a layout with a week token, years are the ISO year of the week, eg. 2019.01 for 2018-12-31
+// MM - short month, eg. 1, 2 ... 11, 12
+// 0M - zero-padded month, eg. 01, 02 ... 11, 12
+// WW - short week (ISO), eg. 1, 2, 33, 52
+// 0W - zero-padded week (ISO), eg. 01, 02, 33, 52
+// DD - short day, eg. 1, 2 ... 30, 31
+// 0D - zero-padded day, eg. 01, 02 ... 30, 31
+// MAJOR, MINOR, MICRO - counters, incremented for releases within the same date segments
+var calverTokens = map[string]bool{
+	"YYYY": true, "YY": true, "0Y": true,
+	"MM": true, "0M": true,
+	"WW": true, "0W": true,
+	"DD": true, "0D": true,
+	"MAJOR": false, "MINOR": false, "MICRO": false,
+}
+
+// CalVer is a calendar version, eg. 2018.06.3 for layout YYYY.0M.MICRO
+type CalVer struct {
+	layout []string
+	values []int
+}
+
+// parseCalVerLayout splits and validates a layout, eg. YYYY.0M.MICRO
+func parseCalVerLayout(layout string) ([]string, error) {
+	if layout == "" {
+		return nil, errors.New("empty calver layout")
+	}
+	tokens := strings.Split(layout, ".")
+	seen := make(map[string]bool)
+	for _, t := range tokens {
+		if _, ok := calverTokens[t]; !ok {
+			return nil, fmt.Errorf("unknown calver token '%s' in layout '%s'", t, layout)
+		}
+		if seen[t] {
+			return nil, fmt.Errorf("duplicate calver token '%s' in layout '%s'", t, layout)
+		}
+		seen[t] = true
+	}
+	return tokens, nil
+}
+
+// ParseCalVer parses a calendar version, tolerating a leading 'v', eg. 2018.06.3
+func ParseCalVer(layout, s string) (*CalVer, error) {
+	tokens, e := parseCalVerLayout(layout)
+	if e != nil {
+		return nil, e
+	}
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".")
+	if len(parts) != len(tokens) {
+		return nil, fmt.Errorf("version '%s' does not match layout '%s'", s, layout)
+	}
+	c := &CalVer{layout: tokens, values: make([]int, len(tokens))}
+	for i, p := range parts {
+		if !isNumeric(p) {
+			return nil, fmt.Errorf("invalid calver segment '%s' in '%s'", p, s)
+		}
+		n, e := strconv.Atoi(p)
+		if e != nil {
+			return nil, e
+		}
+		if e := checkCalVerValue(tokens[i], n); e != nil {
+			return nil, fmt.Errorf("invalid calver '%s': %v", s, e)
+		}
+		c.values[i] = n
+	}
+	return c, nil
+}
+
+func checkCalVerValue(token string, n int) error {
+	var min, max int
+	switch token {
+	case "YYYY":
+		min, max = 1000, 9999
+	case "YY", "0Y":
+		// From 2005, since git has no earlier commits, so semver tags like v3.5.1 aren't taken for calver.
+		min, max = 5, 7999
+	case "MM", "0M":
+		min, max = 1, 12
+	case "WW", "0W":
+		min, max = 1, 53
+	case "DD", "0D":
+		min, max = 1, 31
+	default:
+		return nil
+	}
+	if n < min || n > max {
+		return fmt.Errorf("%s must be %d-%d, got %d", token, min, max, n)
+	}
+	return nil
+}
+
+// hasWeekToken is whether a layout has a week token, whose years are ISO years.
+func hasWeekToken(tokens []string) bool {
+	for _, t := range tokens {
+		if t == "WW" || t == "0W" {
+			return true
+		}
+	}
+	return false
+}
+
+// calverDateValue gets the value of a date token for time 't'.
+// With 'isoYear', years are those of the ISO week of 't'.
+func calverDateValue(token string, t time.Time, isoYear bool) int {
+	year := t.Year()
+	if isoYear {
+		year, _ = t.ISOWeek()
+	}
+	switch token {
+	case "YYYY":
+		return year
+	case "YY", "0Y":
+		return year - 2000
+	case "MM", "0M":
+		return int(t.Month())
+	case "WW", "0W":
+		_, w := t.ISOWeek()
+		return w
+	case "DD", "0D":
+		return t.Day()
+	}
+	return 0
+}
+
+// formatCalVerValue formats a segment value as per its token.
+func formatCalVerValue(token string, n int) string {
+	switch token {
+	case "0Y", "0M", "0W", "0D":
+		return fmt.Sprintf("%02d", n)
+	}
+	return strconv.Itoa(n)
+}
+
+// NextCalVer computes the version for a commit at time 't' following version 'last', which may be nil.
+// Date segments are taken from 't'. If they equal those of 'last', the last counter segment is incremented,
+// else counters after the first date segment are reset to 0, and those before it kept. A commit dated before 'last'
+// increments it as one of its date, so versions never decrease. A layout without counters can't version such a commit.
+// eg. for YYYY.0M.MICRO
+// 2018.06.3, 2018-06-20 -> 2018.06.4
+// 2018.06.3, 2018-07-02 -> 2018.07.0
+// 2018.06.3, 2018-05-31 -> 2018.06.4
+// and for MAJOR.YYYY.MICRO
+// 2.2018.3, 2019-01-02 -> 2.2019.0
+func NextCalVer(layout string, last *CalVer, t time.Time) (*CalVer, error) {
+	tokens, e := parseCalVerLayout(layout)
+	if e != nil {
+		return nil, e
+	}
+	next := &CalVer{layout: tokens, values: make([]int, len(tokens))}
+	sameLayout := last != nil && strings.Join(last.layout, ".") == strings.Join(tokens, ".")
+	lastCounter := -1
+	isoYear := hasWeekToken(tokens)
+	dated := false
+	for i, tok := range tokens {
+		if calverTokens[tok] {
+			next.values[i] = calverDateValue(tok, t, isoYear)
+			dated = true
+			continue
+		}
+		lastCounter = i
+		if sameLayout && !dated {
+			next.values[i] = last.values[i]
+		}
+	}
+	if sameLayout && next.Compare(last) <= 0 {
+		if lastCounter < 0 {
+			return nil, fmt.Errorf("calver layout '%s' has no counter, and version %s is of the date of the commit or later, add a MICRO segment", layout, last)
+		}
+		copy(next.values, last.values)
+		next.values[lastCounter]++
+	}
+	return next, nil
+}
+
+// String returns the formatted version, without a 'v' prefix.
+func (c *CalVer) String() string {
+	out := make([]string, len(c.values))
+	for i, n := range c.values {
+		out[i] = formatCalVerValue(c.layout[i], n)
+	}
+	return strings.Join(out, ".")
+}
+
+// Segments returns the formatted segments of the version, eg. [2018 06 3]
+func (c *CalVer) Segments() []string {
+	return strings.Split(c.String(), ".")
+}
+
+// Token returns the formatted value of a layout token, if the layout has it.
+func (c *CalVer) Token(token string) (string, bool) {
+	for i, t := range c.layout {
+		if t == token {
+			return formatCalVerValue(t, c.values[i]), true
+		}
+	}
+	return "", false
+}
+
+// Compare returns -1, 0 or 1 if c is older than, equal to or newer than o.
+// Versions are compared segment-wise.
+func (c *CalVer) Compare(o *CalVer) int {
+	for i := 0; i < len(c.values) && i < len(o.values); i++ {
+		if c.values[i] < o.values[i] {
+			return -1
+		}
+		if c.values[i] > o.values[i] {
+			return 1
+		}
+	}
+	switch {
+	case len(c.values) < len(o.values):
+		return -1
+	case len(c.values) > len(o.values):
+		return 1
+	}
+	return 0
+}
+
+// reCalVerToken matches calver tokens in a format, eg. %{YYYY}, _{0M}
+var reCalVerToken = regexp.MustCompile(`[%_]\{([0-9A-Z]+)\}`)
+
+// parseCalVerSegmentsFromTag gets formatted segments of a calver tag, padded to at least
+// 3 segments for %M, %m, %P, or nil if the tag doesn't match the layout.
+func parseCalVerSegmentsFromTag(tag, layout string) []string {
+	c, e := ParseCalVer(layout, tag)
+	if e != nil {
+		log.Println(e)
+		return nil
+	}
+	segs := c.Segments()
+	for len(segs) < 3 {
+		segs = append(segs, "?")
+	}
+	return segs
+}
+
+// getCalVerVersion gets the calver of HEAD, which is the last tag if HEAD is on it,
+// else the next version by HEAD commit date.
+func getCalVerVersion(lastTag, commitCount, dir, layout string) (*CalVer, error) {
+	var last *CalVer
+	if lastTag != "" {
+		c, e := ParseCalVer(layout, lastTag)
+		if e != nil {
+			// Not a calver tag, eg. from before switching schemes.
+			log.Println(e)
+		} else if commitCount == "0" {
+			return c, nil
+		} else {
+			last = c
+		}
+	}
+	t, e := getHEADTime(dir)
+	if e != nil {
+		return nil, e
+	}
+	return NextCalVer(layout, last, t)
+}
+
+// replaceCalVerTokens interpolates %{TOKEN} calver tokens in 'format'.
+// Using the semver scheme, only date tokens are available, taken from the HEAD commit date.
+func replaceCalVerTokens(format, lastTag, commitCount, dir string, config *Config) string {
+	var c *CalVer
+	if config.isCalVer() {
+		v, e := getCalVerVersion(lastTag, commitCount, dir, config.calverLayout())
+		if e != nil {
+			log.Println(e)
+		}
+		c = v
+	}
+	var t *time.Time
+	isoYear := strings.Contains(format, "{WW}") || strings.Contains(format, "{0W}")
+	return reCalVerToken.ReplaceAllStringFunc(format, func(m string) string {
+		token := reCalVerToken.FindStringSubmatch(m)[1]
+		isDate, known := calverTokens[token]
+		if !known {
+			return m
+		}
+		if c != nil {
+			if v, ok := c.Token(token); ok {
+				return v
+			}
+		}
+		if !isDate {
+			return "?"
+		}
+		if t == nil {
+			ht, e := getHEADTime(dir)
+			if e != nil {
+				log.Println(e)
+				return "?"
+			}
+			t = &ht
+		}
+		return formatCalVerValue(token, calverDateValue(token, *t, isoYear))
+	})
+}
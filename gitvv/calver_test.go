@@ -0,0 +1,124 @@
+package gitvv
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCalVer(t *testing.T) {
+	table := []struct {
+		layout string
+		s      string
+		want   string
+		ok     bool
+	}{
+		{"YYYY.0M.MICRO", "2018.06.3", "2018.06.3", true},
+		{"YYYY.0M.MICRO", "v2018.06.3", "2018.06.3", true},
+		{"YYYY.MM.MICRO", "2018.06.3", "2018.6.3", true},
+		{"YY.0M.0D", "18.6.1", "18.06.01", true},
+		{"YYYY.0W", "2018.23", "2018.23", true},
+		{"YYYY.0M.MICRO", "2018.13.0", "", false},
+		{"YYYY.0M.MICRO", "2018.06", "", false},
+		{"YYYY.0M.MICRO", "v3.5.1", "", false},
+		{"YY.0M.MICRO", "v3.5.1", "", false},
+		{"0Y.0M.MICRO", "v3.5.1", "", false},
+		{"YYYY.0M.MICRO", "v3.5.0-beta", "", false},
+		{"YYYY.0M.0D", "2018.06.32", "", false},
+		{"YYYY.MONTH", "2018.06", "", false},
+		{"YYYY.YYYY", "2018.2018", "", false},
+	}
+
+	for _, tt := range table {
+		c, e := ParseCalVer(tt.layout, tt.s)
+		if (e == nil) != tt.ok {
+			t.Errorf("layout: %s, s: %s, got error: %v, want ok: %v", tt.layout, tt.s, e, tt.ok)
+			continue
+		}
+		if e == nil && c.String() != tt.want {
+			t.Errorf("layout: %s, s: %s, got: %s, want: %s", tt.layout, tt.s, c, tt.want)
+		}
+	}
+}
+
+func TestNextCalVer(t *testing.T) {
+	june20 := time.Date(2018, time.June, 20, 12, 0, 0, 0, time.UTC)
+	dec31 := time.Date(2018, time.December, 31, 12, 0, 0, 0, time.UTC)
+	table := []struct {
+		layout string
+		last   string
+		t      time.Time
+		want   string
+	}{
+		{"YYYY.0M.MICRO", "2018.06.3", june20, "2018.06.4"},
+		{"YYYY.0M.MICRO", "2018.05.3", june20, "2018.06.0"},
+		{"YYYY.0M.MICRO", "", june20, "2018.06.0"},
+		{"YY.0M.0D", "18.06.19", june20, "18.06.20"},
+		{"YY.0M.0D", "18.06.20", june20, ""},
+		{"YYYY.MINOR.MICRO", "2018.2.7", june20, "2018.2.8"},
+		{"YYYY.MINOR.MICRO", "2017.2.7", june20, "2018.0.0"},
+		{"YYYY.0W.MICRO", "2018.25.1", june20, "2018.25.2"},
+		{"YYYY.0W.MICRO", "2018.52.1", dec31, "2019.01.0"},
+		{"YYYY.0M.MICRO", "2018.11.1", dec31, "2018.12.0"},
+		// Counters before the date segments are kept.
+		{"MAJOR.YYYY.MICRO", "2.2017.3", june20, "2.2018.0"},
+		{"MAJOR.YYYY.MICRO", "2.2018.3", june20, "2.2018.4"},
+		{"MAJOR.YYYY.MICRO", "", june20, "0.2018.0"},
+		// Commits dated before the last version don't go below it.
+		{"YYYY.0M.MICRO", "2018.07.3", june20, "2018.07.4"},
+		{"YYYY.0M.MICRO", "2019.01.0", dec31, "2019.01.1"},
+		{"YY.0M.0D", "18.06.21", june20, ""},
+	}
+
+	for _, tt := range table {
+		var last *CalVer
+		if tt.last != "" {
+			c, e := ParseCalVer(tt.layout, tt.last)
+			if e != nil {
+				t.Fatal(e)
+			}
+			last = c
+		}
+		got, e := NextCalVer(tt.layout, last, tt.t)
+		if tt.want == "" {
+			if e == nil {
+				t.Errorf("layout: %s, last: %s, got: %s, want error", tt.layout, tt.last, got)
+			}
+			continue
+		}
+		if e != nil {
+			t.Fatal(e)
+		}
+		if got.String() != tt.want {
+			t.Errorf("layout: %s, last: %s, got: %s, want: %s", tt.layout, tt.last, got, tt.want)
+		}
+		if last != nil && got.Compare(last) <= 0 {
+			t.Errorf("layout: %s, last: %s, got: %s, which isn't above it", tt.layout, tt.last, got)
+		}
+	}
+}
+
+func TestCalVer_Compare(t *testing.T) {
+	table := []struct {
+		a, b string
+		want int
+	}{
+		{"2018.06.3", "2018.06.3", 0},
+		{"2018.06.3", "2018.06.10", -1},
+		{"2018.10.0", "2018.06.10", 1},
+		{"2017.12.9", "2018.01.0", -1},
+	}
+
+	for _, tt := range table {
+		a, e := ParseCalVer(DefaultCalVerLayout, tt.a)
+		if e != nil {
+			t.Fatal(e)
+		}
+		b, e := ParseCalVer(DefaultCalVerLayout, tt.b)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("a: %s, b: %s, got: %d, want: %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package gitvv
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Counter sources for the trailing number of a channel's pre-release identifier.
+const (
+	CounterCommits   = "commits"   // commit count since last tag
+	CounterBuild     = "build"     // CI build number
+	CounterTimestamp = "timestamp" // HEAD commit time, as UTC YYYYMMDDhhmmss
+)
+
+// Channel maps a branch pattern to a pre-release identifier, eg.
+// master -> 3.6.0-beta.N, develop -> 3.6.0-alpha.N
+type Channel struct {
+	// Branch is a path.Match pattern, eg. master, release/*, *
+	Branch string `json:"branch"`
+	// Pre is the pre-release identifier, eg. beta
+	Pre string `json:"pre"`
+	// Counter is the source of N, one of commits, build, timestamp. Default: commits
+	Counter string `json:"counter"`
+	// Bump is the part of the last tag's version to increment, one of major, minor, patch. Default: minor
+	Bump string `json:"bump"`
+}
+
+// defaultChannel is used when no configured channel matches the current branch.
+// Unlike configured channels it bumps the patch version, eg. 3.5.1 -> 3.5.2-dev.N
+var defaultChannel = Channel{Branch: "*", Pre: "dev", Counter: CounterCommits, Bump: "patch"}
+
+// Environment variables holding the branch name in CI systems, where HEAD is usually detached.
+var branchEnvVars = []string{
+	"TRAVIS_BRANCH",
+	"APPVEYOR_REPO_BRANCH",
+	"CI_COMMIT_REF_NAME", // Gitlab
+	"GITHUB_REF_NAME",
+	"BRANCH_NAME", // Jenkins
+}
+
+// Environment variables holding the build number in CI systems.
+var buildNumberEnvVars = []string{
+	"TRAVIS_BUILD_NUMBER",
+	"APPVEYOR_BUILD_NUMBER",
+	"CI_PIPELINE_IID", // Gitlab
+	"GITHUB_RUN_NUMBER",
+	"BUILD_NUMBER", // Jenkins
+}
+
+// ParseChannel parses a channel rule of the form BRANCH=PRE[:COUNTER[:BUMP]]
+// eg.
+// master=beta
+// develop=alpha:build
+// release/*=rc:commits:patch
+func ParseChannel(s string) (Channel, error) {
+	c := Channel{}
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+		return c, fmt.Errorf("invalid channel '%s', want BRANCH=PRE[:COUNTER[:BUMP]]", s)
+	}
+	c.Branch = kv[0]
+	rest := strings.Split(kv[1], ":")
+	if len(rest) > 3 {
+		return c, fmt.Errorf("invalid channel '%s', want BRANCH=PRE[:COUNTER[:BUMP]]", s)
+	}
+	c.Pre = rest[0]
+	if len(rest) > 1 {
+		c.Counter = rest[1]
+	}
+	if len(rest) > 2 {
+		c.Bump = rest[2]
+	}
+	return c, c.validate()
+}
+
+func (c Channel) validate() error {
+	if _, e := path.Match(c.Branch, ""); e != nil {
+		return fmt.Errorf("invalid branch pattern '%s': %v", c.Branch, e)
+	}
+	for _, id := range strings.Split(c.Pre, ".") {
+		if !isIdentifier(id) {
+			return fmt.Errorf("invalid pre-release identifier '%s'", c.Pre)
+		}
+	}
+	switch c.Counter {
+	case "", CounterCommits, CounterBuild, CounterTimestamp:
+	default:
+		return fmt.Errorf("unknown counter '%s', want %s, %s or %s", c.Counter, CounterCommits, CounterBuild, CounterTimestamp)
+	}
+	switch c.Bump {
+	case "", "major", "minor", "patch":
+	default:
+		return fmt.Errorf("unknown bump '%s', want major, minor or patch", c.Bump)
+	}
+	return nil
+}
+
+// ChannelPres gets the pre-release identifiers of builds of 'channels' and of the default channel, eg. [beta alpha dev]
+func ChannelPres(channels []Channel) []string {
+	var pres []string
+	for _, c := range channels {
+		pres = append(pres, c.Pre)
+	}
+	return append(pres, defaultChannel.Pre)
+}
+
+// matchChannel returns the first channel whose pattern matches branch.
+func matchChannel(channels []Channel, branch string) (Channel, bool) {
+	for _, c := range channels {
+		if ok, _ := path.Match(c.Branch, branch); ok {
+			return c, true
+		}
+	}
+	return Channel{}, false
+}
+
+// channelVersion derives a semver for a build on a channel.
+// 'last' is the version of the last tag (nil if none), 'onTag' whether HEAD is tagged.
+// eg.
+// 3.5.1, on tag       -> 3.5.1
+// 3.5.1, beta, 14     -> 3.6.0-beta.14
+// 3.6.0-rc.5, rc, 2   -> 3.6.0-rc.5.2, as 3.6.0-rc.2 would sort below the tag
+// 3.6.0-rc.1, beta, 2 -> 3.7.0-beta.2, as 3.6.0-beta.2 would sort below the tag
+func channelVersion(last *Semver, onTag bool, c Channel, counter string) (string, error) {
+	if last == nil {
+		last = &Semver{}
+	} else if onTag {
+		return last.String(), nil
+	}
+	pre := strings.Split(c.Pre, ".")
+	base := last
+	if last.IsPreRelease() {
+		switch c := comparePre(pre, last.Pre); {
+		case c < 0:
+			// Bump past the tag's version core.
+			base = &Semver{Major: last.Major, Minor: last.Minor, Patch: last.Patch}
+		case c == 0 && len(last.Pre) >= len(pre):
+			// A build of the tag's pre-release, counted after its identifiers.
+			pre = append([]string(nil), last.Pre...)
+		}
+	}
+	next, e := base.bump(c.Bump)
+	if e != nil {
+		return "", e
+	}
+	if !isNumeric(counter) {
+		return "", fmt.Errorf("counter '%s' is not a number", counter)
+	}
+	// Leading zeroes are invalid for numeric identifiers.
+	counter = strings.TrimLeft(counter, "0")
+	if counter == "" {
+		counter = "0"
+	}
+	next.Pre = append(pre, counter)
+	return next.String(), nil
+}
+
+// comparePre compares the identifiers of a channel with as many leading pre-release identifiers of a tag,
+// eg. beta sorts below rc.1, and rc is the series of rc.1
+func comparePre(ids, tagPre []string) int {
+	for i := 0; i < len(ids) && i < len(tagPre); i++ {
+		if c := comparePreIdentifier(ids[i], tagPre[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// getBranch gets the current branch name, preferring CI environment
+// since CI builds usually check out a detached HEAD.
+// The environment is that of the built repo, so it is ignored for submodules.
+func getBranch(dir string) (string, bool) {
+	for _, k := range branchEnvVars {
+		if b := os.Getenv(k); b != "" {
+			if isSubmodule(dir) {
+				break
+			}
+			return strings.TrimPrefix(b, "refs/heads/"), true
+		}
+	}
+	c, e := exec.Command("git", "-C", dir, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if e != nil {
+		return "", false
+	}
+	b := strings.TrimSpace(string(c))
+	if b == "" || b == "HEAD" {
+		return "", false
+	}
+	return b, true
+}
+
+// isSubmodule reports whether the repo of 'dir' is a submodule of another.
+func isSubmodule(dir string) bool {
+	c, e := exec.Command("git", "-C", dir, "rev-parse", "--show-superproject-working-tree").Output()
+	return e == nil && strings.TrimSpace(string(c)) != ""
+}
+
+// getBuildNumber gets the CI build number from environment.
+func getBuildNumber() (string, bool) {
+	for _, k := range buildNumberEnvVars {
+		if n := os.Getenv(k); isNumeric(n) {
+			return n, true
+		}
+	}
+	return "", false
+}
+
+// getHEADTime gets the committer time of HEAD.
+func getHEADTime(dir string) (time.Time, error) {
+	c, e := exec.Command("git", "-C", dir, "log", "-1", "--format=%ct", "HEAD").Output()
+	if e != nil {
+		return time.Time{}, e
+	}
+	sec, e := strconv.ParseInt(strings.TrimSpace(string(c)), 10, 64)
+	if e != nil {
+		return time.Time{}, e
+	}
+	return time.Unix(sec, 0).UTC(), nil
+}
+
+// getChannelCounter gets the value of a channel's counter source.
+func getChannelCounter(c Channel, commitCount, dir string) (string, error) {
+	switch c.Counter {
+	case CounterBuild:
+		n, ok := getBuildNumber()
+		if ok {
+			return n, nil
+		}
+		log.Println("no CI build number found in environment, using commit count")
+		return commitCount, nil
+	case CounterTimestamp:
+		t, e := getHEADTime(dir)
+		if e != nil {
+			return "", e
+		}
+		return t.Format("20060102150405"), nil
+	case CounterCommits, "":
+		return commitCount, nil
+	}
+	return "", errors.New("unknown counter: " + c.Counter)
+}
+
+// getChannelVersion gets the semver for HEAD using the channel matching the current branch.
+func getChannelVersion(lastTag, commitCount, dir string, channels []Channel) (string, error) {
+	var last *Semver
+	if lastTag != "" {
+		v, e := ParseSemver(lastTag)
+		if e != nil {
+			return "", fmt.Errorf("tag '%s' is not semver: %v", lastTag, e)
+		}
+		last = v
+	}
+
+	c := defaultChannel
+	if branch, ok := getBranch(dir); ok {
+		if m, ok := matchChannel(channels, branch); ok {
+			c = m
+		}
+	}
+
+	counter, e := getChannelCounter(c, commitCount, dir)
+	if e != nil {
+		return "", e
+	}
+	return channelVersion(last, lastTag != "" && commitCount == "0", c, counter)
+}
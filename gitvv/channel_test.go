@@ -0,0 +1,146 @@
+package gitvv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseChannel(t *testing.T) {
+	table := []struct {
+		s    string
+		want Channel
+		ok   bool
+	}{
+		{"master=beta", Channel{Branch: "master", Pre: "beta"}, true},
+		{"develop=alpha:build", Channel{Branch: "develop", Pre: "alpha", Counter: CounterBuild}, true},
+		{"release/*=rc:commits:patch", Channel{Branch: "release/*", Pre: "rc", Counter: CounterCommits, Bump: "patch"}, true},
+		{"master", Channel{}, false},
+		{"=beta", Channel{}, false},
+		{"master=", Channel{}, false},
+		{"master=be_ta", Channel{}, false},
+		{"master=beta:sometimes", Channel{}, false},
+		{"master=beta:commits:micro", Channel{}, false},
+		{"master=beta:commits:minor:extra", Channel{}, false},
+	}
+
+	for _, tt := range table {
+		got, e := ParseChannel(tt.s)
+		if (e == nil) != tt.ok {
+			t.Errorf("s: %s, got error: %v, want ok: %v", tt.s, e, tt.ok)
+			continue
+		}
+		if e == nil && got != tt.want {
+			t.Errorf("s: %s, got: %v, want: %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func Test_matchChannel(t *testing.T) {
+	channels := []Channel{
+		{Branch: "master", Pre: "beta"},
+		{Branch: "release/*", Pre: "rc"},
+		{Branch: "*", Pre: "alpha"},
+	}
+	table := []struct {
+		branch string
+		want   string
+	}{
+		{"master", "beta"},
+		{"release/3.6", "rc"},
+		{"develop", "alpha"},
+		{"feature/x", ""},
+	}
+
+	for _, tt := range table {
+		got, ok := matchChannel(channels, tt.branch)
+		if ok != (tt.want != "") {
+			t.Errorf("branch: %s, got ok: %v", tt.branch, ok)
+		}
+		if got.Pre != tt.want {
+			t.Errorf("branch: %s, got: %s, want: %s", tt.branch, got.Pre, tt.want)
+		}
+	}
+}
+
+func Test_channelVersion(t *testing.T) {
+	beta := Channel{Pre: "beta"}
+	table := []struct {
+		last    string
+		onTag   bool
+		c       Channel
+		counter string
+		want    string
+	}{
+		{"v3.5.1", true, beta, "0", "3.5.1"},
+		{"v3.5.1", false, beta, "14", "3.6.0-beta.14"},
+		{"v3.5.1", false, Channel{Pre: "alpha"}, "2107", "3.6.0-alpha.2107"},
+		{"v3.5.1", false, defaultChannel, "3", "3.5.2-dev.3"},
+		{"v3.6.0-rc.1", false, Channel{Pre: "rc"}, "2", "3.6.0-rc.1.2"},
+		{"v3.6.0-rc.5", false, Channel{Pre: "rc"}, "1", "3.6.0-rc.5.1"},
+		{"v3.6.0-rc.5", false, Channel{Pre: "rc"}, "5", "3.6.0-rc.5.5"},
+		{"v3.6.0-rc", false, Channel{Pre: "rc.2"}, "1", "3.6.0-rc.2.1"},
+		{"v3.6.0-rc.1", false, beta, "2", "3.7.0-beta.2"},
+		{"v3.6.0-rc.1", false, Channel{Pre: "beta", Bump: "patch"}, "2", "3.6.1-beta.2"},
+		{"v3.6.0-beta.3", false, Channel{Pre: "rc"}, "2", "3.6.0-rc.2"},
+		{"v3.6.0-rc.1", false, defaultChannel, "3", "3.6.1-dev.3"},
+		{"v3.5.1", false, Channel{Pre: "nightly", Counter: CounterTimestamp}, "20180611093000", "3.6.0-nightly.20180611093000"},
+		{"v3.5.1", false, beta, "007", "3.6.0-beta.7"},
+		{"", false, beta, "5", "0.1.0-beta.5"},
+	}
+
+	for _, tt := range table {
+		var last *Semver
+		if tt.last != "" {
+			v, e := ParseSemver(tt.last)
+			if e != nil {
+				t.Fatal(e)
+			}
+			last = v
+		}
+		got, e := channelVersion(last, tt.onTag, tt.c, tt.counter)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if got != tt.want {
+			t.Errorf("last: %s, got: %s, want: %s", tt.last, got, tt.want)
+		}
+		// Builds above a tag sort above it.
+		if v, e := ParseSemver(got); e != nil || !tt.onTag && last != nil && v.Compare(last) <= 0 {
+			t.Errorf("last: %s, got: %s, which sorts at or below it: %v", tt.last, got, e)
+		}
+	}
+}
+
+func Test_getBranch_submodule(t *testing.T) {
+	sub := newTestRepo(t, "commit -q --allow-empty -m one", "checkout -q -b vendor")
+	defer os.RemoveAll(sub)
+	dir := newTestRepo(t, "commit -q --allow-empty -m one", "checkout -q -b develop",
+		"-c protocol.file.allow=always submodule -q add "+sub+" sub")
+	defer os.RemoveAll(dir)
+
+	for _, env := range branchEnvVars {
+		if v, ok := os.LookupEnv(env); ok {
+			os.Unsetenv(env)
+			defer os.Setenv(env, v)
+		}
+	}
+	os.Setenv("TRAVIS_BRANCH", "master")
+	defer os.Unsetenv("TRAVIS_BRANCH")
+
+	// The CI branch is that of the built repo, not of its submodules.
+	if b, ok := getBranch(dir); !ok || b != "master" {
+		t.Errorf("repo: got: %s, %v, want: master", b, ok)
+	}
+	if b, ok := getBranch(filepath.Join(dir, "sub")); !ok || b != "vendor" {
+		t.Errorf("submodule: got: %s, %v, want: vendor", b, ok)
+	}
+}
+
+func TestChannelPres(t *testing.T) {
+	got := ChannelPres([]Channel{{Branch: "master", Pre: "beta"}, {Branch: "release/*", Pre: "rc"}})
+	if strings.Join(got, " ") != "beta rc dev" {
+		t.Errorf("got: %v, want: [beta rc dev]", got)
+	}
+}
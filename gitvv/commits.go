@@ -0,0 +1,49 @@
+package gitvv
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Commit is a commit and its message.
+type Commit struct {
+	Hash    string `json:"hash"`
+	Message string `json:"message"`
+}
+
+// Subject returns the first line of the commit message.
+func (c *Commit) Subject() string {
+	return strings.SplitN(c.Message, "\n", 2)[0]
+}
+
+// ListCommits lists the non-merge commits of 'revRange' in 'dir', newest first, eg. v3.5.0..HEAD.
+// If 'revRange' is empty, it lists the commits since the last tag, or all commits if there are no tags.
+func ListCommits(dir, revRange string) ([]*Commit, error) {
+	if dir == "" {
+		dir = "."
+	}
+	if revRange == "" {
+		revRange = "HEAD"
+		if tag, ok := getLastTag(dir); ok {
+			revRange = tag + "..HEAD"
+		}
+	}
+	c, e := exec.Command("git", "-C", dir, "log", "--no-merges", "--format=%H"+refFieldSep+"%B"+refRecordSep, revRange, "--").Output()
+	if e != nil {
+		return nil, fmt.Errorf("git log %s: %v", revRange, e)
+	}
+	var commits []*Commit
+	for _, rec := range strings.Split(string(c), refRecordSep) {
+		rec = strings.TrimLeft(rec, "\n")
+		if rec == "" {
+			continue
+		}
+		f := strings.SplitN(rec, refFieldSep, 2)
+		if len(f) != 2 {
+			return nil, fmt.Errorf("unexpected git log output: %q", rec)
+		}
+		commits = append(commits, &Commit{Hash: f[0], Message: strings.TrimRight(f[1], "\n")})
+	}
+	return commits, nil
+}
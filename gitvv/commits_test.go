@@ -0,0 +1,33 @@
+package gitvv
+
+import (
+	"os"
+	"testing"
+)
+
+func TestListCommits(t *testing.T) {
+	dir := newTestRepo(t,
+		"commit -q --allow-empty -m feat:_one",
+		"tag v3.5.0",
+		"commit -q --allow-empty -m fix:_two -m Signed-off-by:_Janus",
+		"commit -q --allow-empty -m fix:_three",
+	)
+	defer os.RemoveAll(dir)
+	resetCaches()
+	defer resetCaches()
+
+	commits, e := ListCommits(dir, "")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(commits) != 2 || commits[0].Subject() != "fix:_three" || commits[1].Message != "fix:_two\n\nSigned-off-by:_Janus" || len(commits[1].Hash) != 40 {
+		t.Errorf("unexpected commits since tag: %+v", commits)
+	}
+
+	if commits, e = ListCommits(dir, "HEAD~1"); e != nil || len(commits) != 2 || commits[1].Subject() != "feat:_one" {
+		t.Errorf("unexpected commits of range: %+v, %v", commits, e)
+	}
+	if _, e := ListCommits(dir, "nonexistent..HEAD"); e == nil {
+		t.Error("want error for bad range")
+	}
+}
@@ -0,0 +1,68 @@
+package gitvv
+
+import (
+	"fmt"
+)
+
+// Versioning schemes.
+const (
+	SchemeSemver = "semver" // eg. 3.5.0, default
+	SchemeCalVer = "calver" // eg. 2018.06.3
+)
+
+// Config holds versioning rules which can't be expressed by a format string alone.
+type Config struct {
+	// Scheme is the versioning scheme of tags, one of semver, calver. Default: semver
+	Scheme string `json:"scheme"`
+	// CalVer is the layout of calver tags, eg. YYYY.0M.MICRO. Default: DefaultCalVerLayout
+	CalVer string `json:"calver"`
+	// Channels map branches to pre-release identifiers for %V, first match wins.
+	// Only used for the semver scheme.
+	Channels []Channel `json:"channels"`
+	// Keyring is the path of an armored or binary OpenPGP keyring. If set, only tags
+	// with a valid signature from one of its keys are used for versioning.
+	Keyring string `json:"keyring"`
+	// Untrusted is the handling of tags without a valid signature, one of skip, reject. Default: skip
+	Untrusted string `json:"untrusted"`
+
+	// selected are the tags selected by SelectTag per directory, so they're verified once.
+	selected map[string]selection
+}
+
+// Validate checks the config for invalid rules.
+func (c *Config) Validate() error {
+	switch c.Scheme {
+	case "", SchemeSemver:
+	case SchemeCalVer:
+		if _, e := parseCalVerLayout(c.calverLayout()); e != nil {
+			return e
+		}
+	default:
+		return fmt.Errorf("unknown version scheme '%s', want %s or %s", c.Scheme, SchemeSemver, SchemeCalVer)
+	}
+	switch c.Untrusted {
+	case "", UntrustedSkip, UntrustedReject:
+	default:
+		return fmt.Errorf("unknown untrusted tag handling '%s', want %s or %s", c.Untrusted, UntrustedSkip, UntrustedReject)
+	}
+	if c.Untrusted != "" && c.Keyring == "" {
+		return fmt.Errorf("untrusted tag handling '%s' requires a keyring", c.Untrusted)
+	}
+	for _, ch := range c.Channels {
+		if e := ch.validate(); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func (c *Config) isCalVer() bool {
+	return c != nil && c.Scheme == SchemeCalVer
+}
+
+func (c *Config) calverLayout() string {
+	if c == nil || c.CalVer == "" {
+		return DefaultCalVerLayout
+	}
+	return c.CalVer
+}
@@ -0,0 +1,85 @@
+package gitvv
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxDockerTagLength is the maximum length of a Docker image tag.
+const maxDockerTagLength = 128
+
+var reDockerTagInvalid = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// SanitizeDockerTag makes 's' a valid Docker image tag, eg. feature/tx-pool -> feature-tx-pool
+// or 3.5.0+66 -> 3.5.0-66. Docker tags may only contain [A-Za-z0-9_.-], may not start with
+// '.' or '-' and are at most 128 characters.
+func SanitizeDockerTag(s string) string {
+	s = reDockerTagInvalid.ReplaceAllString(s, "-")
+	s = strings.TrimLeft(s, ".-")
+	if len(s) > maxDockerTagLength {
+		s = s[:maxDockerTagLength]
+	}
+	return s
+}
+
+// GetDockerTags gets the Docker image tags of HEAD in 'dir', using versioning rules from 'config', which may be nil.
+//
+// On a release tag these are the full version, eg. 3.5.1, and the 3.5, 3 and latest tags if it is the highest
+// stable version of all version tags in those lines, so they never move backwards. Pre-releases only get the full version.
+// Above a tag these are the version, eg. 3.6.0-beta.14, and the branch, eg. master.
+func GetDockerTags(dir string, config *Config) ([]string, error) {
+	v, e := GetVersionInfo(dir, config)
+	if e != nil {
+		return nil, e
+	}
+	var tag *Tag
+	if v.Tag != "" && v.CommitCount == 0 {
+		tags, e := ListVersionTags(dir, config)
+		if e != nil {
+			return nil, e
+		}
+		for _, t := range tags {
+			if t.Name == v.Tag {
+				tag = t
+			}
+		}
+		if tag != nil {
+			return releaseDockerTags(tag, tags), nil
+		}
+	}
+
+	dts := []string{SanitizeDockerTag(v.Version)}
+	if b := SanitizeDockerTag(v.Branch); b != "" && b != "latest" && b != dts[0] {
+		dts = append(dts, b)
+	}
+	return dts, nil
+}
+
+// releaseDockerTags gets the Docker image tags of release 'tag' amongst all version 'tags'.
+func releaseDockerTags(tag *Tag, tags []*Tag) []string {
+	dts := []string{SanitizeDockerTag(tag.Version)}
+	if tag.IsPreRelease() {
+		return dts
+	}
+	segs := tag.segments()
+	for n := 2; n >= 1 && n < len(segs); n-- {
+		line := strings.Join(segs[:n], ".")
+		if isHighestStable(tag, tags, line) {
+			dts = append(dts, SanitizeDockerTag(line))
+		}
+	}
+	if isHighestStable(tag, tags, "") {
+		dts = append(dts, "latest")
+	}
+	return dts
+}
+
+// isHighestStable reports whether no stable version tag in 'line' has higher precedence than 'tag'.
+func isHighestStable(tag *Tag, tags []*Tag, line string) bool {
+	for _, t := range tags {
+		if !t.IsPreRelease() && t.InLine(line) && t.Compare(tag) > 0 {
+			return false
+		}
+	}
+	return true
+}
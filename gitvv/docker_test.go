@@ -0,0 +1,67 @@
+package gitvv
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeDockerTag(t *testing.T) {
+	table := []struct {
+		s, want string
+	}{
+		{"3.5.0", "3.5.0"},
+		{"v3.5.0+66-bbb06b1", "v3.5.0-66-bbb06b1"},
+		{"feature/tx-pool", "feature-tx-pool"},
+		{"-.hotfix", "hotfix"},
+		{strings.Repeat("a", 200), strings.Repeat("a", 128)},
+	}
+	for _, tt := range table {
+		if got := SanitizeDockerTag(tt.s); got != tt.want {
+			t.Errorf("s: %q, got: %q, want: %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestGetDockerTags(t *testing.T) {
+	history := []string{
+		"commit -q --allow-empty -m one",
+		"tag v3.5.0",
+		"commit -q --allow-empty -m two",
+		"tag v3.6.0",
+		"commit -q --allow-empty -m three",
+		"tag v4.0.0-rc.1",
+		"checkout -q -b release/3.5 v3.5.0",
+		"commit -q --allow-empty -m four",
+		"tag v3.5.1",
+	}
+	table := []struct {
+		cmds []string
+		want []string
+	}{
+		{[]string{"checkout -q v3.5.1"}, []string{"3.5.1", "3.5"}},
+		{[]string{"checkout -q v3.6.0"}, []string{"3.6.0", "3.6", "3", "latest"}},
+		{[]string{"checkout -q v4.0.0-rc.1"}, []string{"4.0.0-rc.1"}},
+		{[]string{"checkout -q -b feature/tx-pool v3.6.0", "commit -q --allow-empty -m five"}, []string{"3.6.1-dev.1", "feature-tx-pool"}},
+	}
+	for _, env := range branchEnvVars {
+		if v, ok := os.LookupEnv(env); ok {
+			os.Unsetenv(env)
+			defer os.Setenv(env, v)
+		}
+	}
+	for _, tt := range table {
+		dir := newTestRepo(t, append(history, tt.cmds...)...)
+		resetCaches()
+		got, e := GetDockerTags(dir, nil)
+		os.RemoveAll(dir)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got: %v, want: %v", tt.cmds, got, tt.want)
+		}
+	}
+	resetCaches()
+}
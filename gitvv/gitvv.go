@@ -18,8 +18,22 @@ var (
 	cacheCommitCountFromTagName string
 	cacheCommitCount            string
 	cacheHEADHash               string
+	// cacheDir is the directory the caches are filled for.
+	cacheDir string
 )
 
+// useCacheDir clears the caches if they were filled for another directory than 'dir'.
+func useCacheDir(dir string) {
+	if dir == cacheDir {
+		return
+	}
+	cacheLastTagName = ""
+	cacheCommitCountFromTagName = ""
+	cacheCommitCount = ""
+	cacheHEADHash = ""
+	cacheDir = dir
+}
+
 func isHash(s string) bool {
 	// Strip 'g' prefix for SHA1
 	if strings.HasPrefix(s, "g") {
@@ -51,6 +65,7 @@ func getTagIfTagOnHEADCommit(dir string) (string, bool) {
 }
 
 func getCommitCountFrom(fromTag, dir string) string {
+	useCacheDir(dir)
 	if cacheCommitCount != "" && cacheCommitCountFromTagName == fromTag {
 		return cacheCommitCount
 	}
@@ -74,6 +89,7 @@ func getCommitCountFrom(fromTag, dir string) string {
 }
 
 func getHEADHash(length int, dir string) string {
+	useCacheDir(dir)
 	if cacheHEADHash != "" {
 		return cacheHEADHash[:length]
 	}
@@ -93,7 +109,28 @@ func getHEADHash(length int, dir string) string {
 	return cacheHEADHash[:length]
 }
 
+// IsDirty reports whether the work tree has uncommitted changes, including untracked files, as go build's vcs.modified.
+// Changes to 'excludes', eg. a generated file, are ignored.
+func IsDirty(dir string, excludes ...string) (bool, error) {
+	if dir == "" {
+		dir = "."
+	}
+	args := []string{"-C", dir, "status", "--porcelain"}
+	if len(excludes) > 0 {
+		args = append(args, "--", ":/")
+		for _, x := range excludes {
+			args = append(args, ":(exclude)"+x)
+		}
+	}
+	c, e := exec.Command("git", args...).Output()
+	if e != nil {
+		return false, e
+	}
+	return len(strings.TrimSpace(string(c))) > 0, nil
+}
+
 func getLastTag(dir string) (string, bool) {
+	useCacheDir(dir)
 	if cacheLastTagName != "" {
 		return cacheLastTagName, true
 	}
@@ -114,6 +151,33 @@ func getLastTag(dir string) (string, bool) {
 	return cacheLastTagName, true
 }
 
+// getVersionTag gets the tag on HEAD, else the last tag, or "" if none.
+func getVersionTag(dir string) string {
+	tag, _ := getTagIfTagOnHEADCommit(dir)
+	// Is not 0
+	if tag == "" {
+		// Either from init (entire branch) or lastTag
+		tag, _ = getLastTag(dir)
+	}
+	return tag
+}
+
+// getSchemeVersion gets %V, the version of HEAD as per the scheme of 'config'.
+func getSchemeVersion(lastTag, commitCount, dir string, config *Config) (string, error) {
+	if config.isCalVer() {
+		c, e := getCalVerVersion(lastTag, commitCount, dir, config.calverLayout())
+		if e != nil {
+			return "", e
+		}
+		return c.String(), nil
+	}
+	var channels []Channel
+	if config != nil {
+		channels = config.Channels
+	}
+	return getChannelVersion(lastTag, commitCount, dir, channels)
+}
+
 // Assumes using semver format for tags, eg v3.5.0 or 3.4.0
 func parseSemverFromTag(s string) []string {
 	tag := strings.TrimPrefix(s, "v")
@@ -154,6 +218,14 @@ func getB(dir string) (string, bool) {
 	if !exists {
 		return "", false
 	}
+	return getBFromTag(t, dir)
+}
+
+// getBFromTag gets the semi-semver/mod patch number above tag 't'
+func getBFromTag(t, dir string) (string, bool) {
+	if t == "" {
+		return "", false
+	}
 	semvers := parseSemverFromTag(t)
 	if len(semvers) != 3 {
 		return "", false
@@ -190,7 +262,16 @@ func getB(dir string) (string, bool) {
 // %C, _C - commit count since last tag
 // %S, _S - HEAD sha1
 // %B - hybrid patch number [semver_minor_version*100 + commit_count]
+// %V, _V - version derived by scheme, eg. 3.6.0-beta.14 (semver, see Channel) or 2018.06.4 (calver, see NextCalVer)
+// %{TOKEN}, _{TOKEN} - calver layout token of %V, eg. %{YYYY}, %{0M}, %{MICRO}
+// %{name}, _{name} - tag and commit metadata, see MetadataTokens
 func GetVersion(format, dir string) string {
+	return GetVersionWithConfig(format, dir, nil)
+}
+
+// GetVersionWithConfig gets formatted git version using versioning rules from 'config',
+// which may be nil.
+func GetVersionWithConfig(format, dir string, config *Config) string {
 
 	var (
 		lastTag     string
@@ -212,17 +293,18 @@ func GetVersion(format, dir string) string {
 	}
 
 	// Need to get commit count
-	lastTag, _ = getTagIfTagOnHEADCommit(dir)
-	// Is not 0
-	if lastTag == "" {
-		lastTag, _ = getLastTag(dir)
-		// Either from init (entire branch) or lastTag
-
+	lastTag, _, e := SelectTag(dir, config)
+	if e != nil {
+		log.Println(e)
 	}
 
 	commitCount = getCommitCountFrom(lastTag, dir)
 	if lastTag != "" {
-		semvers = parseSemverFromTag(lastTag)
+		if config.isCalVer() {
+			semvers = parseCalVerSegmentsFromTag(lastTag, config.calverLayout())
+		} else {
+			semvers = parseSemverFromTag(lastTag)
+		}
 	}
 
 	// Convention alert:
@@ -236,6 +318,10 @@ func GetVersion(format, dir string) string {
 			format = "v%M.%m.%P-%S"
 		}
 	}
+	// Always valid and ordered semver, eg. 3.5.1 on tag, 3.6.0-beta.14 above.
+	if format == "SEMVER" {
+		format = "%V"
+	}
 
 	sha = getHEADHash(defaultHashLength, dir)
 	if strings.Index(format, "%S") >= 0 {
@@ -249,7 +335,9 @@ func GetVersion(format, dir string) string {
 		}
 	}
 
-	out := format
+	// Braced tokens are interpolated last, so neither their names nor values
+	// are mistaken for short tokens, eg. _m in %{tag_message}
+	out, braced := protectBracedTokens(format)
 
 	if semvers != nil {
 		// -1 to replace indefinitely. Allows maximum user-decision-making.
@@ -268,6 +356,16 @@ func GetVersion(format, dir string) string {
 		out = strings.Replace(out, "_P", "?", -1)
 	}
 
+	if strings.Contains(out, "%V") || strings.Contains(out, "_V") {
+		v, e := getSchemeVersion(lastTag, commitCount, dir, config)
+		if e != nil {
+			log.Println(e)
+			v = "?"
+		}
+		out = strings.Replace(out, "%V", v, -1)
+		out = strings.Replace(out, "_V", v, -1)
+	}
+
 	out = strings.Replace(out, "%C", commitCount, -1)
 	out = strings.Replace(out, "_C", commitCount, -1)
 
@@ -277,6 +375,9 @@ func GetVersion(format, dir string) string {
 	out = re2.ReplaceAllLiteralString(out, sha)
 
 	b, ok := getB(dir)
+	if config != nil && config.Keyring != "" {
+		b, ok = getBFromTag(lastTag, dir)
+	}
 	if ok {
 		out = strings.Replace(out, "%B", b, -1)
 		out = strings.Replace(out, "_B", b, -1)
@@ -285,5 +386,38 @@ func GetVersion(format, dir string) string {
 		out = strings.Replace(out, "_B", "?", -1)
 	}
 
+	if len(braced) > 0 {
+		// Interpolate all at once, NUL-separated, to get metadata from git only once.
+		joined := strings.Join(braced, "\x00")
+		if reCalVerToken.MatchString(joined) {
+			joined = replaceCalVerTokens(joined, lastTag, commitCount, dir, config)
+		}
+		if reMetadataToken.MatchString(joined) {
+			joined = replaceMetadataTokens(joined, lastTag, dir)
+		}
+		out = restoreBracedTokens(out, strings.Split(joined, "\x00"))
+	}
+
 	return out
 }
+
+// reBracedToken matches braced tokens in a format, eg. %{YYYY}, _{subject:file}
+var reBracedToken = regexp.MustCompile(`[%_]\{[^{}]*\}`)
+
+// protectBracedTokens replaces braced tokens with NUL-delimited placeholders.
+func protectBracedTokens(format string) (string, []string) {
+	var tokens []string
+	out := reBracedToken.ReplaceAllStringFunc(format, func(m string) string {
+		tokens = append(tokens, m)
+		return "\x00" + strconv.Itoa(len(tokens)-1) + "\x00"
+	})
+	return out, tokens
+}
+
+// restoreBracedTokens replaces placeholders with their interpolated values.
+func restoreBracedTokens(s string, values []string) string {
+	for i, v := range values {
+		s = strings.Replace(s, "\x00"+strconv.Itoa(i)+"\x00", v, 1)
+	}
+	return s
+}
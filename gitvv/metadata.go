@@ -0,0 +1,177 @@
+package gitvv
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// maxFilenameTokenLength bounds metadata interpolated into file names.
+const maxFilenameTokenLength = 100
+
+// MetadataTokens are the names usable as %{name} in formats.
+// Append :file for a filename-safe value, eg. %{subject:file}
+var MetadataTokens = []string{
+	"tag_subject",     // first line of the annotated tag message
+	"tag_message",     // full annotated tag message, without signature
+	"tagger_name",     // name of the tagger
+	"tagger_email",    // email of the tagger
+	"subject",         // HEAD commit subject
+	"author_name",     // HEAD commit author name
+	"author_email",    // HEAD commit author email
+	"committer_name",  // HEAD commit committer name
+	"committer_email", // HEAD commit committer email
+}
+
+// reMetadataToken matches metadata tokens in a format, eg. %{subject}, _{tagger_name:file}
+var reMetadataToken = regexp.MustCompile(`[%_]\{([a-z_]+)(:file)?\}`)
+
+// reFilenameUnsafe matches runs of characters not safe in file names.
+var reFilenameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// TagMetadata is the annotation of a tag. It is empty for lightweight tags.
+type TagMetadata struct {
+	TagSubject  string `json:"tag_subject"`
+	TagMessage  string `json:"tag_message"`
+	TaggerName  string `json:"tagger_name"`
+	TaggerEmail string `json:"tagger_email"`
+}
+
+// CommitMetadata is the message and authorship of a commit.
+type CommitMetadata struct {
+	Subject        string `json:"subject"`
+	AuthorName     string `json:"author_name"`
+	AuthorEmail    string `json:"author_email"`
+	CommitterName  string `json:"committer_name"`
+	CommitterEmail string `json:"committer_email"`
+}
+
+// SanitizeDisplay makes s safe for display on a single line, removing control characters
+// and collapsing whitespace, eg. "Release\n\t3.5.0" -> "Release 3.5.0"
+func SanitizeDisplay(s string) string {
+	return strings.Join(strings.Fields(stripControl(s, false)), " ")
+}
+
+// SanitizeFilename makes s safe for use in file and object names, replacing
+// runs of characters other than [A-Za-z0-9._-] with '-', eg. "Fix: bad tx (#12)" -> "Fix-bad-tx-12"
+func SanitizeFilename(s string) string {
+	s = reFilenameUnsafe.ReplaceAllString(s, "-")
+	if len(s) > maxFilenameTokenLength {
+		s = s[:maxFilenameTokenLength]
+	}
+	return strings.Trim(s, "-.")
+}
+
+// stripControl removes control characters, optionally keeping newlines and tabs.
+func stripControl(s string, keepNewlines bool) string {
+	return strings.Map(func(r rune) rune {
+		if keepNewlines && (r == '\n' || r == '\t') {
+			return r
+		}
+		if unicode.IsControl(r) {
+			if r == '\n' || r == '\t' || r == '\r' {
+				return ' '
+			}
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// getTagMetadata gets the annotation of 'tag'.
+func getTagMetadata(tag, dir string) (TagMetadata, error) {
+	m := TagMetadata{}
+	if tag == "" {
+		return m, nil
+	}
+	format := strings.Join([]string{
+		"%(objecttype)",
+		"%(taggername)",
+		"%(taggeremail)",
+		"%(contents)",
+		"%(contents:signature)",
+	}, refFieldSep)
+	c, e := exec.Command("git", "-C", dir, "for-each-ref", "--format="+format, "refs/tags/"+tag).Output()
+	if e != nil {
+		return m, fmt.Errorf("git for-each-ref %s: %v", tag, e)
+	}
+	f := strings.Split(string(c), refFieldSep)
+	if len(f) != 5 || f[0] != "tag" {
+		// Lightweight tag.
+		return m, nil
+	}
+	m.TaggerName = strings.TrimSpace(f[1])
+	m.TaggerEmail = strings.Trim(strings.TrimSpace(f[2]), "<>")
+	m.TagMessage = strings.TrimSpace(strings.TrimSuffix(f[3], f[4]))
+	m.TagSubject = strings.TrimSpace(strings.SplitN(m.TagMessage, "\n", 2)[0])
+	return m, nil
+}
+
+// getCommitMetadata gets the message and authorship of HEAD.
+func getCommitMetadata(dir string) (CommitMetadata, error) {
+	m := CommitMetadata{}
+	c, e := exec.Command("git", "-C", dir, "log", "-1", "--format=%s%x1f%an%x1f%ae%x1f%cn%x1f%ce", "HEAD").Output()
+	if e != nil {
+		return m, fmt.Errorf("git log: %v", e)
+	}
+	f := strings.Split(strings.TrimRight(string(c), "\n"), refFieldSep)
+	if len(f) != 5 {
+		return m, fmt.Errorf("unexpected git log output: %q", c)
+	}
+	m.Subject, m.AuthorName, m.AuthorEmail, m.CommitterName, m.CommitterEmail = f[0], f[1], f[2], f[3], f[4]
+	return m, nil
+}
+
+// metadataValue gets the raw value of a metadata token.
+func metadataValue(name string, t TagMetadata, c CommitMetadata) (string, bool) {
+	switch name {
+	case "tag_subject":
+		return t.TagSubject, true
+	case "tag_message":
+		return t.TagMessage, true
+	case "tagger_name":
+		return t.TaggerName, true
+	case "tagger_email":
+		return t.TaggerEmail, true
+	case "subject":
+		return c.Subject, true
+	case "author_name":
+		return c.AuthorName, true
+	case "author_email":
+		return c.AuthorEmail, true
+	case "committer_name":
+		return c.CommitterName, true
+	case "committer_email":
+		return c.CommitterEmail, true
+	}
+	return "", false
+}
+
+// replaceMetadataTokens interpolates %{name} metadata tokens in 'format'.
+func replaceMetadataTokens(format, tag, dir string) string {
+	t, e := getTagMetadata(tag, dir)
+	if e != nil {
+		log.Println(e)
+	}
+	c, e := getCommitMetadata(dir)
+	if e != nil {
+		log.Println(e)
+	}
+	return reMetadataToken.ReplaceAllStringFunc(format, func(m string) string {
+		sub := reMetadataToken.FindStringSubmatch(m)
+		v, ok := metadataValue(sub[1], t, c)
+		if !ok {
+			return m
+		}
+		if sub[2] == ":file" {
+			return SanitizeFilename(v)
+		}
+		if sub[1] == "tag_message" {
+			return stripControl(v, true)
+		}
+		return SanitizeDisplay(v)
+	})
+}
@@ -0,0 +1,116 @@
+package gitvv
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSanitizeDisplay(t *testing.T) {
+	table := []struct {
+		s, want string
+	}{
+		{"Release 3.5.0", "Release 3.5.0"},
+		{"Release\n\t3.5.0\r\n", "Release 3.5.0"},
+		{"bell\x07 and  spaces", "bell and spaces"},
+	}
+	for _, tt := range table {
+		if got := SanitizeDisplay(tt.s); got != tt.want {
+			t.Errorf("s: %q, got: %q, want: %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	table := []struct {
+		s, want string
+	}{
+		{"Fix: bad tx (#12)", "Fix-bad-tx-12"},
+		{"../../etc/passwd", "etc-passwd"},
+		{"Isaac Ardis <isaac@example.com>", "Isaac-Ardis-isaac-example.com"},
+		{"v3.5.0_final", "v3.5.0_final"},
+		{"", ""},
+	}
+	for _, tt := range table {
+		if got := SanitizeFilename(tt.s); got != tt.want {
+			t.Errorf("s: %q, got: %q, want: %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestGetVersion_metadata(t *testing.T) {
+	dir := newTestRepo(t,
+		"commit -q --allow-empty -m first",
+		"tag -a v3.5.0 -m Release_3.5.0 -m Notes",
+		"commit -q --allow-empty -m Fix:_bad_tx_(#12)",
+	)
+	defer os.RemoveAll(dir)
+	resetCaches()
+	defer resetCaches()
+
+	table := []struct {
+		format, want string
+	}{
+		{"%{tag_subject}", "Release_3.5.0"},
+		{"%{tag_message}", "Release_3.5.0\n\nNotes"},
+		{"%{tagger_name} <%{tagger_email}>", "Janus <janus@example.com>"},
+		{"_{subject}", "Fix:_bad_tx_(#12)"},
+		{"%{subject:file}", "Fix-_bad_tx_-12"},
+		{"v%M.%m.%P %{author_name} %{committer_email}", "v3.5.0 Janus janus@example.com"},
+		{"%{unknown}", "%{unknown}"},
+	}
+	for _, tt := range table {
+		if got := GetVersion(tt.format, dir); got != tt.want {
+			t.Errorf("format: %s, got: %q, want: %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestGetVersionInfo(t *testing.T) {
+	dir := newTestRepo(t,
+		"commit -q --allow-empty -m first",
+		"tag v3.5.0",
+		"commit -q --allow-empty -m second",
+	)
+	defer os.RemoveAll(dir)
+	resetCaches()
+	defer resetCaches()
+
+	v, e := GetVersionInfo(dir, &Config{Channels: []Channel{{Branch: "*", Pre: "beta"}}})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if v.Version != "3.6.0-beta.1" || v.Tag != "v3.5.0" || v.Major != "3" || v.Minor != "5" || v.Patch != "0" || v.CommitCount != 1 {
+		t.Errorf("unexpected version: %+v", v)
+	}
+	if len(v.Commit) != 40 || v.Subject != "second" || v.TaggerName != "" {
+		t.Errorf("unexpected metadata: %+v", v)
+	}
+	if _, e := time.Parse(time.RFC3339, v.Date); e != nil || v.Dirty {
+		t.Errorf("unexpected date or dirty: %+v", v)
+	}
+
+	if e := ioutil.WriteFile(filepath.Join(dir, "new.txt"), []byte("x"), 0644); e != nil {
+		t.Fatal(e)
+	}
+	if v, e = GetVersionInfo(dir, nil); e != nil || !v.Dirty {
+		t.Errorf("want dirty: %+v, %v", v, e)
+	}
+	if dirty, e := IsDirty(dir, filepath.Join(dir, "new.txt")); e != nil || dirty {
+		t.Errorf("excluded file: got dirty: %v, %v", dirty, e)
+	}
+
+	// As with GetVersion, a tag not following the scheme yields "?".
+	other := newTestRepo(t, "commit -q --allow-empty -m first", "tag v3.5.0.1", "commit -q --allow-empty -m second")
+	defer os.RemoveAll(other)
+	resetCaches()
+	v, e = GetVersionInfo(other, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if got := GetVersion("%V", other); v.Version != "?" || got != "?" || v.Tag != "v3.5.0.1" {
+		t.Errorf("got: %s, GetVersion: %s, tag: %s", v.Version, got, v.Tag)
+	}
+}
@@ -0,0 +1,198 @@
+package gitvv
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Semver is a parsed semantic version, as per https://semver.org.
+type Semver struct {
+	Major int
+	Minor int
+	Patch int
+	Pre   []string // pre-release identifiers, eg. [beta 3]
+	Build []string // build metadata identifiers, eg. [66 bbb06b1]
+}
+
+// ParseSemver parses a semantic version, tolerating a leading 'v', eg v3.5.0 or 3.6.0-beta.2+bbb06b1
+func ParseSemver(s string) (*Semver, error) {
+	v := &Semver{}
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if s == "" {
+		return nil, errors.New("empty version")
+	}
+
+	if i := strings.Index(s, "+"); i >= 0 {
+		build := s[i+1:]
+		s = s[:i]
+		v.Build = strings.Split(build, ".")
+		for _, id := range v.Build {
+			if !isIdentifier(id) {
+				return nil, fmt.Errorf("invalid build metadata '%s'", build)
+			}
+		}
+	}
+	if i := strings.Index(s, "-"); i >= 0 {
+		pre := s[i+1:]
+		s = s[:i]
+		v.Pre = strings.Split(pre, ".")
+		for _, id := range v.Pre {
+			if !isIdentifier(id) || (isNumeric(id) && len(id) > 1 && id[0] == '0') {
+				return nil, fmt.Errorf("invalid pre-release '%s'", pre)
+			}
+		}
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("version '%s' must have exactly 3 numeric parts", s)
+	}
+	nums := make([]int, 3)
+	for i, p := range parts {
+		if !isNumeric(p) || (len(p) > 1 && p[0] == '0') {
+			return nil, fmt.Errorf("invalid numeric part '%s'", p)
+		}
+		n, e := strconv.Atoi(p)
+		if e != nil {
+			return nil, e
+		}
+		nums[i] = n
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+
+	return v, nil
+}
+
+// String returns the canonical version string, without a 'v' prefix.
+func (v *Semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Pre) > 0 {
+		s += "-" + strings.Join(v.Pre, ".")
+	}
+	if len(v.Build) > 0 {
+		s += "+" + strings.Join(v.Build, ".")
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if v has lower, equal or higher precedence than o.
+// Build metadata is ignored, as per https://semver.org/#spec-item-11
+func (v *Semver) Compare(o *Semver) int {
+	if c := compareInt(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	// A pre-release has lower precedence than its release.
+	switch {
+	case len(v.Pre) == 0 && len(o.Pre) == 0:
+		return 0
+	case len(v.Pre) == 0:
+		return 1
+	case len(o.Pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.Pre) && i < len(o.Pre); i++ {
+		if c := comparePreIdentifier(v.Pre[i], o.Pre[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(v.Pre), len(o.Pre))
+}
+
+// comparePreIdentifier compares pre-release identifiers: numeric identifiers numerically,
+// alphanumeric ones lexically, and numeric lower than alphanumeric.
+func comparePreIdentifier(a, b string) int {
+	an, bn := isNumeric(a), isNumeric(b)
+	switch {
+	case an && bn:
+		if c := compareInt(len(a), len(b)); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	case an:
+		return -1
+	case bn:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// SortSemvers sorts versions by ascending precedence.
+func SortSemvers(vs []*Semver) {
+	sort.SliceStable(vs, func(i, j int) bool {
+		return vs[i].Compare(vs[j]) < 0
+	})
+}
+
+// IsPreRelease reports whether the version has pre-release identifiers.
+func (v *Semver) IsPreRelease() bool {
+	return len(v.Pre) > 0
+}
+
+// bump returns the next release version for the given part (major, minor or patch).
+// A pre-release is bumped to its own release version, eg. 3.6.0-rc.1 -> 3.6.0
+func (v *Semver) bump(part string) (*Semver, error) {
+	next := &Semver{Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+	if v.IsPreRelease() {
+		return next, nil
+	}
+	switch part {
+	case "major":
+		next.Major++
+		next.Minor = 0
+		next.Patch = 0
+	case "minor", "":
+		next.Minor++
+		next.Patch = 0
+	case "patch":
+		next.Patch++
+	default:
+		return nil, fmt.Errorf("unknown version part '%s', want major, minor or patch", part)
+	}
+	return next, nil
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isIdentifier checks for a non-empty string of [0-9A-Za-z-]
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
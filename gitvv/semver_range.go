@@ -0,0 +1,301 @@
+package gitvv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Range is a set of version constraints, eg. ">=3.5.0 <4" or "^3.5 || ~4.1.2"
+//
+// Space-separated comparators must all be satisfied, '||' separates alternatives.
+// Supported comparators:
+// =, <, <=, >, >= - eg. >=3.5.0, <4 (partial versions are filled in, <4 is <4.0.0)
+// x-ranges - eg. 3.5.x, 3.5, 3, *
+// ~ - patch updates, eg. ~3.5.1 is >=3.5.1 <3.6.0
+// ^ - updates not changing the left-most non-zero part, eg. ^3.5.1 is >=3.5.1 <4.0.0
+// hyphen ranges - eg. 3.5.0 - 3.7 is >=3.5.0 <3.8.0
+//
+// As with npm, a pre-release version only satisfies a range if some comparator
+// of the same alternative has a pre-release on the same major.minor.patch.
+type Range struct {
+	raw  string
+	sets [][]comparator
+}
+
+type comparator struct {
+	op string // one of =, <, <=, >, >=
+	v  *Semver
+}
+
+// partial is a possibly incomplete version, eg. 3.5 or 3.x, with -1 for wildcards.
+type partial struct {
+	major, minor, patch int
+	pre                 []string
+}
+
+// ParseRange parses a version range, eg. ">=3.5.0 <4"
+func ParseRange(s string) (*Range, error) {
+	r := &Range{raw: s}
+	for _, alt := range strings.Split(s, "||") {
+		set, e := parseComparatorSet(alt)
+		if e != nil {
+			return nil, fmt.Errorf("invalid range '%s': %v", s, e)
+		}
+		r.sets = append(r.sets, set)
+	}
+	return r, nil
+}
+
+// String returns the range as given.
+func (r *Range) String() string {
+	return r.raw
+}
+
+// Satisfies reports whether v is in the range.
+func (r *Range) Satisfies(v *Semver) bool {
+	for _, set := range r.sets {
+		if setSatisfies(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func setSatisfies(set []comparator, v *Semver) bool {
+	for _, c := range set {
+		if !c.matches(v) {
+			return false
+		}
+	}
+	if !v.IsPreRelease() {
+		return true
+	}
+	for _, c := range set {
+		if c.v.IsPreRelease() && c.v.Major == v.Major && c.v.Minor == v.Minor && c.v.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+func (c comparator) matches(v *Semver) bool {
+	cmp := v.Compare(c.v)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+func parseComparatorSet(s string) ([]comparator, error) {
+	fields := strings.Fields(s)
+	// Join operators separated from their version, eg. ">= 3.5.0"
+	var tokens []string
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if isOperator(f) && i+1 < len(fields) {
+			f += fields[i+1]
+			i++
+		}
+		tokens = append(tokens, f)
+	}
+
+	// Any version.
+	if len(tokens) == 0 {
+		return []comparator{{op: ">=", v: &Semver{}}}, nil
+	}
+
+	// Hyphen range, eg. 3.5.0 - 3.7
+	if len(tokens) == 3 && tokens[1] == "-" {
+		lo, e := parsePartial(tokens[0])
+		if e != nil {
+			return nil, e
+		}
+		hi, e := parsePartial(tokens[2])
+		if e != nil {
+			return nil, e
+		}
+		var set []comparator
+		if lo.major >= 0 {
+			set = append(set, comparator{">=", lo.floor()})
+		}
+		if hi.major >= 0 {
+			if hi.isFull() {
+				set = append(set, comparator{"<=", hi.floor()})
+			} else {
+				set = append(set, comparator{"<", hi.ceil()})
+			}
+		}
+		if len(set) == 0 {
+			set = append(set, comparator{">=", &Semver{}})
+		}
+		return set, nil
+	}
+
+	var set []comparator
+	for _, t := range tokens {
+		cs, e := parseComparator(t)
+		if e != nil {
+			return nil, e
+		}
+		set = append(set, cs...)
+	}
+	return set, nil
+}
+
+func isOperator(s string) bool {
+	switch s {
+	case "=", "<", "<=", ">", ">=", "~", "^":
+		return true
+	}
+	return false
+}
+
+// parseComparator desugars a single comparator into primitive ones.
+func parseComparator(s string) ([]comparator, error) {
+	op := ""
+	for _, o := range []string{"<=", ">=", "<", ">", "=", "~", "^"} {
+		if strings.HasPrefix(s, o) {
+			op = o
+			s = s[len(o):]
+			break
+		}
+	}
+	p, e := parsePartial(s)
+	if e != nil {
+		return nil, e
+	}
+	anyVersion := []comparator{{">=", &Semver{}}}
+
+	switch op {
+	case "", "=":
+		if p.major < 0 {
+			return anyVersion, nil
+		}
+		if p.isFull() {
+			return []comparator{{"=", p.floor()}}, nil
+		}
+		return []comparator{{">=", p.floor()}, {"<", p.ceil()}}, nil
+	case ">":
+		if p.major < 0 {
+			// Nothing is greater than any version.
+			return []comparator{{"<", &Semver{}}}, nil
+		}
+		if p.isFull() {
+			return []comparator{{">", p.floor()}}, nil
+		}
+		return []comparator{{">=", p.ceil()}}, nil
+	case ">=":
+		return []comparator{{">=", p.floor()}}, nil
+	case "<":
+		if p.major < 0 {
+			return []comparator{{"<", &Semver{}}}, nil
+		}
+		return []comparator{{"<", p.floor()}}, nil
+	case "<=":
+		if p.major < 0 {
+			return anyVersion, nil
+		}
+		if p.isFull() {
+			return []comparator{{"<=", p.floor()}}, nil
+		}
+		return []comparator{{"<", p.ceil()}}, nil
+	case "~":
+		if p.major < 0 {
+			return anyVersion, nil
+		}
+		hi := &Semver{Major: p.major + 1}
+		if p.minor >= 0 {
+			hi = &Semver{Major: p.major, Minor: p.minor + 1}
+		}
+		return []comparator{{">=", p.floor()}, {"<", hi}}, nil
+	case "^":
+		if p.major < 0 {
+			return anyVersion, nil
+		}
+		var hi *Semver
+		switch {
+		case p.major > 0 || p.minor < 0:
+			hi = &Semver{Major: p.major + 1}
+		case p.minor > 0 || p.patch < 0:
+			hi = &Semver{Minor: p.minor + 1}
+		default:
+			hi = &Semver{Patch: p.patch + 1}
+		}
+		return []comparator{{">=", p.floor()}, {"<", hi}}, nil
+	}
+	return nil, fmt.Errorf("unknown operator in '%s'", s)
+}
+
+// parsePartial parses a possibly incomplete version, eg. 3, 3.5, 3.5.x, v3.5.0-beta.1, *
+func parsePartial(s string) (partial, error) {
+	p := partial{-1, -1, -1, nil}
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.Index(s, "-"); i >= 0 {
+		v, e := ParseSemver(s)
+		if e != nil {
+			return p, e
+		}
+		return partial{v.Major, v.Minor, v.Patch, v.Pre}, nil
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return p, fmt.Errorf("invalid version '%s'", s)
+	}
+	nums := []*int{&p.major, &p.minor, &p.patch}
+	for i, part := range parts {
+		switch part {
+		case "x", "X", "*", "":
+			// Everything following a wildcard is a wildcard, eg. 3.x.5 is 3.x.x
+			return p, nil
+		}
+		if !isNumeric(part) {
+			return p, fmt.Errorf("invalid version '%s'", s)
+		}
+		n, e := strconv.Atoi(part)
+		if e != nil {
+			return p, e
+		}
+		*nums[i] = n
+	}
+	return p, nil
+}
+
+func (p partial) isFull() bool {
+	return p.patch >= 0
+}
+
+// floor is the lowest version matching p, eg. 3.5 -> 3.5.0
+func (p partial) floor() *Semver {
+	v := &Semver{Major: p.major, Minor: p.minor, Patch: p.patch, Pre: p.pre}
+	if v.Major < 0 {
+		v.Major = 0
+	}
+	if v.Minor < 0 {
+		v.Minor = 0
+	}
+	if v.Patch < 0 {
+		v.Patch = 0
+	}
+	return v
+}
+
+// ceil is the lowest version above all versions matching incomplete p, eg. 3.5 -> 3.6.0
+func (p partial) ceil() *Semver {
+	if p.minor < 0 {
+		return &Semver{Major: p.major + 1}
+	}
+	return &Semver{Major: p.major, Minor: p.minor + 1}
+}
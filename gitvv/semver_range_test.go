@@ -0,0 +1,71 @@
+package gitvv
+
+import (
+	"testing"
+)
+
+func TestRange_Satisfies(t *testing.T) {
+	table := []struct {
+		r    string
+		v    string
+		want bool
+	}{
+		{">=3.5.0 <4", "3.5.0", true},
+		{">=3.5.0 <4", "3.9.12", true},
+		{">=3.5.0 <4", "4.0.0", false},
+		{">=3.5.0 <4", "3.4.9", false},
+		{">= 3.5.0 < 4", "3.6.0", true},
+		{">=3.5.0 <4", "3.6.0-beta.1", false}, // pre-releases excluded
+		{">=3.6.0-beta.0 <4", "3.6.0-beta.1", true},
+		{">=3.6.0-beta.0 <4", "3.7.0-beta.1", false},
+		{"3.5.x", "3.5.7", true},
+		{"3.5.x", "3.6.0", false},
+		{"3.5", "3.5.7", true},
+		{"3", "3.9.0", true},
+		{"3", "4.0.0", false},
+		{"*", "0.0.1", true},
+		{"", "10.1.1", true},
+		{"=3.5.1", "3.5.1", true},
+		{"3.5.1", "3.5.2", false},
+		{">3.5", "3.5.9", false},
+		{">3.5", "3.6.0", true},
+		{"<=3.5", "3.5.9", true},
+		{"<=3.5", "3.6.0", false},
+		{"~3.5.1", "3.5.9", true},
+		{"~3.5.1", "3.6.0", false},
+		{"~3", "3.9.0", true},
+		{"^3.5.1", "3.9.0", true},
+		{"^3.5.1", "4.0.0", false},
+		{"^0.5.1", "0.5.9", true},
+		{"^0.5.1", "0.6.0", false},
+		{"^0.0.3", "0.0.4", false},
+		{"3.5.0 - 3.7", "3.7.9", true},
+		{"3.5.0 - 3.7", "3.8.0", false},
+		{"3.5.0 - 3.7.1", "3.7.1", true},
+		{"^2 || >=3.5.0 <4", "2.1.0", true},
+		{"^2 || >=3.5.0 <4", "3.1.0", false},
+		{"v3.5.0", "3.5.0+66.bbb06b1", true},
+	}
+
+	for _, tt := range table {
+		r, e := ParseRange(tt.r)
+		if e != nil {
+			t.Fatalf("range: %s, error: %v", tt.r, e)
+		}
+		v, e := ParseSemver(tt.v)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if got := r.Satisfies(v); got != tt.want {
+			t.Errorf("range: %s, version: %s, got: %v, want: %v", tt.r, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestParseRange_invalid(t *testing.T) {
+	for _, r := range []string{">=3.5.0 <four", "3.5.0.1", "~>3.5", ">=3.5.0-"} {
+		if _, e := ParseRange(r); e == nil {
+			t.Errorf("range: %s, want error", r)
+		}
+	}
+}
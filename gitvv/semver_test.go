@@ -0,0 +1,129 @@
+package gitvv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSemver(t *testing.T) {
+	table := []struct {
+		s    string
+		want string
+		ok   bool
+	}{
+		{"v3.5.0", "3.5.0", true},
+		{"3.5.0", "3.5.0", true},
+		{"3.6.0-beta.14", "3.6.0-beta.14", true},
+		{"v3.6.0-rc.1+bbb06b1", "3.6.0-rc.1+bbb06b1", true},
+		{"1.0.0+66.bbb06b1", "1.0.0+66.bbb06b1", true},
+		{"3.5", "", false},
+		{"3.5.0.1", "", false},
+		{"03.5.0", "", false},
+		{"3.5.x", "", false},
+		{"3.6.0-beta.01", "", false},
+		{"3.6.0-", "", false},
+		{"3.6.0+bad_meta", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range table {
+		v, e := ParseSemver(tt.s)
+		if (e == nil) != tt.ok {
+			t.Errorf("s: %s, got error: %v, want ok: %v", tt.s, e, tt.ok)
+			continue
+		}
+		if e == nil && v.String() != tt.want {
+			t.Errorf("s: %s, got: %s, want: %s", tt.s, v, tt.want)
+		}
+	}
+}
+
+func TestSemver_bump(t *testing.T) {
+	table := []struct {
+		s    string
+		part string
+		want string
+	}{
+		{"3.5.1", "major", "4.0.0"},
+		{"3.5.1", "minor", "3.6.0"},
+		{"3.5.1", "", "3.6.0"},
+		{"3.5.1", "patch", "3.5.2"},
+		{"3.6.0-rc.1", "minor", "3.6.0"},
+		{"3.5.1+66", "patch", "3.5.2"},
+	}
+
+	for _, tt := range table {
+		v, e := ParseSemver(tt.s)
+		if e != nil {
+			t.Fatal(e)
+		}
+		got, e := v.bump(tt.part)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if got.String() != tt.want {
+			t.Errorf("s: %s, part: %s, got: %s, want: %s", tt.s, tt.part, got, tt.want)
+		}
+	}
+}
+
+func TestSemver_Compare(t *testing.T) {
+	// Ascending precedence, as per https://semver.org/#spec-item-11
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.1.0",
+		"2.0.0",
+	}
+
+	for i := range ordered {
+		for j := range ordered {
+			a, e := ParseSemver(ordered[i])
+			if e != nil {
+				t.Fatal(e)
+			}
+			b, e := ParseSemver(ordered[j])
+			if e != nil {
+				t.Fatal(e)
+			}
+			want := compareInt(i, j)
+			if got := a.Compare(b); got != want {
+				t.Errorf("a: %s, b: %s, got: %d, want: %d", a, b, got, want)
+			}
+		}
+	}
+
+	// Build metadata is ignored.
+	a, _ := ParseSemver("3.5.0+66.bbb06b1")
+	b, _ := ParseSemver("3.5.0")
+	if got := a.Compare(b); got != 0 {
+		t.Errorf("a: %s, b: %s, got: %d, want: 0", a, b, got)
+	}
+}
+
+func TestSortSemvers(t *testing.T) {
+	var vs []*Semver
+	for _, s := range []string{"v3.5.0", "3.6.0-beta.2", "3.4.10", "3.6.0", "3.4.9", "3.6.0-beta.10"} {
+		v, e := ParseSemver(s)
+		if e != nil {
+			t.Fatal(e)
+		}
+		vs = append(vs, v)
+	}
+	SortSemvers(vs)
+	var got []string
+	for _, v := range vs {
+		got = append(got, v.String())
+	}
+	want := "3.4.9 3.4.10 3.5.0 3.6.0-beta.2 3.6.0-beta.10 3.6.0"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
@@ -0,0 +1,193 @@
+package gitvv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// Handling of tags without a valid signature from the keyring.
+const (
+	UntrustedSkip   = "skip"   // use the nearest trusted tag instead, default
+	UntrustedReject = "reject" // fail versioning
+)
+
+const pgpSignatureHeader = "-----BEGIN PGP SIGNATURE-----"
+
+// Signature is the result of verifying a tag's signature against the keyring.
+type Signature struct {
+	Tag      string `json:"tag"`
+	Signed   bool   `json:"signed"`
+	Verified bool   `json:"verified"`
+	KeyID    string `json:"key_id,omitempty"`
+	Signer   string `json:"signer,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ErrUntrustedTag is returned when the nearest tag isn't signed by the keyring and untrusted tags are rejected.
+var ErrUntrustedTag = errors.New("tag is not signed by a trusted key")
+
+var keyringCache = make(map[string]openpgp.EntityList)
+
+// readKeyring reads an armored or binary OpenPGP keyring.
+func readKeyring(path string) (openpgp.EntityList, error) {
+	if k, ok := keyringCache[path]; ok {
+		return k, nil
+	}
+	b, e := ioutil.ReadFile(path)
+	if e != nil {
+		return nil, e
+	}
+	k, e := openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+	if e != nil {
+		k, e = openpgp.ReadKeyRing(bytes.NewReader(b))
+		if e != nil {
+			return nil, fmt.Errorf("failed to read keyring %s: %v", path, e)
+		}
+	}
+	if len(k) == 0 {
+		return nil, fmt.Errorf("keyring %s has no keys", path)
+	}
+	keyringCache[path] = k
+	return k, nil
+}
+
+// VerifyTag verifies the PGP signature of an annotated tag against 'keyring'.
+// Lightweight and unsigned tags are reported as not signed. The tag object must be named 'tag',
+// so a signed tag of another version can't be re-pointed to by a new tag, eg. v99.0.0 -> v1.0.0
+func VerifyTag(tag, dir string, keyring openpgp.EntityList) *Signature {
+	s := &Signature{Tag: tag}
+	t, e := exec.Command("git", "-C", dir, "cat-file", "-t", "refs/tags/"+tag).Output()
+	if e != nil {
+		s.Error = fmt.Sprintf("git cat-file: %v", e)
+		return s
+	}
+	if strings.TrimSpace(string(t)) != "tag" {
+		s.Error = "lightweight tag"
+		return s
+	}
+	raw, e := exec.Command("git", "-C", dir, "cat-file", "tag", "refs/tags/"+tag).Output()
+	if e != nil {
+		s.Error = fmt.Sprintf("git cat-file: %v", e)
+		return s
+	}
+	i := bytes.Index(raw, []byte(pgpSignatureHeader))
+	if i < 0 {
+		s.Error = "not signed"
+		return s
+	}
+	s.Signed = true
+	if name := tagObjectName(raw[:i]); name != tag {
+		s.Error = fmt.Sprintf("tag object is named '%s'", name)
+		return s
+	}
+
+	// The signed payload is the tag object up to its signature.
+	signer, e := openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(raw[:i]), bytes.NewReader(raw[i:]))
+	if e != nil {
+		s.Error = e.Error()
+		return s
+	}
+	s.Verified = true
+	if signer.PrimaryKey != nil {
+		s.KeyID = signer.PrimaryKey.KeyIdString()
+	}
+	for name := range signer.Identities {
+		s.Signer = name
+		break
+	}
+	return s
+}
+
+// tagObjectName gets the name of the 'tag' header of a tag object.
+func tagObjectName(raw []byte) string {
+	for _, l := range strings.Split(string(raw), "\n") {
+		if l == "" {
+			// End of headers.
+			break
+		}
+		if strings.HasPrefix(l, "tag ") {
+			return strings.TrimPrefix(l, "tag ")
+		}
+	}
+	return ""
+}
+
+// getTrustedVersionTag gets the nearest tag to HEAD signed by the keyring of 'config'.
+// Untrusted tags are skipped, or rejected with ErrUntrustedTag, as per config.
+// It returns "" and a nil Signature if there are no trusted tags.
+func getTrustedVersionTag(dir string, config *Config) (string, *Signature, error) {
+	keyring, e := readKeyring(config.Keyring)
+	if e != nil {
+		return "", nil, e
+	}
+	var excludes []string
+	for {
+		args := []string{"-C", dir, "describe", "--tags", "--abbrev=0"}
+		for _, x := range excludes {
+			args = append(args, "--exclude", escapeGlob(x))
+		}
+		c, e := exec.Command("git", args...).Output()
+		tag := strings.TrimSpace(string(c))
+		if e != nil || tag == "" {
+			// No more tags.
+			return "", nil, nil
+		}
+		s := VerifyTag(tag, dir, keyring)
+		if s.Verified {
+			return tag, s, nil
+		}
+		if config.Untrusted == UntrustedReject {
+			return "", s, fmt.Errorf("%s: %v (%s)", tag, ErrUntrustedTag, s.Error)
+		}
+		excludes = append(excludes, tag)
+	}
+}
+
+// escapeGlob escapes the glob metacharacters of a tag name, as git describe matches --exclude patterns as globs.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`\*?[`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// selection is a tag selected by SelectTag, with its signature.
+type selection struct {
+	tag string
+	sig *Signature
+}
+
+// SelectTag gets the tag versions are derived from: the nearest tag to HEAD,
+// or the nearest trusted one if 'config' has a keyring.
+// The Signature is nil unless a keyring is configured.
+// The selection is kept in 'config', so versioning with it again doesn't verify tags again.
+func SelectTag(dir string, config *Config) (string, *Signature, error) {
+	if dir == "" {
+		dir = "."
+	}
+	if config == nil || config.Keyring == "" {
+		return getVersionTag(dir), nil, nil
+	}
+	if s, ok := config.selected[dir]; ok {
+		return s.tag, s.sig, nil
+	}
+	tag, sig, e := getTrustedVersionTag(dir, config)
+	if e != nil {
+		return "", sig, e
+	}
+	if config.selected == nil {
+		config.selected = make(map[string]selection)
+	}
+	config.selected[dir] = selection{tag: tag, sig: sig}
+	return tag, sig, nil
+}
@@ -0,0 +1,177 @@
+package gitvv
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+// signTag creates annotated tag 'name' on HEAD, signed by 'signer' if not nil.
+func signTag(t *testing.T, dir, name string, signer *openpgp.Entity) {
+	head, e := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if e != nil {
+		t.Fatal(e)
+	}
+	obj := fmt.Sprintf("object %s\ntype commit\ntag %s\ntagger Janus <janus@example.com> 1528700000 +0000\n\nRelease %s\n",
+		strings.TrimSpace(string(head)), name, name)
+	if signer != nil {
+		sig := &bytes.Buffer{}
+		if e := openpgp.ArmoredDetachSign(sig, signer, strings.NewReader(obj), nil); e != nil {
+			t.Fatal(e)
+		}
+		obj += sig.String()
+		if !strings.HasSuffix(obj, "\n") {
+			obj += "\n"
+		}
+	}
+	mktag := exec.Command("git", "-C", dir, "mktag")
+	mktag.Stdin = strings.NewReader(obj)
+	sha, e := mktag.Output()
+	if e != nil {
+		t.Fatalf("git mktag: %v", e)
+	}
+	if out, e := exec.Command("git", "-C", dir, "update-ref", "refs/tags/"+name, strings.TrimSpace(string(sha))).CombinedOutput(); e != nil {
+		t.Fatalf("git update-ref: %v: %s", e, out)
+	}
+}
+
+// writeKeyring writes the armored public key of 'entity'.
+func writeKeyring(t *testing.T, dir string, entity *openpgp.Entity) string {
+	path := filepath.Join(dir, "keyring.asc")
+	b := &bytes.Buffer{}
+	w, e := armor.Encode(b, openpgp.PublicKeyType, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if e := entity.Serialize(w); e != nil {
+		t.Fatal(e)
+	}
+	w.Close()
+	if e := ioutil.WriteFile(path, b.Bytes(), 0644); e != nil {
+		t.Fatal(e)
+	}
+	return path
+}
+
+func TestSelectTag_keyring(t *testing.T) {
+	maintainer, e := openpgp.NewEntity("Maintainer", "", "maintainer@example.com", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	stranger, e := openpgp.NewEntity("Stranger", "", "stranger@example.com", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	dir := newTestRepo(t, "commit -q --allow-empty -m one")
+	defer os.RemoveAll(dir)
+	signTag(t, dir, "v3.5.0", maintainer)
+	exec.Command("git", "-C", dir, "-c", "user.name=Janus", "-c", "user.email=janus@example.com", "commit", "-q", "--allow-empty", "-m", "two").Run()
+	signTag(t, dir, "v3.5.1", stranger)
+	exec.Command("git", "-C", dir, "-c", "user.name=Janus", "-c", "user.email=janus@example.com", "commit", "-q", "--allow-empty", "-m", "three").Run()
+	signTag(t, dir, "v3.5.2", nil)
+	exec.Command("git", "-C", dir, "tag", "v3.5.3").Run()
+
+	keyring := writeKeyring(t, dir, maintainer)
+	resetCaches()
+	defer resetCaches()
+
+	// Without keyring, the nearest tag is used.
+	if tag, sig, e := SelectTag(dir, nil); e != nil || sig != nil || (tag != "v3.5.3" && tag != "v3.5.2") {
+		t.Errorf("got: %s %v %v, want nearest tag", tag, sig, e)
+	}
+
+	tag, sig, e := SelectTag(dir, &Config{Keyring: keyring})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if tag != "v3.5.0" || sig == nil || !sig.Verified || sig.KeyID != maintainer.PrimaryKey.KeyIdString() {
+		t.Errorf("got: %s %+v, want v3.5.0 verified", tag, sig)
+	}
+
+	_, sig, e = SelectTag(dir, &Config{Keyring: keyring, Untrusted: UntrustedReject})
+	if e == nil {
+		t.Error("want error rejecting untrusted tag")
+	}
+	if sig == nil || sig.Verified {
+		t.Errorf("unexpected signature: %+v", sig)
+	}
+
+	if got := GetVersionWithConfig("v%M.%m.%P+%C", dir, &Config{Keyring: keyring}); got != "v3.5.0+2" {
+		t.Errorf("got: %s, want: v3.5.0+2", got)
+	}
+
+	// The selection is kept in the config, so the keyring isn't read again.
+	c := &Config{Keyring: keyring}
+	if _, _, e := SelectTag(dir, c); e != nil {
+		t.Fatal(e)
+	}
+	if e := os.Remove(keyring); e != nil {
+		t.Fatal(e)
+	}
+	v, e := GetVersionInfo(dir, c)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if v.Tag != "v3.5.0" || v.Signature == nil || !v.Signature.Verified || v.Signature.Signer == "" {
+		t.Errorf("unexpected version: %+v, signature: %+v", v, v.Signature)
+	}
+	if got := GetVersionWithConfig("v%M.%m.%P+%C", dir, c); got != "v3.5.0+2" {
+		t.Errorf("got: %s, want: v3.5.0+2", got)
+	}
+}
+
+func Test_escapeGlob(t *testing.T) {
+	table := []struct {
+		s, want string
+	}{
+		{"v3.5.0", "v3.5.0"},
+		{"v3.5.*", `v3.5.\*`},
+		{`[rc]?\`, `\[rc]\?\\`},
+	}
+	for _, tt := range table {
+		if got := escapeGlob(tt.s); got != tt.want {
+			t.Errorf("%s: got: %s, want: %s", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyTag(t *testing.T) {
+	maintainer, e := openpgp.NewEntity("Maintainer", "", "maintainer@example.com", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	dir := newTestRepo(t, "commit -q --allow-empty -m one", "tag lightweight")
+	defer os.RemoveAll(dir)
+	signTag(t, dir, "unsigned", nil)
+	signTag(t, dir, "signed", maintainer)
+	// A new tag re-pointed at the signed tag object of another.
+	if out, e := exec.Command("git", "-C", dir, "update-ref", "refs/tags/v99.0.0", "refs/tags/signed").CombinedOutput(); e != nil {
+		t.Fatalf("git update-ref: %v: %s", e, out)
+	}
+	keyring := openpgp.EntityList{maintainer}
+
+	table := []struct {
+		tag              string
+		signed, verified bool
+	}{
+		{"lightweight", false, false},
+		{"unsigned", false, false},
+		{"signed", true, true},
+		{"v99.0.0", true, false},
+	}
+	for _, tt := range table {
+		s := VerifyTag(tt.tag, dir, keyring)
+		if s.Signed != tt.signed || s.Verified != tt.verified {
+			t.Errorf("tag: %s, got: %+v", tt.tag, s)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package gitvv
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tag is a git tag recognized as a version by the configured scheme.
+type Tag struct {
+	Name      string    `json:"name"`      // eg. v3.5.0
+	Version   string    `json:"version"`   // eg. 3.5.0
+	Date      time.Time `json:"date"`      // tagger date, or commit date for lightweight tags
+	Commit    string    `json:"commit"`    // sha1 of tagged commit
+	Annotated bool      `json:"annotated"` // annotated or lightweight
+	Signed    bool      `json:"signed"`    // has a (not verified) PGP, SSH or X.509 signature
+	// CommitsToNext is the number of commits from this tag to the next version, or to HEAD for the latest.
+	// It is -1 if those don't descend from the tag, eg. for the last tag of a release branch.
+	CommitsToNext int `json:"commits_to_next"`
+
+	semver *Semver
+	calver *CalVer
+}
+
+// Separators for git for-each-ref fields and records.
+const (
+	refFieldSep  = "\x1f"
+	refRecordSep = "\x1e"
+)
+
+// tagSignatureHeaders start the signatures of tags: PGP, SSH and X.509.
+var tagSignatureHeaders = []string{
+	"-----BEGIN PGP SIGNATURE-----",
+	"-----BEGIN SSH SIGNATURE-----",
+	"-----BEGIN SIGNED MESSAGE-----",
+}
+
+// hasTagSignature reports whether the contents of a tag object end with a signature.
+// Older git doesn't recognize SSH signatures with %(contents:signature), so the contents are searched.
+func hasTagSignature(contents string) bool {
+	for _, h := range tagSignatureHeaders {
+		if strings.HasPrefix(contents, h) || strings.Contains(contents, "\n"+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare returns -1, 0 or 1 if t has lower, equal or higher precedence than o.
+func (t *Tag) Compare(o *Tag) int {
+	if t.calver != nil && o.calver != nil {
+		return t.calver.Compare(o.calver)
+	}
+	if t.semver != nil && o.semver != nil {
+		return t.semver.Compare(o.semver)
+	}
+	return strings.Compare(t.Version, o.Version)
+}
+
+// InLine reports whether the tag's version starts with the given segments, eg. 3, 3.5 or 2018.06
+func (t *Tag) InLine(line string) bool {
+	if line == "" {
+		return true
+	}
+	want := strings.Split(strings.TrimPrefix(line, "v"), ".")
+	got := t.segments()
+	if len(want) > len(got) {
+		return false
+	}
+	for i := range want {
+		if want[i] != got[i] {
+			// Allow unpadded calver segments, eg. 2018.6 for 2018.06
+			wn, e1 := strconv.Atoi(want[i])
+			gn, e2 := strconv.Atoi(got[i])
+			if e1 != nil || e2 != nil || wn != gn {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// segments gets the release segments of the tag's version, eg. [3 5 0] or [2018 06 3]
+func (t *Tag) segments() []string {
+	if t.calver != nil {
+		return t.calver.Segments()
+	}
+	if t.semver != nil {
+		return []string{strconv.Itoa(t.semver.Major), strconv.Itoa(t.semver.Minor), strconv.Itoa(t.semver.Patch)}
+	}
+	return nil
+}
+
+// IsPreRelease reports whether the tag's version is a semver pre-release.
+func (t *Tag) IsPreRelease() bool {
+	return t.semver != nil && t.semver.IsPreRelease()
+}
+
+// ListVersionTags lists all tags recognized as versions by the scheme of 'config' (which may be nil),
+// sorted by ascending precedence.
+func ListVersionTags(dir string, config *Config) ([]*Tag, error) {
+	if dir == "" {
+		dir = "."
+	}
+	format := strings.Join([]string{
+		"%(refname:short)",
+		"%(objecttype)",
+		"%(objectname)",
+		"%(*objectname)",
+		"%(creatordate:unix)",
+		"%(contents)",
+	}, refFieldSep) + refRecordSep
+	c, e := exec.Command("git", "-C", dir, "for-each-ref", "--format="+format, "refs/tags").Output()
+	if e != nil {
+		return nil, fmt.Errorf("git for-each-ref: %v", e)
+	}
+
+	var tags []*Tag
+	for _, rec := range strings.Split(string(c), refRecordSep) {
+		rec = strings.TrimLeft(rec, "\n")
+		if rec == "" {
+			continue
+		}
+		f := strings.Split(rec, refFieldSep)
+		if len(f) != 6 {
+			return nil, fmt.Errorf("unexpected git for-each-ref output: %q", rec)
+		}
+		t := &Tag{
+			Name:      f[0],
+			Annotated: f[1] == "tag",
+			Commit:    f[2],
+			Signed:    hasTagSignature(f[5]),
+		}
+		if t.Annotated {
+			t.Commit = f[3]
+		}
+		if sec, e := strconv.ParseInt(f[4], 10, 64); e == nil {
+			t.Date = time.Unix(sec, 0).UTC()
+		}
+
+		if config.isCalVer() {
+			v, e := ParseCalVer(config.calverLayout(), t.Name)
+			if e != nil {
+				continue
+			}
+			t.calver = v
+			t.Version = v.String()
+		} else {
+			v, e := ParseSemver(t.Name)
+			if e != nil {
+				continue
+			}
+			t.semver = v
+			t.Version = v.String()
+		}
+		tags = append(tags, t)
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].Compare(tags[j]) < 0
+	})
+
+	if len(tags) == 0 {
+		return tags, nil
+	}
+	head, e := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if e != nil {
+		return nil, fmt.Errorf("git rev-parse HEAD: %v", e)
+	}
+	heads := []string{strings.TrimSpace(string(head))}
+	for _, t := range tags {
+		heads = append(heads, t.Commit)
+	}
+	g, e := readCommitGraph(dir, heads)
+	if e != nil {
+		return nil, e
+	}
+	for i, t := range tags {
+		next := heads[0]
+		if i+1 < len(tags) {
+			next = tags[i+1].Commit
+		}
+		t.CommitsToNext = g.countCommits(t.Commit, next)
+	}
+
+	return tags, nil
+}
+
+// commitGraph holds the parents of commits, so counting commits between tags takes a single git process.
+type commitGraph struct {
+	parents map[string][]string
+	sizes   map[string]int
+}
+
+// readCommitGraph reads the parents of the commits reachable from 'heads'.
+func readCommitGraph(dir string, heads []string) (*commitGraph, error) {
+	cmd := exec.Command("git", "-C", dir, "rev-list", "--parents", "--stdin")
+	cmd.Stdin = strings.NewReader(strings.Join(heads, "\n") + "\n")
+	c, e := cmd.Output()
+	if e != nil {
+		return nil, fmt.Errorf("git rev-list: %v", e)
+	}
+	g := &commitGraph{parents: make(map[string][]string), sizes: make(map[string]int)}
+	for _, l := range strings.Split(string(c), "\n") {
+		if f := strings.Fields(l); len(f) > 0 {
+			g.parents[f[0]] = f[1:]
+		}
+	}
+	return g, nil
+}
+
+// ancestors gets the commits reachable from 'commit', including it.
+func (g *commitGraph) ancestors(commit string) map[string]bool {
+	seen := map[string]bool{commit: true}
+	queue := []string{commit}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		for _, p := range g.parents[c] {
+			if !seen[p] {
+				seen[p] = true
+				queue = append(queue, p)
+			}
+		}
+	}
+	return seen
+}
+
+// countCommits counts commits reachable from 'to' but not from 'from', or -1 if 'to' doesn't descend from 'from'.
+func (g *commitGraph) countCommits(from, to string) int {
+	a := g.ancestors(to)
+	if !a[from] {
+		return -1
+	}
+	g.sizes[to] = len(a)
+	n, ok := g.sizes[from]
+	if !ok {
+		n = len(g.ancestors(from))
+		g.sizes[from] = n
+	}
+	// All commits reachable from 'from' are reachable from 'to'.
+	return len(a) - n
+}
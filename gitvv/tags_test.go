@@ -0,0 +1,193 @@
+package gitvv
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// newTestRepo creates a temporary git repo, running each of 'cmds' as git arguments.
+func newTestRepo(t *testing.T, cmds ...string) string {
+	if _, e := exec.LookPath("git"); e != nil {
+		t.Skip("git not found")
+	}
+	dir, e := ioutil.TempDir("", "gitvv")
+	if e != nil {
+		t.Fatal(e)
+	}
+	base := []string{"-C", dir, "-c", "user.name=Janus", "-c", "user.email=janus@example.com", "-c", "commit.gpgsign=false", "-c", "tag.gpgsign=false"}
+	for _, c := range append([]string{"init -q"}, cmds...) {
+		out, e := exec.Command("git", append(base, strings.Fields(c)...)...).CombinedOutput()
+		if e != nil {
+			os.RemoveAll(dir)
+			t.Fatalf("git %s: %v: %s", c, e, out)
+		}
+	}
+	return dir
+}
+
+func TestListVersionTags(t *testing.T) {
+	dir := newTestRepo(t,
+		"commit -q --allow-empty -m one",
+		"tag -a v3.5.0 -m release",
+		"commit -q --allow-empty -m two",
+		"commit -q --allow-empty -m three",
+		"tag v3.5.1",
+		"tag not-a-version",
+		"commit -q --allow-empty -m four",
+		"tag v3.10.0",
+		"commit -q --allow-empty -m five",
+		"tag 2018.06.3",
+	)
+	defer os.RemoveAll(dir)
+
+	tags, e := ListVersionTags(dir, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	want := []struct {
+		name      string
+		annotated bool
+		toNext    int
+	}{
+		{"v3.5.0", true, 2},
+		{"v3.5.1", false, 1},
+		{"v3.10.0", false, 1},
+	}
+	if len(tags) != len(want) {
+		t.Fatalf("got: %d tags, want: %d", len(tags), len(want))
+	}
+	for i, w := range want {
+		got := tags[i]
+		if got.Name != w.name || got.Annotated != w.annotated || got.CommitsToNext != w.toNext {
+			t.Errorf("got: %s annotated=%v next=%d, want: %s annotated=%v next=%d",
+				got.Name, got.Annotated, got.CommitsToNext, w.name, w.annotated, w.toNext)
+		}
+		if got.Signed {
+			t.Errorf("tag: %s, unexpected signed", got.Name)
+		}
+		if !isHash(got.Commit) || len(got.Commit) != 40 {
+			t.Errorf("tag: %s, unexpected commit: %s", got.Name, got.Commit)
+		}
+	}
+
+	calver, e := ListVersionTags(dir, &Config{Scheme: SchemeCalVer})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(calver) != 1 || calver[0].Version != "2018.06.3" || calver[0].CommitsToNext != 0 {
+		t.Errorf("unexpected calver tags: %v", calver)
+	}
+}
+
+func TestListVersionTags_branches(t *testing.T) {
+	dir := newTestRepo(t,
+		"commit -q --allow-empty -m one",
+		"tag v3.5.0",
+		"checkout -q -b release",
+		"commit -q --allow-empty -m two",
+		"tag v3.5.1",
+		"checkout -q -",
+		"commit -q --allow-empty -m three",
+		"commit -q --allow-empty -m four",
+		"tag v3.6.0",
+		"commit -q --allow-empty -m five",
+	)
+	defer os.RemoveAll(dir)
+
+	tags, e := ListVersionTags(dir, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	// v3.6.0 doesn't descend from v3.5.1 of the release branch.
+	want := map[string]int{"v3.5.0": 1, "v3.5.1": -1, "v3.6.0": 1}
+	if len(tags) != len(want) {
+		t.Fatalf("got: %d tags, want: %d", len(tags), len(want))
+	}
+	for _, tag := range tags {
+		if tag.CommitsToNext != want[tag.Name] {
+			t.Errorf("tag: %s, got: %d, want: %d", tag.Name, tag.CommitsToNext, want[tag.Name])
+		}
+	}
+}
+
+func Test_hasTagSignature(t *testing.T) {
+	table := []struct {
+		contents string
+		want     bool
+	}{
+		{"release\n", false},
+		{"release\n-----BEGIN PGP SIGNATURE-----\n\niQEz\n-----END PGP SIGNATURE-----\n", true},
+		{"release\n-----BEGIN SSH SIGNATURE-----\nU1NI\n-----END SSH SIGNATURE-----\n", true},
+		{"release\n-----BEGIN SIGNED MESSAGE-----\nMII\n-----END SIGNED MESSAGE-----\n", true},
+		{"quoting -----BEGIN PGP SIGNATURE----- inline\n", false},
+	}
+	for _, tt := range table {
+		if got := hasTagSignature(tt.contents); got != tt.want {
+			t.Errorf("%q: got: %v, want: %v", tt.contents, got, tt.want)
+		}
+	}
+}
+
+func TestTag_InLine(t *testing.T) {
+	table := []struct {
+		version string
+		line    string
+		want    bool
+	}{
+		{"3.5.1", "", true},
+		{"3.5.1", "3", true},
+		{"3.5.1", "v3.5", true},
+		{"3.5.1", "3.6", false},
+		{"3.5.1", "35", false},
+		{"3.6.0-rc.1", "3.6", true},
+		{"3.5.1", "3.5.1.0", false},
+	}
+
+	for _, tt := range table {
+		v, e := ParseSemver(tt.version)
+		if e != nil {
+			t.Fatal(e)
+		}
+		tag := &Tag{Version: tt.version, semver: v}
+		if got := tag.InLine(tt.line); got != tt.want {
+			t.Errorf("version: %s, line: %s, got: %v, want: %v", tt.version, tt.line, got, tt.want)
+		}
+	}
+
+	c, _ := ParseCalVer(DefaultCalVerLayout, "2018.06.3")
+	tag := &Tag{Version: c.String(), calver: c}
+	if !tag.InLine("2018.6") || tag.InLine("2018.07") {
+		t.Error("unexpected calver line match")
+	}
+}
+
+// resetCaches clears git caches between test repos.
+func resetCaches() {
+	cacheHEADHash = ""
+	cacheCommitCount = ""
+	cacheLastTagName = ""
+	cacheCommitCountFromTagName = ""
+	cacheDir = ""
+}
+
+func TestGetVersion_multipleDirs(t *testing.T) {
+	a := newTestRepo(t, "commit -q --allow-empty -m one", "tag v3.5.0")
+	defer os.RemoveAll(a)
+	b := newTestRepo(t, "commit -q --allow-empty -m one", "tag v1.2.0", "commit -q --allow-empty -m two")
+	defer os.RemoveAll(b)
+	resetCaches()
+	defer resetCaches()
+
+	// Caches must not leak between directories.
+	for i := 0; i < 2; i++ {
+		if got := GetVersion("v%M.%m.%P+%C", a); got != "v3.5.0+0" {
+			t.Errorf("a: got: %s", got)
+		}
+		if got := GetVersion("v%M.%m.%P+%C", b); got != "v1.2.0+1" {
+			t.Errorf("b: got: %s", got)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package gitvv
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+// Version is the structured version information of HEAD, as interpolated by GetVersion.
+type Version struct {
+	// Version is %V, eg. 3.6.0-beta.14, "?" if the last tag doesn't follow the scheme
+	Version string `json:"version"`
+	// Tag is the tag on HEAD, else the last tag, or "" if none.
+	Tag   string `json:"tag"`
+	Major string `json:"major"` // %M, "?" if no tag
+	Minor string `json:"minor"` // %m, "?" if no tag
+	Patch string `json:"patch"` // %P, "?" if no tag
+	// CommitCount is %C, commits since Tag.
+	CommitCount int `json:"commit_count"`
+	// Commit is the full HEAD sha1.
+	Commit string `json:"commit"`
+	// Date is the HEAD commit date, RFC3339 in UTC, eg. 2018-06-08T09:51:40Z
+	Date string `json:"date"`
+	// Dirty is true if the work tree has uncommitted changes.
+	Dirty bool `json:"dirty"`
+	// Branch is the current branch, from CI environment or git, or "" if HEAD is detached.
+	Branch string `json:"branch"`
+	// Signature is the verification result of Tag, if a keyring is configured.
+	Signature *Signature `json:"signature,omitempty"`
+
+	TagMetadata
+	CommitMetadata
+}
+
+// GetVersionInfo gets the structured version information of HEAD in 'dir',
+// using versioning rules from 'config', which may be nil.
+func GetVersionInfo(dir string, config *Config) (*Version, error) {
+	if dir == "" {
+		dir = "."
+	}
+	v := &Version{Major: "?", Minor: "?", Patch: "?"}
+
+	tag, sig, e := SelectTag(dir, config)
+	if e != nil {
+		return nil, e
+	}
+	v.Tag, v.Signature = tag, sig
+	commitCount := getCommitCountFrom(v.Tag, dir)
+	n, e := strconv.Atoi(commitCount)
+	if e != nil {
+		return nil, e
+	}
+	v.CommitCount = n
+
+	if v.Tag != "" {
+		var segs []string
+		if config.isCalVer() {
+			segs = parseCalVerSegmentsFromTag(v.Tag, config.calverLayout())
+		} else {
+			segs = parseSemverFromTag(v.Tag)
+		}
+		if len(segs) >= 3 {
+			v.Major, v.Minor, v.Patch = segs[0], segs[1], segs[2]
+		}
+	}
+
+	// As with GetVersion, a tag not following the scheme is logged, eg. from before switching schemes.
+	sv, e := getSchemeVersion(v.Tag, commitCount, dir, config)
+	if e != nil {
+		log.Println(e)
+		sv = "?"
+	}
+	v.Version = sv
+
+	v.Commit = getHEADHash(40, dir)
+	t, e := getHEADTime(dir)
+	if e != nil {
+		return nil, e
+	}
+	v.Date = t.Format(time.RFC3339)
+	if v.Dirty, e = IsDirty(dir); e != nil {
+		return nil, e
+	}
+	v.Branch, _ = getBranch(dir)
+
+	if v.TagMetadata, e = getTagMetadata(v.Tag, dir); e != nil {
+		return nil, e
+	}
+	if v.CommitMetadata, e = getCommitMetadata(dir); e != nil {
+		return nil, e
+	}
+	return v, nil
+}
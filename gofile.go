@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ETCDEVTeam/janus/gitvv"
+)
+
+// goFileHeader marks the file as generated, as recognized by go vet and golint.
+const goFileHeader = "// Code generated by janus version -gofile. DO NOT EDIT."
+
+// errGoFileOutdated is returned by writeGoFile in check mode if the file isn't current.
+var errGoFileOutdated = errors.New("file is out of date, run janus version -gofile")
+
+// goFileCommitConsts are the constants of the commit the file is generated at, and of the build,
+// which committing the file changes, so check mode ignores them if asked to, see writeGoFile.
+var goFileCommitConsts = map[string]bool{
+	"Version": true, "CommitCount": true, "Commit": true, "Date": true, "Dirty": true, "Branch": true,
+}
+
+func init() {
+	t := reflect.TypeOf(gitvv.CommitMetadata{})
+	for i := 0; i < t.NumField(); i++ {
+		goFileCommitConsts[t.Field(i).Name] = true
+	}
+}
+
+// goFile generates gofmt'd source declaring a constant for every field of 'v',
+// named as the field, eg. Version, CommitCount, TagSubject, SignatureVerified.
+func goFile(pkg string, v *gitvv.Version) ([]byte, error) {
+	b := &bytes.Buffer{}
+	fmt.Fprintln(b, goFileHeader)
+	fmt.Fprintln(b)
+	fmt.Fprintf(b, "package %s\n\n", pkg)
+	fmt.Fprintln(b, "// Version information from git.")
+	fmt.Fprintln(b, "const (")
+	writeGoConsts(b, "", reflect.ValueOf(*v))
+	fmt.Fprintln(b, ")")
+	return format.Source(b.Bytes())
+}
+
+// writeGoConsts writes a constant spec for each field of struct 'v', flattening embedded and pointer structs.
+func writeGoConsts(b *bytes.Buffer, prefix string, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		name := prefix + f.Name
+		if f.Anonymous {
+			name = prefix
+		}
+		switch fv.Kind() {
+		case reflect.Ptr:
+			if fv.IsNil() {
+				fv = reflect.Zero(f.Type.Elem())
+			} else {
+				fv = fv.Elem()
+			}
+			writeGoConsts(b, name, fv)
+		case reflect.Struct:
+			writeGoConsts(b, name, fv)
+		case reflect.String:
+			fmt.Fprintf(b, "%s = %s\n", name, strconv.Quote(fv.String()))
+		case reflect.Int:
+			fmt.Fprintf(b, "%s = %d\n", name, fv.Int())
+		case reflect.Bool:
+			fmt.Fprintf(b, "%s = %t\n", name, fv.Bool())
+		}
+	}
+}
+
+// goFilePackage gets the package name for a file generated at 'path':
+// $GOPACKAGE as set by go generate, else the package of other files in the directory, else main.
+func goFilePackage(path string) string {
+	if p := os.Getenv("GOPACKAGE"); p != "" {
+		return p
+	}
+	dir := filepath.Dir(path)
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, m := range matches {
+		if strings.HasSuffix(m, "_test.go") || filepath.Base(m) == filepath.Base(path) {
+			continue
+		}
+		f, e := parser.ParseFile(token.NewFileSet(), m, nil, parser.PackageClauseOnly)
+		if e == nil {
+			return f.Name.Name
+		}
+	}
+	return "main"
+}
+
+// goFileConsts gets the constants declared in Go source 'src', by name, as their literal values.
+func goFileConsts(src []byte) (map[string]string, error) {
+	f, e := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if e != nil {
+		return nil, e
+	}
+	consts := make(map[string]string)
+	for _, d := range f.Decls {
+		g, ok := d.(*ast.GenDecl)
+		if !ok || g.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range g.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for i, n := range vs.Names {
+				if i >= len(vs.Values) {
+					break
+				}
+				switch v := vs.Values[i].(type) {
+				case *ast.BasicLit:
+					consts[n.Name] = v.Value
+				case *ast.Ident:
+					consts[n.Name] = v.Name
+				}
+			}
+		}
+	}
+	return consts, nil
+}
+
+// writeGoFile writes the Go source file for 'v' at 'path', or with 'check' verifies it is current.
+// Untracked or modified, the file itself doesn't make the work tree dirty.
+// With 'tagOnly', checking compares only the constants of the tag, so the file may be committed, see goFileCommitConsts.
+func writeGoFile(path, pkg, dir string, config *gitvv.Config, check, tagOnly bool) error {
+	if pkg == "" {
+		pkg = goFilePackage(path)
+	}
+	if !token.IsIdentifier(pkg) {
+		return fmt.Errorf("invalid package name '%s'", pkg)
+	}
+	v, e := gitvv.GetVersionInfo(dir, config)
+	if e != nil {
+		return e
+	}
+	abs, e := filepath.Abs(path)
+	if e != nil {
+		return e
+	}
+	if v.Dirty, e = gitvv.IsDirty(dir, abs); e != nil {
+		return e
+	}
+	b, e := goFile(pkg, v)
+	if e != nil {
+		return e
+	}
+	if check {
+		old, e := ioutil.ReadFile(path)
+		if e != nil {
+			return e
+		}
+		got, e := goFileConsts(old)
+		if e != nil {
+			return fmt.Errorf("%s: %v", path, e)
+		}
+		want, e := goFileConsts(b)
+		if e != nil {
+			return e
+		}
+		for name, v := range want {
+			if tagOnly && goFileCommitConsts[name] {
+				continue
+			}
+			g, ok := got[name]
+			if !ok {
+				return fmt.Errorf("%s: %s is missing: %v", path, name, errGoFileOutdated)
+			}
+			if g != v {
+				return fmt.Errorf("%s: %s is %s, want %s: %v", path, name, g, v, errGoFileOutdated)
+			}
+		}
+		return nil
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// git runs git in 'dir' with a test identity.
+func git(t *testing.T, dir string, args ...string) {
+	base := []string{"-C", dir, "-c", "user.name=Janus", "-c", "user.email=janus@example.com", "-c", "commit.gpgsign=false", "-c", "tag.gpgsign=false"}
+	if out, e := exec.Command("git", append(base, args...)...).CombinedOutput(); e != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), e, out)
+	}
+}
+
+func TestWriteGoFile_committed(t *testing.T) {
+	if _, e := exec.LookPath("git"); e != nil {
+		t.Skip("git not found")
+	}
+	dir, e := ioutil.TempDir("", "janus-gofile")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	git(t, dir, "init", "-q")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "one")
+	git(t, dir, "tag", "v3.5.0")
+
+	path := filepath.Join(dir, "version_gen.go")
+	if e := writeGoFile(path, "version", dir, nil, false, false); e != nil {
+		t.Fatal(e)
+	}
+	if e := writeGoFile(path, "version", dir+"/./", nil, true, false); e != nil {
+		t.Errorf("written file: %v", e)
+	}
+	b, e := ioutil.ReadFile(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	consts, e := goFileConsts(b)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if consts["Tag"] != `"v3.5.0"` || consts["CommitCount"] != "0" || consts["Dirty"] != "false" {
+		t.Errorf("unexpected file:\n%s", b)
+	}
+
+	// Committing the file changes the commit, and the version, but not the tag.
+	// Each step spells the directory differently, as git results are cached per directory.
+	git(t, dir, "add", "version_gen.go")
+	git(t, dir, "commit", "-q", "-m", "two")
+	if e := writeGoFile(path, "version", dir+"/", nil, true, true); e != nil {
+		t.Errorf("committed file: %v", e)
+	}
+	if e := writeGoFile(path, "version", dir+"//", nil, true, false); e == nil || !strings.Contains(e.Error(), errGoFileOutdated.Error()) {
+		t.Errorf("committed file, of all constants: got: %v, want: %v", e, errGoFileOutdated)
+	}
+
+	git(t, dir, "tag", "v3.5.1")
+	if e := writeGoFile(path, "version", dir+"/.", nil, true, true); e == nil || !strings.Contains(e.Error(), errGoFileOutdated.Error()) {
+		t.Errorf("got: %v, want: %v", e, errGoFileOutdated)
+	}
+
+	if e := ioutil.WriteFile(path, []byte("package version\n"), 0644); e != nil {
+		t.Fatal(e)
+	}
+	if e := writeGoFile(path, "version", dir, nil, true, false); e == nil {
+		t.Error("want error for a file without constants")
+	}
+}
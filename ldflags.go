@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ETCDEVTeam/janus/gitvv"
+)
+
+// defaultLdflagsPackage is the import path of the package whose variables ldflags sets by default.
+const defaultLdflagsPackage = "github.com/ETCDEVTeam/janus/buildinfo"
+
+// ldflags gets the linker flags setting the Version, Commit, Date and Dirty string variables of 'pkg'.
+// 'version' is the formatted version, which may differ from v.Version.
+func ldflags(pkg, version string, v *gitvv.Version) string {
+	vars := []struct{ name, value string }{
+		{"Version", version},
+		{"Commit", v.Commit},
+		{"Date", v.Date},
+		{"Dirty", strconv.FormatBool(v.Dirty)},
+	}
+	var flags []string
+	for _, x := range vars {
+		flags = append(flags, "-X", quoteLdflag(pkg+"."+x.name+"="+x.value))
+	}
+	return strings.Join(flags, " ")
+}
+
+// quoteLdflag quotes an argument with spaces or quotes as the go tool splits -ldflags.
+func quoteLdflag(s string) string {
+	if !strings.ContainsAny(s, " \t\n'\"") {
+		return s
+	}
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'"
+	}
+	return `"` + s + `"`
+}
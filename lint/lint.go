@@ -0,0 +1,157 @@
+// Package lint checks commit messages against conventional commit rules,
+// eg. "fix(eth): reject underpriced txs".
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultTypes are the conventional commit types allowed if none are configured.
+var DefaultTypes = []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}
+
+// DefaultMaxSubjectLength is the maximum subject length if none is configured.
+const DefaultMaxSubjectLength = 72
+
+// Rule names of violations.
+const (
+	RuleFormat        = "format"         // subject is 'type(scope)!: description'
+	RuleType          = "type"           // type is one of Rules.Types
+	RuleScope         = "scope"          // scope is one of Rules.Scopes, and given if required
+	RuleSubjectLength = "subject-length" // subject is at most Rules.MaxSubjectLength characters
+	RuleBody          = "body"           // body is separated from the subject by a blank line
+	RuleTrailer       = "trailer"        // each of Rules.Trailers is present
+)
+
+// Rules configure which commit messages are valid.
+type Rules struct {
+	// Types are the allowed types. Default: DefaultTypes
+	Types []string `json:"types"`
+	// Scopes are the allowed scopes, any if empty.
+	Scopes []string `json:"scopes"`
+	// RequireScope requires every commit to have a scope.
+	RequireScope bool `json:"require_scope"`
+	// MaxSubjectLength is the maximum length of the subject line in characters. Default: DefaultMaxSubjectLength
+	MaxSubjectLength int `json:"max_subject_length"`
+	// Trailers are trailer keys every message must have, eg. Signed-off-by
+	Trailers []string `json:"trailers"`
+}
+
+// Violation is a broken rule.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return v.Rule + ": " + v.Message
+}
+
+// Message is a parsed conventional commit message.
+type Message struct {
+	Type        string
+	Scope       string
+	Breaking    bool
+	Description string
+	Body        string
+	// Trailers are the key: value lines of the last paragraph, eg. Signed-off-by
+	Trailers map[string][]string
+}
+
+var (
+	reHeader  = regexp.MustCompile(`^([A-Za-z]+)(?:\(([^()]*)\))?(!)?: (.*)$`)
+	reTrailer = regexp.MustCompile(`^([A-Za-z0-9-]+|BREAKING CHANGE): (.*)$`)
+)
+
+// Parse parses a commit message. It returns an error if the subject isn't 'type(scope)!: description'.
+func Parse(msg string) (*Message, error) {
+	lines := strings.Split(strings.Replace(msg, "\r\n", "\n", -1), "\n")
+	m := &Message{Trailers: make(map[string][]string)}
+	h := reHeader.FindStringSubmatch(lines[0])
+	if h == nil {
+		return nil, fmt.Errorf("subject '%s' is not 'type(scope): description'", lines[0])
+	}
+	m.Type, m.Scope, m.Breaking, m.Description = h[1], h[2], h[3] == "!", strings.TrimSpace(h[4])
+	if len(lines) > 1 {
+		m.Body = strings.TrimSpace(strings.Join(lines[1:], "\n"))
+	}
+
+	// Trailers are the last paragraph of the body, if all its lines are trailers.
+	paras := strings.Split(m.Body, "\n\n")
+	last := paras[len(paras)-1]
+	trailers := make(map[string][]string)
+	for _, l := range strings.Split(last, "\n") {
+		t := reTrailer.FindStringSubmatch(l)
+		if t == nil {
+			trailers = nil
+			break
+		}
+		key := strings.ToLower(t[1])
+		trailers[key] = append(trailers[key], t[2])
+	}
+	if trailers != nil && last != "" {
+		m.Trailers = trailers
+	}
+	if len(m.Trailer("BREAKING CHANGE")) > 0 || len(m.Trailer("BREAKING-CHANGE")) > 0 {
+		m.Breaking = true
+	}
+	return m, nil
+}
+
+// Trailer returns the values of a trailer, matching its key case-insensitively.
+func (m *Message) Trailer(key string) []string {
+	return m.Trailers[strings.ToLower(key)]
+}
+
+// Check checks a commit message against the rules.
+func (r *Rules) Check(msg string) []Violation {
+	var vs []Violation
+	subject := strings.SplitN(msg, "\n", 2)[0]
+	max := r.MaxSubjectLength
+	if max == 0 {
+		max = DefaultMaxSubjectLength
+	}
+	if n := len([]rune(subject)); n > max {
+		vs = append(vs, Violation{RuleSubjectLength, fmt.Sprintf("subject is %d characters, want at most %d", n, max)})
+	}
+	if lines := strings.SplitN(msg, "\n", 3); len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		vs = append(vs, Violation{RuleBody, "body must be separated from the subject by a blank line"})
+	}
+
+	m, e := Parse(msg)
+	if e != nil {
+		return append(vs, Violation{RuleFormat, e.Error()})
+	}
+	if m.Description == "" {
+		vs = append(vs, Violation{RuleFormat, "description is empty"})
+	}
+	types := r.Types
+	if len(types) == 0 {
+		types = DefaultTypes
+	}
+	if !contains(types, m.Type) {
+		vs = append(vs, Violation{RuleType, fmt.Sprintf("type '%s' is not one of: %s", m.Type, strings.Join(types, ", "))})
+	}
+	if m.Scope == "" && r.RequireScope {
+		vs = append(vs, Violation{RuleScope, "scope is required"})
+	}
+	if m.Scope != "" && len(r.Scopes) > 0 && !contains(r.Scopes, m.Scope) {
+		vs = append(vs, Violation{RuleScope, fmt.Sprintf("scope '%s' is not one of: %s", m.Scope, strings.Join(r.Scopes, ", "))})
+	}
+	for _, t := range r.Trailers {
+		if len(m.Trailer(t)) == 0 {
+			vs = append(vs, Violation{RuleTrailer, fmt.Sprintf("trailer '%s' is missing", t)})
+		}
+	}
+	return vs
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
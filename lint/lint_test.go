@@ -0,0 +1,72 @@
+package lint
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	m, e := Parse("fix(eth)!: reject underpriced txs\n\nLong description.\n\nReviewed-by: Janus\nSigned-off-by: A <a@example.com>\nSigned-off-by: B <b@example.com>")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if m.Type != "fix" || m.Scope != "eth" || !m.Breaking || m.Description != "reject underpriced txs" {
+		t.Errorf("unexpected message: %+v", m)
+	}
+	if got := m.Trailer("signed-off-by"); !reflect.DeepEqual(got, []string{"A <a@example.com>", "B <b@example.com>"}) {
+		t.Errorf("unexpected trailers: %v", got)
+	}
+
+	m, e = Parse("feat: add stamp\n\nBREAKING CHANGE: stamp replaces -file")
+	if e != nil || !m.Breaking || m.Scope != "" {
+		t.Errorf("unexpected message: %+v, %v", m, e)
+	}
+	m, e = Parse("docs: note\n\nSee: the docs\nand more")
+	if e != nil || len(m.Trailers) != 0 {
+		t.Errorf("prose taken for trailers: %+v, %v", m, e)
+	}
+
+	for _, s := range []string{"Fix bad tx", "fix bad tx", "fix(eth) : x", ""} {
+		if _, e := Parse(s); e == nil {
+			t.Errorf("%q: want error", s)
+		}
+	}
+}
+
+func TestRules_Check(t *testing.T) {
+	rules := &Rules{
+		Scopes:           []string{"eth", "core"},
+		MaxSubjectLength: 40,
+		Trailers:         []string{"Signed-off-by"},
+	}
+	signed := "\n\nSigned-off-by: Janus <janus@example.com>"
+	table := []struct {
+		msg  string
+		want []string
+	}{
+		{"fix(eth): reject underpriced txs" + signed, nil},
+		{"fix: reject underpriced txs" + signed, nil},
+		{"Fix bad tx" + signed, []string{RuleFormat}},
+		{"fixed(eth): bad tx" + signed, []string{RuleType}},
+		{"fix(p2p): bad tx" + signed, []string{RuleScope}},
+		{"fix(eth): " + strings.Repeat("x", 40) + signed, []string{RuleSubjectLength}},
+		{"fix(eth): bad tx", []string{RuleTrailer}},
+		{"fix(eth): bad tx\nmore" + signed, []string{RuleBody}},
+		{"fix(eth): " + signed, []string{RuleFormat}},
+	}
+	for _, tt := range table {
+		var got []string
+		for _, v := range rules.Check(tt.msg) {
+			got = append(got, v.Rule)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got: %v, want: %v", tt.msg, got, tt.want)
+		}
+	}
+
+	rules.RequireScope = true
+	if vs := rules.Check("fix: bad tx" + signed); len(vs) != 1 || vs[0].Rule != RuleScope {
+		t.Errorf("want scope required, got: %v", vs)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ETCDEVTeam/janus/gitvv"
+	"github.com/ETCDEVTeam/janus/lint"
+)
+
+// errLintViolations is returned by lintCommits if any commit breaks a rule.
+var errLintViolations = errors.New("commit messages break lint rules")
+
+// commitLint is the lint result of a commit.
+type commitLint struct {
+	Commit     string           `json:"commit"`
+	Subject    string           `json:"subject"`
+	Violations []lint.Violation `json:"violations"`
+}
+
+// lintCommits checks the commits of 'revRange' in 'dir' (since the last tag if empty) and writes the results as text or JSON.
+func lintCommits(w io.Writer, dir, revRange string, rules *lint.Rules, output string) error {
+	if output != "text" && output != "json" {
+		return fmt.Errorf("unknown output format '%s', want text or json", output)
+	}
+	commits, e := gitvv.ListCommits(dir, revRange)
+	if e != nil {
+		return e
+	}
+	results := []commitLint{}
+	failed := 0
+	for _, c := range commits {
+		vs := rules.Check(c.Message)
+		if vs == nil {
+			vs = []lint.Violation{}
+		}
+		if len(vs) > 0 {
+			failed++
+		}
+		results = append(results, commitLint{Commit: c.Hash, Subject: c.Subject(), Violations: vs})
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if e := enc.Encode(results); e != nil {
+			return e
+		}
+	} else {
+		for _, r := range results {
+			if len(r.Violations) == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "%s %s\n", r.Commit[:7], gitvv.SanitizeDisplay(r.Subject))
+			for _, v := range r.Violations {
+				fmt.Fprintf(w, "  - %s\n", v)
+			}
+		}
+		fmt.Fprintf(w, "%d of %d commits break lint rules\n", failed, len(results))
+	}
+	if failed > 0 {
+		return errLintViolations
+	}
+	return nil
+}
@@ -1,12 +1,24 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/ETCDEVTeam/janus/gcp"
+	"github.com/ETCDEVTeam/janus/deploy"
+	_ "github.com/ETCDEVTeam/janus/gcp"
 	"github.com/ETCDEVTeam/janus/gitvv"
+	"github.com/ETCDEVTeam/janus/lint"
+	"github.com/ETCDEVTeam/janus/releases"
+	_ "github.com/ETCDEVTeam/janus/s3"
+	"github.com/ETCDEVTeam/janus/sign"
+	"github.com/ETCDEVTeam/janus/stamp"
+	_ "github.com/ETCDEVTeam/janus/webdav"
 )
 
 func main() {
@@ -14,17 +26,69 @@ func main() {
 	// Subcommands
 	deployCommand := flag.NewFlagSet("deploy", flag.ExitOnError)
 	versionCommand := flag.NewFlagSet("version", flag.ExitOnError)
+	versionsCommand := flag.NewFlagSet("versions", flag.ExitOnError)
+	semverCommand := flag.NewFlagSet("semver", flag.ExitOnError)
+	stampCommand := flag.NewFlagSet("stamp", flag.ExitOnError)
+	ldflagsCommand := flag.NewFlagSet("ldflags", flag.ExitOnError)
+	lintCommand := flag.NewFlagSet("lint-commits", flag.ExitOnError)
+	signCommand := flag.NewFlagSet("sign", flag.ExitOnError)
+	verifyCommand := flag.NewFlagSet("verify", flag.ExitOnError)
 
 	// Deploy flags
-	var key, files, to string
+	var key, files, to, mode, checksums string
 	var gpg bool
+	var endpoint, region string
+	var pathStyle bool
+	var partSize int64
+	var deployFlags versionOptions
+	var deploySignFlags signOptions
+	var draft, mkcol bool
+	var parallel, retries int
+	var chunkSize, ifGenerationMatch int64
+	var timeout time.Duration
+	var clobber clobberFlag
 	// Version flags
-	var dir, format string
+	var versionFlags versionOptions
+	var format string
+	var versionJSON bool
+	var goFilePath, goFilePkg string
+	var goFileCheck, goFileCheckTag bool
+	var dockerTags bool
+	var export, exportPath, exportBase string
+	var recursive, requireTaggedSubmodules bool
+	// Versions flags
+	var versionsFlags versionOptions
+	var output, line string
+	// Stamp flags
+	var stampFlags versionOptions
+	var stampFormat string
+	var stampFiles stringsFlag
+	var stampCheck bool
+	// Ldflags flags
+	var ldflagsFlags versionOptions
+	var ldflagsFormat, ldflagsPkg string
+	// Lint-commits flags
+	var lintDir, lintConfig, lintRange, lintOutput, lintTypes, lintScopes string
+	var lintRequireScope bool
+	var lintMaxSubjectLength int
+	var lintTrailers stringsFlag
+	// Sign flags
+	var signFiles string
+	var signFlags signOptions
+	// Verify flags
+	var verifyFiles, verifyChecksums, verifyKeyring, verifySigner, verifyExt, verifyNamespace, verifyOutput string
 
 	// Set up flags.
 	//
 	// Deploy
-	deployCommand.StringVar(&to, "to", "", `directory path to deploy files to
+	deployCommand.StringVar(&to, "to", "", `directory path to deploy files to, as a URL selecting the storage backend:
+gs://<bucket>/<path> - GCP Storage, the default if no scheme is given
+s3://<bucket>/<path> - Amazon S3, or S3-compatible storage at -endpoint, eg. MinIO
+file://<directory> - local filesystem, eg. a NFS-hosted mirror
+github://<owner>/<repo>[/<tag>] - assets of the GitHub release of <tag>, created if needed
+gitea://<owner>/<repo>[/<tag>] - assets of the Gitea release of <tag> at -endpoint
+  without <tag>, the tag on HEAD, else v%V as a draft
+https://<host>/<path> - HTTP PUT, eg. Artifactory or Nexus raw repositories, or WebDAV
 
 the first directory in the given path is GCP <bucket>
 files will be uploaded INTO this path
@@ -37,10 +101,33 @@ eg.
 --> builds.etcdevteam.com/go-ethereum/releases/v3.5.x/geth-osx.zip
 `)
 	deployCommand.StringVar(&files, "files", "", "file(s) to upload, allows globbing")
-	deployCommand.StringVar(&key, "key", "", "service account json key file, may be encrypted OR decrypted")
+	deployCommand.StringVar(&key, "key", "", `service account json key file, may be encrypted OR decrypted
+for s3://, a json file of {"access_key_id": "...", "secret_access_key": "..."}, else env AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are used
+for github:// and gitea://, a json file of {"token": "..."}, else env GITHUB_TOKEN or GITEA_TOKEN is used
+for https://, a json file of {"username": "...", "password": "..."} or {"token": "..."},
+else env JANUS_HTTP_USERNAME and JANUS_HTTP_PASSWORD, or JANUS_HTTP_TOKEN are used`)
 	deployCommand.BoolVar(&gpg, "gpg", false, "use GPG 2 instead of openssl for decryption")
+	deployCommand.StringVar(&endpoint, "endpoint", "", `URL of S3-compatible storage for s3://, eg. https://minio.example.com:9000 (default $AWS_ENDPOINT_URL, else AWS S3)
+API base URL for github:// (default $GITHUB_API_URL, else https://api.github.com) or gitea://, eg. https://gitea.example.com`)
+	deployCommand.BoolVar(&draft, "draft", false, "create releases of github:// and gitea:// as drafts")
+	deployCommand.StringVar(&checksums, "checksums", "", "comma separated checksums of the files to upload manifests of to the -to directory, merged with existing ones, eg. sha256,sha512 uploads SHA256SUMS and SHA512SUMS")
+	deployCommand.IntVar(&parallel, "parallel", deploy.DefaultParallel, "number of files uploaded concurrently")
+	deployCommand.IntVar(&retries, "retries", deploy.DefaultRetries, "number of retries of operations failing with network errors, throttling or server errors, with exponential backoff")
+	deployCommand.Int64Var(&chunkSize, "chunk-size", 16, "size in MiB of the chunks of resumable uploads to gs://, 0 uploads in a single request")
+	deployCommand.DurationVar(&timeout, "timeout", 0, "overall timeout of the deploy, eg. 10m (default none)")
+	deployCommand.Var(&clobber, "no-clobber", `don't replace existing objects, failing their uploads, or skipping them with -no-clobber=skip
+-no-clobber=false replaces them (default only objects of version-tagged paths, eg. go-ethereum/v3.5.0 or github:// release tags, aren't replaced,
+unless of builds of -channel pre-releases which aren't tags of the repo, eg. go-ethereum/3.6.0-beta.14)`)
+	deployCommand.Int64Var(&ifGenerationMatch, "if-generation-match", 0, "replace the object of the single file uploaded only if it is of this generation, also of version-tagged paths, eg. from gsutil stat (gs:// and file://)")
+	deployCommand.BoolVar(&mkcol, "mkcol", false, "create missing directories of https:// with WebDAV MKCOL")
+	deployFlags.register(deployCommand)
+	deploySignFlags.register(deployCommand)
+	deployCommand.StringVar(&region, "region", "", "region of s3:// (default $AWS_REGION, else us-east-1)")
+	deployCommand.BoolVar(&pathStyle, "path-style", false, "address s3:// buckets as <endpoint>/<bucket> instead of <bucket>.<endpoint>, as needed by eg. MinIO")
+	deployCommand.Int64Var(&partSize, "part-size", 16, "size in MiB of the parts of multipart uploads to s3://, larger files are uploaded in parts")
+	deployCommand.StringVar(&mode, "mode", "0644", "octal permissions of files written to file://, directories get search permission where readable")
 	// Version
-	versionCommand.StringVar(&dir, "dir", "", `path to base directory`)
+	versionFlags.register(versionCommand)
 	versionCommand.StringVar(&format, "format", "", `format of git version:
 
 %M - major version
@@ -49,20 +136,122 @@ eg.
 %C - commit count since last tag
 %S[|NUMBER] - HEAD sha1, where NUMBER is optional desired length of hash (default: 7)
 %B - hybrid patch number (B = semver_minor_version*100 + commit_count)
+%V - version derived by -scheme, from last tag and branch channel (semver, see -channel) or HEAD commit date (calver)
+%{TOKEN} - calver layout token of %V, eg. %{YYYY}, %{0M}, %{MICRO}
+%{name} - tag and commit metadata, sanitized for display, or for file names as %{name:file}:
+  tag_subject, tag_message, tagger_name, tagger_email,
+  subject, author_name, author_email, committer_name, committer_email
+
+TAG_OR_NIGHTLY - v%M.%m.%P-%S on a tag, else v%M.%m.%P+%C-%S
+SEMVER - %V, eg. 3.5.1 on a tag, 3.6.0-beta.14 above it on master
 
 Default: v%M.%m.%P+%C-%S -> v3.5.0+66-bbb06b1
 `)
+	versionCommand.BoolVar(&versionJSON, "json", false, `print all version fields as JSON instead of -format`)
+	versionCommand.StringVar(&goFilePath, "gofile", "", `write a Go source file with constants for all version fields instead of printing, eg.
+
+//go:generate janus version -gofile version_gen.go
+`)
+	versionCommand.StringVar(&goFilePkg, "package", "", `package of -gofile (default: $GOPACKAGE, else the package of other files in its directory, else main)`)
+	versionCommand.BoolVar(&goFileCheck, "check", false, `do not write -gofile, fail if it is not current`)
+	versionCommand.BoolVar(&goFileCheckTag, "check-tag", false, `with -check, compare only the constants of the tag, eg. Tag and Major, not those of the commit, eg. Version and Commit,
+so -gofile may be committed after tagging`)
+	versionCommand.BoolVar(&recursive, "recursive", false, `also version all submodules, recursively, printing a report as a table, or with -json as JSON
+-dir may be given multiple times for a report of several repositories`)
+	versionCommand.BoolVar(&requireTaggedSubmodules, "require-tagged-submodules", false, `with -recursive, fail if any submodule is not on a version tag, eg. for a release`)
+	versionCommand.StringVar(&export, "export", "", `print all version fields as variable assignments instead of -format, one of:
+sh, powershell, cmd, dotenv, github-output, gitlab
+
+VERSION (as per -format), VERSION_BASE, TAG_OR_NIGHTLY, SEMVER, TAG, MAJOR, MINOR, PATCH,
+COMMIT_COUNT, COMMIT, COMMIT_SHORT, DATE, DIRTY, BRANCH, and metadata, eg. TAG_SUBJECT
+
+eg.
+eval "$(janus version -export sh)"
+janus.exe version -export powershell | Out-String | Invoke-Expression
+`)
+	versionCommand.StringVar(&exportPath, "export-file", "", `append -export to this file instead of printing (default: $GITHUB_OUTPUT for github-output)`)
+	versionCommand.StringVar(&exportBase, "export-base", "v%M.%m.x", `format of VERSION_BASE for -export, eg. %M.%m.x for deploy paths of the unprefixed base`)
+	versionCommand.BoolVar(&dockerTags, "docker-tags", false, `print Docker image tags, one per line, instead of -format:
+on a release tag: the version, and M.m, M and latest if it is the highest stable version in those lines
+above a tag: %V and the branch, with invalid characters replaced by '-'
+`)
+	// Versions
+	versionsFlags.register(versionsCommand)
+	versionsCommand.StringVar(&output, "output", "table", `output format: table, json`)
+	versionsCommand.StringVar(&line, "line", "", `only list versions of a major or minor line, eg. 3 or 3.5`)
+	// Stamp
+	stampFlags.register(stampCommand)
+	stampCommand.StringVar(&stampFormat, "format", "%V", `format of the version written, see version -format`)
+	stampCommand.Var(&stampFiles, "file", `file to stamp, may be repeated, added to the stamp section of the config:
+
+TYPE:FILE:KEY
+
+TYPE - updater: `+strings.Join(stamp.Types(), ", ")+`
+FILE - path, relative to -dir
+KEY - JSON path, TOML/YAML dotted key, Go const or var name, regex with a capture group,
+      XML element path or plist key
+
+eg.
+-file json:package.json:version -file go:params/version.go:Version \
+-file 'regex:res/geth.rc:FILEVERSION (\d+,\d+,\d+)' -file plist:Info.plist:CFBundleShortVersionString
+`)
+	stampCommand.BoolVar(&stampCheck, "check", false, `do not write files, fail if any file disagrees with the git version`)
+	// Ldflags
+	ldflagsFlags.register(ldflagsCommand)
+	ldflagsCommand.StringVar(&ldflagsFormat, "format", "%V", `format of the Version variable, see version -format`)
+	ldflagsCommand.StringVar(&ldflagsPkg, "pkg", defaultLdflagsPackage, `import path of the package with Version, Commit, Date and Dirty string variables
+
+eg.
+go build -ldflags "$(janus ldflags -pkg main)"
+--> -X main.Version=3.6.0-beta.14 -X main.Commit=bbb06b1... -X main.Date=2018-06-08T09:51:40Z -X main.Dirty=false
+`)
+
+	// Lint-commits
+	lintCommand.StringVar(&lintDir, "dir", "", `path to base directory`)
+	lintCommand.StringVar(&lintConfig, "config", "", `path to JSON config file with a lint section (default: <dir>/`+defaultConfigFile+` if exists)`)
+	lintCommand.StringVar(&lintRange, "range", "", `commits to check, eg. v3.5.0..HEAD (default: since the last tag)`)
+	lintCommand.StringVar(&lintOutput, "output", "text", `output format: text, json`)
+	lintCommand.StringVar(&lintTypes, "types", "", `comma-separated allowed types (default: `+strings.Join(lint.DefaultTypes, ",")+`)`)
+	lintCommand.StringVar(&lintScopes, "scopes", "", `comma-separated allowed scopes (default: any)`)
+	lintCommand.BoolVar(&lintRequireScope, "require-scope", false, `require every commit to have a scope`)
+	lintCommand.IntVar(&lintMaxSubjectLength, "max-subject-length", 0, fmt.Sprintf("maximum subject length (default: %d)", lint.DefaultMaxSubjectLength))
+	lintCommand.Var(&lintTrailers, "trailer", `required trailer, may be repeated, eg. Signed-off-by`)
+
+	// Sign
+	signCommand.StringVar(&signFiles, "files", "", "file(s) to sign, can use relative or absolute path and/or wildcard globbing")
+	signFlags.register(signCommand)
+
+	// Verify
+	verifyCommand.StringVar(&verifyFiles, "files", "", "file(s) to verify, can use relative or absolute path and/or wildcard globbing")
+	verifyCommand.StringVar(&verifyChecksums, "checksums", "", "manifest of checksums the files must match, eg. SHA256SUMS, whose signature is verified with -keyring too")
+	verifyCommand.StringVar(&verifyKeyring, "keyring", "", `trusted keys of -signer, verifying the signatures next to the files, eg. geth.zip.asc:
+pgp - OpenPGP keyring (armored or binary)
+minisign - minisign public keys
+ssh - allowed signers file, as of ssh-keygen -Y verify, or public keys`)
+	verifyCommand.StringVar(&verifySigner, "signer", "pgp", "type of signatures: "+strings.Join(sign.Types(), ", "))
+	verifyCommand.StringVar(&verifyExt, "sig-ext", "", "extension of signature files, eg. .sig (default by -signer)")
+	verifyCommand.StringVar(&verifyNamespace, "ssh-namespace", sign.DefaultSSHNamespace, "namespace of ssh signatures")
+	verifyCommand.StringVar(&verifyOutput, "output", "text", `output format: text, json`)
 
 	flag.Usage = func() {
 		fmt.Println("Usage for Janus:")
 		fmt.Println("  $ janus deploy -to builds.etcdevteam.com/go-ethereum/version -file geth.zip -key .gcloud.json")
 		fmt.Println("  $ janus version -format 'v%M.%m.%P+%C-%S'")
+		fmt.Println("  $ janus versions -line 3.5 -output json")
+		fmt.Println("  $ janus semver compare 3.5.0 3.6.0-beta.1")
+		fmt.Println("  $ janus semver satisfies 3.5.1 '>=3.5.0 <4'")
+		fmt.Println("  $ git tag | janus semver sort [-r]")
+		fmt.Println("  $ janus stamp -file json:package.json:version [-check]")
+		fmt.Println("  $ go build -ldflags \"$(janus ldflags -pkg main)\"")
+		fmt.Println("  $ janus lint-commits -trailer Signed-off-by [-range v3.5.0..HEAD]")
+		fmt.Println("  $ janus sign -files './dist/*.zip' -sign-key release.asc")
+		fmt.Println("  $ janus verify -files './dist/*.zip' -checksums dist/SHA256SUMS -keyring trusted.asc")
 		flag.PrintDefaults()
 	}
 
 	// Ensure subcommand is used.
 	if len(os.Args) < 2 {
-		fmt.Println("'deploy' or 'version' subcommand is required")
+		fmt.Println("'deploy', 'version', 'versions', 'semver', 'stamp', 'ldflags', 'lint-commits', 'sign' or 'verify' subcommand is required")
 		os.Exit(1)
 	}
 
@@ -72,6 +261,20 @@ Default: v%M.%m.%P+%C-%S -> v3.5.0+66-bbb06b1
 		deployCommand.Parse(os.Args[2:])
 	case "version":
 		versionCommand.Parse(os.Args[2:])
+	case "versions":
+		versionsCommand.Parse(os.Args[2:])
+	case "semver":
+		semverCommand.Parse(os.Args[2:])
+	case "stamp":
+		stampCommand.Parse(os.Args[2:])
+	case "ldflags":
+		ldflagsCommand.Parse(os.Args[2:])
+	case "lint-commits":
+		lintCommand.Parse(os.Args[2:])
+	case "sign":
+		signCommand.Parse(os.Args[2:])
+	case "verify":
+		verifyCommand.Parse(os.Args[2:])
 	default:
 		flag.Usage()
 		os.Exit(1)
@@ -92,15 +295,87 @@ Default: v%M.%m.%P+%C-%S -> v3.5.0+66-bbb06b1
 			flag.Usage()
 			os.Exit(1)
 		}
-		if key == "" {
+		l, e := deploy.ParseLocation(to)
+		if e != nil {
+			fmt.Println(e)
+			os.Exit(1)
+		}
+		if key == "" && l.Scheme == deploy.SchemeGCS {
 			fmt.Println("--key requires an argument")
 			flag.Usage()
 			os.Exit(1)
 		}
 
+		m, e := strconv.ParseUint(mode, 8, 32)
+		if e != nil || os.FileMode(m) != os.FileMode(m).Perm() {
+			fmt.Println("--mode requires octal permissions, eg. 0644")
+			os.Exit(1)
+		}
+
+		algs, e := deploy.ParseChecksums(checksums)
+		if e != nil {
+			fmt.Println(e)
+			os.Exit(1)
+		}
+
+		signer, e := deploySignFlags.newSigner()
+		if e != nil {
+			fmt.Println(e)
+			os.Exit(1)
+		}
+
+		retry := deploy.DefaultRetry
+		retry.Retries = retries
+		if chunkSize == 0 {
+			chunkSize = -1
+		}
+
+		opts := deploy.Options{
+			Key:               key,
+			GPG:               gpg,
+			Mode:              os.FileMode(m),
+			Endpoint:          endpoint,
+			Region:            region,
+			PathStyle:         pathStyle,
+			PartSize:          partSize << 20,
+			Draft:             draft,
+			MkCol:             mkcol,
+			Parallel:          parallel,
+			Retry:             &retry,
+			ChunkSize:         chunkSize << 20,
+			Checksums:         algs,
+			Signer:            signer,
+			Clobber:           string(clobber),
+			IfGenerationMatch: ifGenerationMatch,
+		}
+		vc, e := deployFlags.versionConfig()
+		if e != nil {
+			fmt.Println(e)
+			os.Exit(1)
+		}
+		// Builds of channels stay overwritable, unless of a release tag.
+		opts.Channels = gitvv.ChannelPres(vc.Channels)
+		if tags, e := gitvv.ListVersionTags(deployFlags.dir, nil); e == nil {
+			for _, t := range tags {
+				opts.Tags = append(opts.Tags, t.Name)
+			}
+		}
+		if releases.IsScheme(l.Scheme) {
+			if to, e = releaseDestination(l, deployFlags.dir, vc, &opts); e != nil {
+				fmt.Println(e)
+				os.Exit(1)
+			}
+		}
+
 		// Handle deploy.
 		// -- Will check for existing file(s) to upload, will return error if not exists.
-		if e := gcp.SendToGCP(to, files, key, gpg); e != nil {
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		e = deploy.Deploy(ctx, to, files, opts)
+		cancel()
+		if e != nil {
 			fmt.Println("Failed to deploy:")
 			fmt.Println(e)
 			os.Exit(1)
@@ -108,10 +383,257 @@ Default: v%M.%m.%P+%C-%S -> v3.5.0+66-bbb06b1
 	} else
 	// Version
 	if versionCommand.Parsed() {
-		v := gitvv.GetVersion(format, dir)
+		vc, e := versionFlags.versionConfig()
+		if e != nil {
+			fmt.Println(e)
+			os.Exit(1)
+		}
+
+		if recursive || len(versionFlags.dirs) > 1 {
+			dirs := versionFlags.dirs
+			if len(dirs) == 0 {
+				dirs = []string{"."}
+			}
+			rs := getRepoVersions(dirs, recursive, format, &versionFlags)
+			if e := writeRepoVersions(os.Stdout, rs, versionJSON); e != nil {
+				fmt.Println(e)
+				os.Exit(1)
+			}
+			failed := false
+			for _, r := range rs {
+				if r.Error != "" {
+					failed = true
+				} else if r.Submodule && requireTaggedSubmodules && r.untagged() {
+					fmt.Fprintf(os.Stderr, "%s: submodule is not on a version tag (%s)\n", r.Dir, r.Formatted)
+					failed = true
+				}
+			}
+			if failed {
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+
+		// Fail rather than version from an untrusted tag.
+		if _, _, e := gitvv.SelectTag(versionFlags.dir, vc); e != nil {
+			fmt.Println(e)
+			os.Exit(1)
+		}
+
+		if goFilePath != "" {
+			if goFileCheckTag && !goFileCheck {
+				fmt.Println("-check-tag requires -check")
+				os.Exit(1)
+			}
+			if e := writeGoFile(goFilePath, goFilePkg, versionFlags.dir, vc, goFileCheck, goFileCheckTag); e != nil {
+				fmt.Println(e)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+		if goFileCheck {
+			fmt.Println("-check requires -gofile")
+			os.Exit(1)
+		}
+
+		if export != "" {
+			vars, e := getExportVars(format, exportBase, versionFlags.dir, vc)
+			if e != nil {
+				fmt.Println(e)
+				os.Exit(1)
+			}
+			if e := exportVersion(os.Stdout, export, exportPath, vars); e != nil {
+				fmt.Println(e)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+
+		if dockerTags {
+			tags, e := gitvv.GetDockerTags(versionFlags.dir, vc)
+			if e != nil {
+				fmt.Println(e)
+				os.Exit(1)
+			}
+			for _, t := range tags {
+				fmt.Println(t)
+			}
+			os.Exit(0)
+		}
+
+		if versionJSON {
+			v, e := gitvv.GetVersionInfo(versionFlags.dir, vc)
+			if e != nil {
+				fmt.Println(e)
+				os.Exit(1)
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if e := enc.Encode(v); e != nil {
+				fmt.Println(e)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+
+		v := gitvv.GetVersionWithConfig(format, versionFlags.dir, vc)
 		fmt.Print(v)
 		os.Exit(0)
 	} else
+	// Versions
+	if versionsCommand.Parsed() {
+		vc, e := versionsFlags.versionConfig()
+		if e != nil {
+			fmt.Println(e)
+			os.Exit(1)
+		}
+		if e := listVersions(os.Stdout, versionsFlags.dir, vc, line, output); e != nil {
+			fmt.Println("Failed to list versions:")
+			fmt.Println(e)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	} else
+	// Semver
+	if semverCommand.Parsed() {
+		os.Exit(runSemver(semverCommand.Args(), os.Stdin, os.Stdout, os.Stderr))
+	} else
+	// Stamp
+	if stampCommand.Parsed() {
+		vc, e := stampFlags.versionConfig()
+		if e != nil {
+			fmt.Println(e)
+			os.Exit(1)
+		}
+		c, e := readConfig(stampFlags.config, stampFlags.dir)
+		if e != nil {
+			fmt.Println("Failed to read config:")
+			fmt.Println(e)
+			os.Exit(1)
+		}
+		targets := c.Stamp
+		for _, s := range stampFiles {
+			t, e := stamp.ParseTarget(s)
+			if e != nil {
+				fmt.Println(e)
+				os.Exit(1)
+			}
+			targets = append(targets, t)
+		}
+		if _, _, e := gitvv.SelectTag(stampFlags.dir, vc); e != nil {
+			fmt.Println(e)
+			os.Exit(1)
+		}
+		if e := runStamp(os.Stdout, targets, stampFlags.dir, vc, stampFormat, stampCheck); e != nil {
+			fmt.Println("Failed to stamp:")
+			fmt.Println(e)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	} else
+	// Ldflags
+	if ldflagsCommand.Parsed() {
+		vc, e := ldflagsFlags.versionConfig()
+		if e != nil {
+			fmt.Println(e)
+			os.Exit(1)
+		}
+		v, e := gitvv.GetVersionInfo(ldflagsFlags.dir, vc)
+		if e != nil {
+			fmt.Println(e)
+			os.Exit(1)
+		}
+		fmt.Print(ldflags(ldflagsPkg, gitvv.GetVersionWithConfig(ldflagsFormat, ldflagsFlags.dir, vc), v))
+		os.Exit(0)
+	} else
+	// Lint-commits
+	if lintCommand.Parsed() {
+		c, e := readConfig(lintConfig, lintDir)
+		if e != nil {
+			fmt.Println("Failed to read config:")
+			fmt.Println(e)
+			os.Exit(1)
+		}
+		rules := c.Lint
+		if rules == nil {
+			rules = &lint.Rules{}
+		}
+		if lintTypes != "" {
+			rules.Types = strings.Split(lintTypes, ",")
+		}
+		if lintScopes != "" {
+			rules.Scopes = strings.Split(lintScopes, ",")
+		}
+		if lintRequireScope {
+			rules.RequireScope = true
+		}
+		if lintMaxSubjectLength != 0 {
+			rules.MaxSubjectLength = lintMaxSubjectLength
+		}
+		rules.Trailers = append(rules.Trailers, lintTrailers...)
+
+		e = lintCommits(os.Stdout, lintDir, lintRange, rules, lintOutput)
+		if e == errLintViolations {
+			os.Exit(1)
+		}
+		if e != nil {
+			fmt.Println("Failed to lint commits:")
+			fmt.Println(e)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	} else
+	// Sign
+	if signCommand.Parsed() {
+		if signFiles == "" {
+			fmt.Println("--files requires an argument")
+			flag.Usage()
+			os.Exit(1)
+		}
+		if signFlags.key == "" {
+			fmt.Println("--sign-key requires an argument")
+			flag.Usage()
+			os.Exit(1)
+		}
+		s, e := signFlags.newSigner()
+		if e != nil {
+			fmt.Println(e)
+			os.Exit(1)
+		}
+		if e := runSign(os.Stdout, s, signFiles); e != nil {
+			fmt.Println("Failed to sign:")
+			fmt.Println(e)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	} else
+	// Verify
+	if verifyCommand.Parsed() {
+		if verifyFiles == "" {
+			fmt.Println("--files requires an argument")
+			flag.Usage()
+			os.Exit(1)
+		}
+		var v sign.Verifier
+		if verifyKeyring != "" {
+			var e error
+			v, e = sign.NewVerifier(verifySigner, sign.Options{Key: verifyKeyring, Ext: verifyExt, Namespace: verifyNamespace})
+			if e != nil {
+				fmt.Println(e)
+				os.Exit(1)
+			}
+		}
+		e := runVerify(os.Stdout, verifyFiles, verifyChecksums, v, verifyOutput)
+		if e == errVerifyFailed {
+			os.Exit(1)
+		}
+		if e != nil {
+			fmt.Println("Failed to verify:")
+			fmt.Println(e)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	} else
 	// No command
 	{
 		// Must use a subcommand.
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/ETCDEVTeam/janus/deploy"
+	"github.com/ETCDEVTeam/janus/gitvv"
+)
+
+// releaseDestination completes a releases destination without a tag, eg. github://ETCDEVTeam/janus,
+// with the tag on HEAD of 'dir', else with v%V of the untagged build, which is then released as a draft,
+// eg. github://ETCDEVTeam/janus/v0.3.1-dev.2
+// It sets the commit of opts, at which a release is created if its tag doesn't exist yet.
+func releaseDestination(l deploy.Location, dir string, config *gitvv.Config, opts *deploy.Options) (string, error) {
+	if l.Prefix == "" {
+		return "", fmt.Errorf("%s:// requires an owner and repository, eg. %s://ETCDEVTeam/janus", l.Scheme, l.Scheme)
+	}
+	v, e := gitvv.GetVersionInfo(dir, config)
+	if e != nil {
+		return "", e
+	}
+	opts.Commit = v.Commit
+	if path.Dir(l.Prefix) != "." {
+		return l.String(), nil
+	}
+	tag := v.Tag
+	if v.CommitCount > 0 || tag == "" {
+		tag = "v" + v.Version
+		opts.Draft = true
+	}
+	l.Prefix = path.Join(l.Prefix, tag)
+	return l.String(), nil
+}
@@ -0,0 +1,657 @@
+// Package releases is a deploy backend uploading assets to GitHub or Gitea releases,
+// for destinations like github://ETCDEVTeam/janus/v0.3.0 or gitea://ETCDEVTeam/janus/v0.3.0
+// Object names are <repo>/<tag>/<asset>, and the release of a tag is created if it doesn't exist.
+package releases
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ETCDEVTeam/janus/deploy"
+	"github.com/ETCDEVTeam/janus/gitvv"
+)
+
+const (
+	// SchemeGitHub is the URL scheme of GitHub releases, eg. github://owner/repo/tag
+	SchemeGitHub = "github"
+	// SchemeGitea is the URL scheme of Gitea releases, eg. gitea://owner/repo/tag
+	SchemeGitea = "gitea"
+)
+
+// DefaultGitHubAPI is the GitHub API base URL if neither -endpoint nor $GITHUB_API_URL are set.
+const DefaultGitHubAPI = "https://api.github.com"
+
+// pageSize is the number of releases listed per request.
+const pageSize = 100
+
+func init() {
+	deploy.Register(SchemeGitHub, Open)
+	deploy.Register(SchemeGitea, Open)
+}
+
+// IsScheme is whether 'scheme' is of a releases backend.
+func IsScheme(scheme string) bool {
+	return scheme == SchemeGitHub || scheme == SchemeGitea
+}
+
+// Backend is the releases of a GitHub or Gitea repository.
+type Backend struct {
+	client *http.Client
+	api    string
+	gitea  bool
+	owner  string
+	repo   string
+	token  string
+	commit string
+	draft  bool
+	// mu guards releases and their assets.
+	mu sync.Mutex
+	// releases caches found and created releases by tag.
+	releases map[string]*release
+}
+
+// Error is an error response of the API.
+type Error struct {
+	StatusCode int    `json:"-"`
+	Message    string `json:"message"`
+	// Errors are the causes of a 422 response, eg. {"resource": "ReleaseAsset", "code": "already_exists", "field": "name"}
+	Errors []struct {
+		Code string `json:"code"`
+	} `json:"errors"`
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("releases: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("releases: %s (%d)", e.Message, e.StatusCode)
+}
+
+// Retryable is whether the request may succeed if retried, see deploy.IsRetryable.
+func (e *Error) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// alreadyExists is whether 'e' rejects an upload of an asset whose name exists.
+func alreadyExists(e error) bool {
+	ae, ok := e.(*Error)
+	if !ok || ae.StatusCode != http.StatusUnprocessableEntity {
+		return false
+	}
+	for _, c := range ae.Errors {
+		if c.Code == "already_exists" {
+			return true
+		}
+	}
+	return false
+}
+
+type release struct {
+	ID         int64    `json:"id"`
+	TagName    string   `json:"tag_name"`
+	Draft      bool     `json:"draft"`
+	Prerelease bool     `json:"prerelease"`
+	UploadURL  string   `json:"upload_url"`
+	Assets     []*asset `json:"assets"`
+}
+
+type asset struct {
+	ID                 int64     `json:"id"`
+	Name               string    `json:"name"`
+	Size               int64     `json:"size"`
+	ContentType        string    `json:"content_type"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+	BrowserDownloadURL string    `json:"browser_download_url"`
+}
+
+// Open opens the releases of the repository of 'l', eg. github://ETCDEVTeam/janus
+// The API base URL is 'opts.Endpoint', else $GITHUB_API_URL or https://api.github.com for GitHub;
+// Gitea requires it, eg. https://gitea.example.com, to which /api/v1 is added if it has no path.
+// The token is read from the JSON file 'opts.Key', which may be encrypted, see deploy.DecryptKey,
+// eg. {"token": "..."}, else from $GITHUB_TOKEN or $GITEA_TOKEN.
+// Releases are created at 'opts.Commit', as drafts if 'opts.Draft' is set, and as pre-releases if the
+// tag is a semver pre-release.
+func Open(ctx context.Context, l deploy.Location, opts deploy.Options) (deploy.Backend, error) {
+	b := &Backend{
+		client:   http.DefaultClient,
+		gitea:    l.Scheme == SchemeGitea,
+		owner:    l.Bucket,
+		commit:   opts.Commit,
+		draft:    opts.Draft,
+		releases: make(map[string]*release),
+	}
+	b.repo = strings.SplitN(l.Prefix, "/", 2)[0]
+	if b.repo == "" {
+		return nil, fmt.Errorf("%s:// requires an owner and repository, eg. %s://ETCDEVTeam/janus", l.Scheme, l.Scheme)
+	}
+
+	b.api = opts.Endpoint
+	if b.api == "" && !b.gitea {
+		b.api = os.Getenv("GITHUB_API_URL")
+		if b.api == "" {
+			b.api = DefaultGitHubAPI
+		}
+	}
+	if b.api == "" {
+		return nil, errors.New("gitea:// requires -endpoint, eg. https://gitea.example.com")
+	}
+	u, e := url.Parse(b.api)
+	if e != nil {
+		return nil, e
+	}
+	if b.gitea && strings.Trim(u.Path, "/") == "" {
+		u.Path = "/api/v1"
+	}
+	b.api = strings.TrimSuffix(u.String(), "/")
+
+	tokenEnv := "GITHUB_TOKEN"
+	if b.gitea {
+		tokenEnv = "GITEA_TOKEN"
+	}
+	b.token, e = readToken(opts.Key, opts.GPG, tokenEnv)
+	if e != nil {
+		return nil, e
+	}
+	return b, nil
+}
+
+// readToken reads the token from the JSON file 'key', eg. {"token": "..."}, else from env 'tokenEnv'.
+func readToken(key string, gpg bool, tokenEnv string) (string, error) {
+	if key == "" {
+		if t := os.Getenv(tokenEnv); t != "" {
+			return t, nil
+		}
+		return "", fmt.Errorf("releases require -key or env %s", tokenEnv)
+	}
+	p, cleanup, e := deploy.DecryptKey(key, gpg)
+	if e != nil {
+		return "", e
+	}
+	defer cleanup()
+	data, e := ioutil.ReadFile(p)
+	if e != nil {
+		return "", e
+	}
+	var t struct {
+		Token string `json:"token"`
+	}
+	if e := json.Unmarshal(data, &t); e != nil {
+		return "", e
+	}
+	if t.Token == "" {
+		return "", fmt.Errorf("%s: no token", key)
+	}
+	return t.Token, nil
+}
+
+// split splits an object name into the release tag and asset name, eg.
+// janus/v0.3.0/janus_linux.zip -> v0.3.0, janus_linux.zip
+func (b *Backend) split(name string) (string, string, error) {
+	rest := strings.TrimPrefix(name, b.repo+"/")
+	i := strings.LastIndex(rest, "/")
+	if rest == name || i <= 0 || i == len(rest)-1 {
+		return "", "", fmt.Errorf("object name must be %s/<tag>/<asset>: %s", b.repo, name)
+	}
+	return rest[:i], rest[i+1:], nil
+}
+
+// object gets the object of an asset of the release of 'tag'.
+func (b *Backend) object(tag string, a *asset) *deploy.Object {
+	updated := a.UpdatedAt
+	if updated.IsZero() {
+		updated = a.CreatedAt
+	}
+	return &deploy.Object{
+		Name:        path.Join(b.repo, tag, a.Name),
+		Size:        a.Size,
+		ContentType: a.ContentType,
+		Updated:     updated,
+	}
+}
+
+// do sends an authorized request, decoding a JSON response into 'v' if not nil.
+func (b *Backend) do(ctx context.Context, method, u string, header http.Header, body io.Reader, size int64, v interface{}) error {
+	req, e := http.NewRequest(method, u, body)
+	if e != nil {
+		return e
+	}
+	req = req.WithContext(ctx)
+	if size >= 0 {
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Authorization", "token "+b.token)
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	res, e := b.client.Do(req)
+	if e != nil {
+		return e
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		ae := &Error{StatusCode: res.StatusCode}
+		if data, _ := ioutil.ReadAll(res.Body); len(data) > 0 {
+			json.Unmarshal(data, ae)
+		}
+		return ae
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func (b *Backend) doJSON(ctx context.Context, method, u string, in, out interface{}) error {
+	data, e := json.Marshal(in)
+	if e != nil {
+		return e
+	}
+	header := http.Header{"Content-Type": {"application/json"}}
+	return b.do(ctx, method, u, header, bytes.NewReader(data), int64(len(data)), out)
+}
+
+func (b *Backend) repoURL(p string) string {
+	return b.api + "/repos/" + url.PathEscape(b.owner) + "/" + url.PathEscape(b.repo) + p
+}
+
+// listReleases lists all releases, including drafts, which can't be got by tag.
+// Pages are read until an empty one, since servers may cap them below pageSize, eg. Gitea at 50.
+func (b *Backend) listReleases(ctx context.Context) ([]*release, error) {
+	var all []*release
+	for page := 1; ; page++ {
+		var rs []*release
+		q := url.Values{"per_page": {strconv.Itoa(pageSize)}, "limit": {strconv.Itoa(pageSize)}, "page": {strconv.Itoa(page)}}
+		if e := b.do(ctx, http.MethodGet, b.repoURL("/releases?"+q.Encode()), nil, nil, 0, &rs); e != nil {
+			return nil, e
+		}
+		if len(rs) == 0 {
+			return all, nil
+		}
+		all = append(all, rs...)
+	}
+}
+
+// findRelease finds the release of 'tag', or returns deploy.ErrNotExist. b.mu must be held.
+func (b *Backend) findRelease(ctx context.Context, tag string) (*release, error) {
+	if r, ok := b.releases[tag]; ok {
+		return r, nil
+	}
+	rs, e := b.listReleases(ctx)
+	if e != nil {
+		return nil, e
+	}
+	for _, r := range rs {
+		if r.TagName == tag {
+			b.releases[tag] = r
+			return r, nil
+		}
+	}
+	return nil, deploy.ErrNotExist
+}
+
+// ensureRelease finds the release of 'tag', else creates it, as a pre-release if
+// 'tag' is a semver pre-release, eg. v3.6.0-beta.1, and a draft if b.draft.
+// The draft and pre-release flags of a found release are updated to match.
+// b.mu must be held.
+func (b *Backend) ensureRelease(ctx context.Context, tag string) (*release, error) {
+	prerelease := false
+	if v, e := gitvv.ParseSemver(tag); e == nil {
+		prerelease = v.IsPreRelease()
+	}
+	r, e := b.findRelease(ctx, tag)
+	if e == nil && (r.Draft != b.draft || r.Prerelease != prerelease) {
+		edit := map[string]bool{"draft": b.draft, "prerelease": prerelease}
+		edited := &release{}
+		if e := b.doJSON(ctx, http.MethodPatch, b.repoURL("/releases/"+strconv.FormatInt(r.ID, 10)), edit, edited); e != nil {
+			return nil, e
+		}
+		fmt.Printf("Updated release %s (draft: %v, prerelease: %v)\n", tag, edited.Draft, edited.Prerelease)
+		r.Draft, r.Prerelease = edited.Draft, edited.Prerelease
+	}
+	if e != deploy.ErrNotExist {
+		return r, e
+	}
+	create := struct {
+		TagName         string `json:"tag_name"`
+		TargetCommitish string `json:"target_commitish,omitempty"`
+		Name            string `json:"name"`
+		Draft           bool   `json:"draft"`
+		Prerelease      bool   `json:"prerelease"`
+	}{TagName: tag, TargetCommitish: b.commit, Name: tag, Draft: b.draft, Prerelease: prerelease}
+	r = &release{}
+	if e := b.doJSON(ctx, http.MethodPost, b.repoURL("/releases"), create, r); e != nil {
+		return nil, e
+	}
+	fmt.Printf("Created release %s (draft: %v, prerelease: %v)\n", tag, r.Draft, r.Prerelease)
+	b.releases[tag] = r
+	return r, nil
+}
+
+func findAsset(r *release, name string) (int, *asset) {
+	for i, a := range r.Assets {
+		if a.Name == name {
+			return i, a
+		}
+	}
+	return -1, nil
+}
+
+// assetURL gets the API URL of an asset of a release.
+func (b *Backend) assetURL(r *release, a *asset) string {
+	if b.gitea {
+		return b.repoURL("/releases/" + strconv.FormatInt(r.ID, 10) + "/assets/" + strconv.FormatInt(a.ID, 10))
+	}
+	return b.repoURL("/releases/assets/" + strconv.FormatInt(a.ID, 10))
+}
+
+// deleteAsset deletes an asset of a release. b.mu must be held.
+func (b *Backend) deleteAsset(ctx context.Context, r *release, a *asset) error {
+	if e := b.do(ctx, http.MethodDelete, b.assetURL(r, a), nil, nil, 0, nil); e != nil {
+		return e
+	}
+	if i, _ := findAsset(r, a.Name); i >= 0 {
+		r.Assets = append(r.Assets[:i], r.Assets[i+1:]...)
+	}
+	return nil
+}
+
+// renameAsset renames an asset of a release. b.mu must be held.
+func (b *Backend) renameAsset(ctx context.Context, r *release, a *asset, name string) error {
+	renamed := &asset{}
+	if e := b.doJSON(ctx, http.MethodPatch, b.assetURL(r, a), map[string]string{"name": name}, renamed); e != nil {
+		return e
+	}
+	*a = *renamed
+	return nil
+}
+
+// uploadURL gets the URL assets of a release are uploaded to, eg.
+// https://uploads.github.com/repos/ETCDEVTeam/janus/releases/1/assets?name=janus.zip
+func (b *Backend) uploadURL(r *release, name string) string {
+	u := r.UploadURL
+	// GitHub's is a URI template, eg. .../assets{?name,label}
+	if i := strings.Index(u, "{"); i >= 0 {
+		u = u[:i]
+	}
+	if u == "" {
+		u = b.repoURL("/releases/" + strconv.FormatInt(r.ID, 10) + "/assets")
+	}
+	return u + "?" + url.Values{"name": {name}}.Encode()
+}
+
+// Put uploads the content of 'r' as an asset of the release of the tag of 'name', replacing an asset
+// with the same name. A replaced asset is deleted only once its replacement is uploaded, under a temporary
+// name it is then renamed from, so a failed upload keeps it.
+func (b *Backend) Put(ctx context.Context, name string, r io.ReadSeeker, opts deploy.PutOptions) (*deploy.Object, error) {
+	if opts.IfGenerationMatch != 0 {
+		return nil, errors.New("release assets have no generations to match")
+	}
+	tag, assetName, e := b.split(name)
+	if e != nil {
+		return nil, e
+	}
+	o, e := b.put(ctx, tag, assetName, r, opts)
+	if alreadyExists(e) {
+		// The asset was uploaded since the release was listed, eg. by a concurrent deploy.
+		b.mu.Lock()
+		delete(b.releases, tag)
+		b.mu.Unlock()
+		o, e = b.put(ctx, tag, assetName, r, opts)
+	}
+	return o, e
+}
+
+// put uploads an asset of the release of 'tag', as per Put.
+func (b *Backend) put(ctx context.Context, tag, assetName string, r io.ReadSeeker, opts deploy.PutOptions) (*deploy.Object, error) {
+	b.mu.Lock()
+	rel, e := b.ensureRelease(ctx, tag)
+	var old *asset
+	if e == nil {
+		if _, old = findAsset(rel, assetName); old != nil && opts.NoClobber {
+			e = deploy.ErrExist
+		}
+	}
+	b.mu.Unlock()
+	if e != nil {
+		return nil, e
+	}
+
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(assetName))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	uploadName := assetName
+	if old != nil {
+		uploadName = assetName + ".janus-upload-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	a, e := b.upload(ctx, rel, uploadName, r, contentType)
+	if e != nil {
+		return nil, e
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if old == nil {
+		rel.Assets = append(rel.Assets, a)
+		return b.object(tag, a), nil
+	}
+	// Refresh the release, since the asset may have been replaced since it was listed.
+	delete(b.releases, tag)
+	if rel, e = b.findRelease(ctx, tag); e != nil {
+		return nil, e
+	}
+	if _, cur := findAsset(rel, assetName); cur != nil {
+		if e := b.deleteAsset(ctx, rel, cur); e != nil {
+			return nil, fmt.Errorf("failed to replace asset %s, uploaded as %s: %v", assetName, uploadName, e)
+		}
+	}
+	if _, uploaded := findAsset(rel, uploadName); uploaded != nil {
+		a = uploaded
+	} else {
+		rel.Assets = append(rel.Assets, a)
+	}
+	if e := b.renameAsset(ctx, rel, a, assetName); e != nil {
+		return nil, fmt.Errorf("failed to rename asset %s to %s: %v", uploadName, assetName, e)
+	}
+	return b.object(tag, a), nil
+}
+
+// upload uploads the content of 'r' as asset 'assetName' of a release.
+func (b *Backend) upload(ctx context.Context, rel *release, assetName string, r io.ReadSeeker, contentType string) (*asset, error) {
+	size, e := r.Seek(0, io.SeekEnd)
+	if e != nil {
+		return nil, e
+	}
+	if _, e := r.Seek(0, io.SeekStart); e != nil {
+		return nil, e
+	}
+
+	a := &asset{}
+	if b.gitea {
+		// Gitea takes a multipart form with the file as 'attachment'.
+		pr, pw := io.Pipe()
+		mw := multipart.NewWriter(pw)
+		go func() {
+			fw, e := mw.CreateFormFile("attachment", assetName)
+			if e == nil {
+				_, e = io.Copy(fw, r)
+			}
+			if e == nil {
+				e = mw.Close()
+			}
+			pw.CloseWithError(e)
+		}()
+		header := http.Header{"Content-Type": {mw.FormDataContentType()}}
+		e = b.do(ctx, http.MethodPost, b.uploadURL(rel, assetName), header, pr, -1, a)
+		pr.Close()
+	} else {
+		header := http.Header{"Content-Type": {contentType}}
+		e = b.do(ctx, http.MethodPost, b.uploadURL(rel, assetName), header, r, size, a)
+	}
+	if e != nil {
+		return nil, e
+	}
+	return a, nil
+}
+
+// Stat gets the metadata of the asset of 'name'.
+func (b *Backend) Stat(ctx context.Context, name string) (*deploy.Object, error) {
+	tag, assetName, e := b.split(name)
+	if e != nil {
+		return nil, e
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, e := b.findRelease(ctx, tag)
+	if e != nil {
+		return nil, e
+	}
+	_, a := findAsset(r, assetName)
+	if a == nil {
+		return nil, deploy.ErrNotExist
+	}
+	return b.object(tag, a), nil
+}
+
+// List lists the assets of all releases with object names starting with 'prefix'.
+func (b *Backend) List(ctx context.Context, prefix string) ([]*deploy.Object, error) {
+	rs, e := b.listReleases(ctx)
+	if e != nil {
+		return nil, e
+	}
+	var objs []*deploy.Object
+	for _, r := range rs {
+		for _, a := range r.Assets {
+			if o := b.object(r.TagName, a); strings.HasPrefix(o.Name, prefix) {
+				objs = append(objs, o)
+			}
+		}
+	}
+	return objs, nil
+}
+
+// download gets the content of an asset.
+func (b *Backend) download(ctx context.Context, a *asset) (io.ReadCloser, error) {
+	u := b.repoURL("/releases/assets/" + strconv.FormatInt(a.ID, 10))
+	if b.gitea {
+		u = a.BrowserDownloadURL
+	}
+	req, e := http.NewRequest(http.MethodGet, u, nil)
+	if e != nil {
+		return nil, e
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "token "+b.token)
+	req.Header.Set("Accept", "application/octet-stream")
+	res, e := b.client.Do(req)
+	if e != nil {
+		return nil, e
+	}
+	if res.StatusCode >= 300 {
+		res.Body.Close()
+		return nil, &Error{StatusCode: res.StatusCode}
+	}
+	return res.Body, nil
+}
+
+// asset gets the asset of 'name', or deploy.ErrNotExist.
+func (b *Backend) asset(ctx context.Context, name string) (*asset, error) {
+	tag, assetName, e := b.split(name)
+	if e != nil {
+		return nil, e
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, e := b.findRelease(ctx, tag)
+	if e != nil {
+		return nil, e
+	}
+	_, a := findAsset(r, assetName)
+	if a == nil {
+		return nil, deploy.ErrNotExist
+	}
+	return a, nil
+}
+
+// Get downloads the asset of 'name'.
+func (b *Backend) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	a, e := b.asset(ctx, name)
+	if e != nil {
+		return nil, e
+	}
+	return b.download(ctx, a)
+}
+
+// Copy downloads the asset of 'src' and uploads it as that of 'dst', since assets can't be copied server-side.
+func (b *Backend) Copy(ctx context.Context, src, dst string) (*deploy.Object, error) {
+	a, e := b.asset(ctx, src)
+	if e != nil {
+		return nil, e
+	}
+	rc, e := b.download(ctx, a)
+	if e != nil {
+		return nil, e
+	}
+	defer rc.Close()
+
+	f, e := ioutil.TempFile("", "janus-asset-")
+	if e != nil {
+		return nil, e
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, e := io.Copy(f, rc); e != nil {
+		return nil, e
+	}
+	return b.Put(ctx, dst, f, deploy.PutOptions{ContentType: a.ContentType})
+}
+
+// Delete deletes the asset of 'name'.
+func (b *Backend) Delete(ctx context.Context, name string) error {
+	tag, assetName, e := b.split(name)
+	if e != nil {
+		return e
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, e := b.findRelease(ctx, tag)
+	if e != nil {
+		return e
+	}
+	_, a := findAsset(r, assetName)
+	if a == nil {
+		return deploy.ErrNotExist
+	}
+	return b.deleteAsset(ctx, r, a)
+}
+
+// Close is a no-op.
+func (b *Backend) Close() error {
+	return nil
+}
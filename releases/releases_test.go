@@ -0,0 +1,398 @@
+package releases
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ETCDEVTeam/janus/deploy"
+)
+
+// stubAPI is an in-process GitHub or Gitea releases API of a single repository, ETCDEVTeam/janus.
+type stubAPI struct {
+	t        *testing.T
+	gitea    bool
+	srv      *httptest.Server
+	mu       sync.Mutex
+	releases []*release
+	content  map[int64][]byte
+	nextID   int64
+	created  []map[string]interface{}
+	edited   []map[string]interface{}
+	// maxPage caps the releases listed per page, eg. 50 like Gitea, unless 0.
+	maxPage int
+}
+
+func newStubAPI(t *testing.T, gitea bool) *stubAPI {
+	s := &stubAPI{t: t, gitea: gitea, content: make(map[int64][]byte)}
+	s.srv = httptest.NewServer(s)
+	return s
+}
+
+func (s *stubAPI) id() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+func (s *stubAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "token secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"message": "Bad credentials"}`)
+		return
+	}
+	base := "/repos/ETCDEVTeam/janus/releases"
+	if s.gitea {
+		base = "/api/v1" + base
+	}
+	if !strings.HasPrefix(r.URL.Path, base) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message": "Not Found"}`)
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, base), "/"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && parts[0] == "":
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		n, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		if s.gitea {
+			n, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+		}
+		if s.maxPage > 0 && n > s.maxPage {
+			n = s.maxPage
+		}
+		rs := []*release{}
+		if page >= 1 && n > 0 && (page-1)*n < len(s.releases) {
+			rs = s.releases[(page-1)*n:]
+			if len(rs) > n {
+				rs = rs[:n]
+			}
+		}
+		json.NewEncoder(w).Encode(rs)
+	case r.Method == http.MethodPost && parts[0] == "":
+		var create map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&create)
+		s.created = append(s.created, create)
+		id := s.id()
+		rel := &release{
+			ID:         id,
+			TagName:    create["tag_name"].(string),
+			Draft:      create["draft"].(bool),
+			Prerelease: create["prerelease"].(bool),
+			Assets:     []*asset{},
+		}
+		if !s.gitea {
+			rel.UploadURL = s.srv.URL + base + "/" + strconv.FormatInt(id, 10) + "/assets{?name,label}"
+		}
+		s.releases = append(s.releases, rel)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(rel)
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[1] == "assets":
+		id, _ := strconv.ParseInt(parts[0], 10, 64)
+		var rel *release
+		for _, x := range s.releases {
+			if x.ID == id {
+				rel = x
+			}
+		}
+		name := r.URL.Query().Get("name")
+		var data []byte
+		contentType := r.Header.Get("Content-Type")
+		if s.gitea {
+			f, _, e := r.FormFile("attachment")
+			if e != nil {
+				s.t.Error(e)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			data, _ = ioutil.ReadAll(f)
+			contentType = ""
+		} else {
+			data, _ = ioutil.ReadAll(r.Body)
+		}
+		if _, a := findAsset(rel, name); rel == nil || a != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"message": "Validation Failed", "errors": [{"resource": "ReleaseAsset", "code": "already_exists", "field": "name"}]}`)
+			return
+		}
+		a := &asset{ID: s.id(), Name: name, Size: int64(len(data)), ContentType: contentType}
+		a.BrowserDownloadURL = s.srv.URL + "/download/" + strconv.FormatInt(a.ID, 10)
+		s.content[a.ID] = data
+		rel.Assets = append(rel.Assets, a)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(a)
+	case r.Method == http.MethodPatch && len(parts) == 1:
+		id, _ := strconv.ParseInt(parts[0], 10, 64)
+		var edit map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&edit)
+		s.edited = append(s.edited, edit)
+		for _, rel := range s.releases {
+			if rel.ID == id {
+				rel.Draft = edit["draft"].(bool)
+				rel.Prerelease = edit["prerelease"].(bool)
+				json.NewEncoder(w).Encode(rel)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodPatch:
+		// GitHub: assets/<id>, Gitea: <release id>/assets/<id>
+		id, _ := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+		var edit struct {
+			Name string `json:"name"`
+		}
+		json.NewDecoder(r.Body).Decode(&edit)
+		for _, rel := range s.releases {
+			for _, a := range rel.Assets {
+				if a.ID == id {
+					if _, x := findAsset(rel, edit.Name); x != nil {
+						w.WriteHeader(http.StatusUnprocessableEntity)
+						return
+					}
+					a.Name = edit.Name
+					json.NewEncoder(w).Encode(a)
+					return
+				}
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodDelete:
+		// GitHub: assets/<id>, Gitea: <release id>/assets/<id>
+		id, _ := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+		for _, rel := range s.releases {
+			for i, a := range rel.Assets {
+				if a.ID == id {
+					rel.Assets = append(rel.Assets[:i], rel.Assets[i+1:]...)
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "assets":
+		id, _ := strconv.ParseInt(parts[1], 10, 64)
+		w.Write(s.content[id])
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (s *stubAPI) open(t *testing.T, tag string, opts deploy.Options) deploy.Backend {
+	scheme := SchemeGitHub
+	if s.gitea {
+		scheme = SchemeGitea
+	}
+	opts.Endpoint = s.srv.URL
+	os.Setenv("GITHUB_TOKEN", "secret")
+	os.Setenv("GITEA_TOKEN", "secret")
+	b, e := Open(context.Background(), deploy.Location{Scheme: scheme, Bucket: "ETCDEVTeam", Prefix: "janus/" + tag}, opts)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return b
+}
+
+func TestBackend(t *testing.T) {
+	for _, gitea := range []bool{false, true} {
+		s := newStubAPI(t, gitea)
+		b := s.open(t, "v0.3.0", deploy.Options{Commit: "abc123"})
+		ctx := context.Background()
+
+		o, e := b.Put(ctx, "janus/v0.3.0/janus_linux.zip", strings.NewReader("linux"), deploy.PutOptions{})
+		if e != nil {
+			t.Fatal(e)
+		}
+		if o.Name != "janus/v0.3.0/janus_linux.zip" || o.Size != 5 {
+			t.Errorf("gitea: %v: got: %+v", gitea, o)
+		}
+		if _, e := b.Put(ctx, "janus/v0.3.0/janus_linux.zip", strings.NewReader("linux2"), deploy.PutOptions{NoClobber: true}); e != deploy.ErrExist {
+			t.Errorf("gitea: %v: got: %v, want: %v", gitea, e, deploy.ErrExist)
+		}
+		// Replace it, and add another to the same release.
+		if _, e := b.Put(ctx, "janus/v0.3.0/janus_linux.zip", strings.NewReader("linux2"), deploy.PutOptions{}); e != nil {
+			t.Fatal(e)
+		}
+		if _, e := b.Put(ctx, "janus/v0.3.0/janus_osx.zip", strings.NewReader("osx"), deploy.PutOptions{}); e != nil {
+			t.Fatal(e)
+		}
+		if len(s.created) != 1 {
+			t.Fatalf("gitea: %v: got %d releases created, want 1", gitea, len(s.created))
+		}
+		if s.created[0]["target_commitish"] != "abc123" || s.created[0]["prerelease"] != false || s.created[0]["draft"] != false {
+			t.Errorf("gitea: %v: got: %v", gitea, s.created[0])
+		}
+
+		// A new backend finds the existing release.
+		if _, e := b.Put(ctx, "janus/v0.3.1-beta.1/janus_linux.zip", strings.NewReader("beta"), deploy.PutOptions{}); e != nil {
+			t.Fatal(e)
+		}
+		if len(s.created) != 2 || s.created[1]["prerelease"] != true {
+			t.Errorf("gitea: %v: want pre-release: %v", gitea, s.created)
+		}
+		b = s.open(t, "v0.3.1-beta.1", deploy.Options{})
+		o, e = b.Stat(ctx, "janus/v0.3.1-beta.1/janus_linux.zip")
+		if e != nil {
+			t.Fatal(e)
+		}
+		if o.Size != 4 || len(s.created) != 2 {
+			t.Errorf("gitea: %v: got: %+v, %d releases created", gitea, o, len(s.created))
+		}
+		if _, e := b.Stat(ctx, "janus/v9.9.9/janus_linux.zip"); e != deploy.ErrNotExist {
+			t.Errorf("gitea: %v: got: %v, want: %v", gitea, e, deploy.ErrNotExist)
+		}
+		if _, e := b.Put(ctx, "janus/janus_linux.zip", strings.NewReader("x"), deploy.PutOptions{}); e == nil {
+			t.Errorf("gitea: %v: want error for name without tag", gitea)
+		}
+
+		objs, e := b.List(ctx, "janus/v0.3.0/")
+		if e != nil {
+			t.Fatal(e)
+		}
+		var names []string
+		for _, o := range objs {
+			names = append(names, fmt.Sprintf("%s:%d", o.Name, o.Size))
+		}
+		if got, want := strings.Join(names, " "), "janus/v0.3.0/janus_linux.zip:6 janus/v0.3.0/janus_osx.zip:3"; got != want {
+			t.Errorf("gitea: %v: got: %s, want: %s", gitea, got, want)
+		}
+
+		if !gitea {
+			o, e := b.Copy(ctx, "janus/v0.3.0/janus_osx.zip", "janus/v0.3.1-beta.1/janus_osx.zip")
+			if e != nil {
+				t.Fatal(e)
+			}
+			if o.Size != 3 {
+				t.Errorf("got: %+v", o)
+			}
+		}
+
+		if e := b.Delete(ctx, "janus/v0.3.0/janus_osx.zip"); e != nil {
+			t.Fatal(e)
+		}
+		if e := b.Delete(ctx, "janus/v0.3.0/janus_osx.zip"); e != deploy.ErrNotExist {
+			t.Errorf("gitea: %v: got: %v, want: %v", gitea, e, deploy.ErrNotExist)
+		}
+		s.srv.Close()
+	}
+}
+
+func TestBackend_Put_replace(t *testing.T) {
+	for _, gitea := range []bool{false, true} {
+		s := newStubAPI(t, gitea)
+		b := s.open(t, "v0.3.0", deploy.Options{})
+		ctx := context.Background()
+
+		if _, e := b.Put(ctx, "janus/v0.3.0/janus_linux.zip", strings.NewReader("linux"), deploy.PutOptions{}); e != nil {
+			t.Fatal(e)
+		}
+		// Another deploy uploads an asset the backend doesn't know of.
+		other := s.open(t, "v0.3.0", deploy.Options{})
+		if _, e := other.Put(ctx, "janus/v0.3.0/janus_osx.zip", strings.NewReader("osx"), deploy.PutOptions{}); e != nil {
+			t.Fatal(e)
+		}
+		if _, e := b.Put(ctx, "janus/v0.3.0/janus_osx.zip", strings.NewReader("osx2"), deploy.PutOptions{NoClobber: true}); e != deploy.ErrExist {
+			t.Errorf("gitea: %v: got: %v, want: %v", gitea, e, deploy.ErrExist)
+		}
+		b = s.open(t, "v0.3.0", deploy.Options{})
+		if _, e := b.Stat(ctx, "janus/v0.3.0/janus_osx.zip"); e != nil {
+			t.Fatal(e)
+		}
+		if _, e := other.Put(ctx, "janus/v0.3.0/janus_osx.zip", strings.NewReader("osx2"), deploy.PutOptions{}); e != nil {
+			t.Fatal(e)
+		}
+		if _, e := b.Put(ctx, "janus/v0.3.0/janus_osx.zip", strings.NewReader("osx3"), deploy.PutOptions{}); e != nil {
+			t.Fatal(e)
+		}
+		if _, e := b.Put(ctx, "janus/v0.3.0/janus_linux.zip", strings.NewReader("linux2"), deploy.PutOptions{}); e != nil {
+			t.Fatal(e)
+		}
+
+		// Replaced assets keep their names, without temporary ones left.
+		var got []string
+		for _, a := range s.releases[0].Assets {
+			got = append(got, a.Name+":"+string(s.content[a.ID]))
+		}
+		if want := "janus_osx.zip:osx3 janus_linux.zip:linux2"; strings.Join(got, " ") != want {
+			t.Errorf("gitea: %v: got: %v, want: %s", gitea, got, want)
+		}
+		s.srv.Close()
+	}
+}
+
+func TestOpen_errors(t *testing.T) {
+	for _, env := range []string{"GITHUB_TOKEN", "GITEA_TOKEN"} {
+		defer os.Setenv(env, os.Getenv(env))
+	}
+	os.Unsetenv("GITHUB_TOKEN")
+	os.Setenv("GITEA_TOKEN", "secret")
+	table := []deploy.Location{
+		{Scheme: SchemeGitHub, Bucket: "ETCDEVTeam", Prefix: "janus"}, // no token
+		{Scheme: SchemeGitea, Bucket: "ETCDEVTeam", Prefix: "janus"},  // no endpoint
+		{Scheme: SchemeGitea, Bucket: "ETCDEVTeam"},                   // no repo
+	}
+	for _, l := range table {
+		if _, e := Open(context.Background(), l, deploy.Options{}); e == nil {
+			t.Errorf("%v: want error", l)
+		}
+	}
+}
+
+func TestBackend_findRelease_pages(t *testing.T) {
+	for _, gitea := range []bool{false, true} {
+		s := newStubAPI(t, gitea)
+		s.maxPage = 50
+		for i := 0; i < 120; i++ {
+			s.releases = append(s.releases, &release{ID: s.id(), TagName: fmt.Sprintf("v0.%d.0", i), Assets: []*asset{}})
+		}
+		b := s.open(t, "v0.1.0", deploy.Options{})
+		// The release on the last page is found, not created again.
+		if _, e := b.Put(context.Background(), "janus/v0.119.0/janus_linux.zip", strings.NewReader("linux"), deploy.PutOptions{}); e != nil {
+			t.Fatal(e)
+		}
+		if len(s.created) != 0 || len(s.releases[119].Assets) != 1 {
+			t.Errorf("gitea: %v: got %d releases created, %d assets", gitea, len(s.created), len(s.releases[119].Assets))
+		}
+		s.srv.Close()
+	}
+}
+
+func TestBackend_ensureRelease_flags(t *testing.T) {
+	s := newStubAPI(t, false)
+	defer s.srv.Close()
+	s.releases = []*release{
+		{ID: s.id(), TagName: "v0.3.0", Draft: true, Assets: []*asset{}},
+		{ID: s.id(), TagName: "v0.4.0-rc.1", Assets: []*asset{}},
+		{ID: s.id(), TagName: "v0.5.0", Assets: []*asset{}},
+	}
+	ctx := context.Background()
+
+	// A draft is published by a deploy which isn't of a draft, and a pre-release is marked as such.
+	b := s.open(t, "v0.3.0", deploy.Options{})
+	for _, name := range []string{"janus/v0.3.0/janus_linux.zip", "janus/v0.3.0/janus_osx.zip", "janus/v0.4.0-rc.1/janus_linux.zip"} {
+		if _, e := b.Put(ctx, name, strings.NewReader("linux"), deploy.PutOptions{}); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if s.releases[0].Draft || !s.releases[1].Prerelease || len(s.edited) != 2 {
+		t.Errorf("got: %+v %+v, %d edits", s.releases[0], s.releases[1], len(s.edited))
+	}
+	// A draft deploy makes a release a draft.
+	b = s.open(t, "v0.5.0", deploy.Options{Draft: true})
+	if _, e := b.Put(ctx, "janus/v0.5.0/janus_linux.zip", strings.NewReader("linux"), deploy.PutOptions{}); e != nil {
+		t.Fatal(e)
+	}
+	if !s.releases[2].Draft || s.releases[2].Prerelease {
+		t.Errorf("got: %+v", s.releases[2])
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ETCDEVTeam/janus/gitvv"
+)
+
+// repoVersion is the version of one repository in a batch.
+type repoVersion struct {
+	Dir string `json:"dir"`
+	// Submodule is true for submodules found by -recursive.
+	Submodule bool `json:"submodule"`
+	// Formatted is the version as per -format.
+	Formatted string `json:"formatted,omitempty"`
+	*gitvv.Version
+	Error string `json:"error,omitempty"`
+}
+
+// untagged reports whether the repository's HEAD is not on a version tag.
+func (r *repoVersion) untagged() bool {
+	return r.Version == nil || r.Tag == "" || r.CommitCount != 0
+}
+
+// getRepoVersions gets the versions of 'dirs', and with 'recursive' of their submodules, recursively.
+// Failures are reported per repository.
+func getRepoVersions(dirs []string, recursive bool, format string, f *versionOptions) []*repoVersion {
+	var rs []*repoVersion
+	for _, dir := range dirs {
+		rs = append(rs, getRepoVersion(dir, false, format, f))
+		if !recursive {
+			continue
+		}
+		subs, e := listSubmodules(dir)
+		if e != nil {
+			rs[len(rs)-1].Error = e.Error()
+			continue
+		}
+		for _, s := range subs {
+			if !s.initialized {
+				rs = append(rs, &repoVersion{Dir: s.path, Submodule: true, Error: "submodule not initialized"})
+				continue
+			}
+			rs = append(rs, getRepoVersion(s.path, true, format, f))
+		}
+	}
+	return rs
+}
+
+func getRepoVersion(dir string, submodule bool, format string, f *versionOptions) *repoVersion {
+	r := &repoVersion{Dir: dir, Submodule: submodule}
+	vc, e := f.versionConfigFor(dir)
+	if e != nil {
+		r.Error = e.Error()
+		return r
+	}
+	if r.Version, e = gitvv.GetVersionInfo(dir, vc); e != nil {
+		r.Error = e.Error()
+		return r
+	}
+	r.Formatted = gitvv.GetVersionWithConfig(format, dir, vc)
+	return r
+}
+
+type submodule struct {
+	path        string
+	initialized bool
+}
+
+// listSubmodules lists the submodules of the repository in 'dir', recursively, with paths joined to 'dir'.
+func listSubmodules(dir string) ([]submodule, error) {
+	if dir == "" {
+		dir = "."
+	}
+	c, e := exec.Command("git", "-C", dir, "submodule", "status", "--recursive").Output()
+	if e != nil {
+		return nil, fmt.Errorf("git submodule status: %v", e)
+	}
+	// eg. -bbb06b1... sputnikvm
+	//     +e35b683... emerald-cli (v0.2.0-4-ge35b683)
+	var subs []submodule
+	for _, l := range strings.Split(strings.TrimRight(string(c), "\n"), "\n") {
+		if len(l) < 2 {
+			continue
+		}
+		fields := strings.SplitN(l[1:], " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("unexpected git submodule status output: %q", l)
+		}
+		path := fields[1]
+		if i := strings.LastIndex(path, " ("); i > 0 && strings.HasSuffix(path, ")") {
+			path = path[:i]
+		}
+		subs = append(subs, submodule{path: filepath.Join(dir, path), initialized: l[0] != '-'})
+	}
+	return subs, nil
+}
+
+// writeRepoVersions writes the versions as a table or JSON.
+func writeRepoVersions(w io.Writer, rs []*repoVersion, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rs)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DIR\tVERSION\tTAG\tCOMMITS\tCOMMIT\tDIRTY\tERROR")
+	for _, r := range rs {
+		dir := r.Dir
+		if r.Submodule {
+			dir += " (submodule)"
+		}
+		if r.Version == nil {
+			fmt.Fprintf(tw, "%s\t\t\t\t\t\t%s\n", dir, r.Error)
+			continue
+		}
+		commit := r.Commit
+		if len(commit) > 7 {
+			commit = commit[:7]
+		}
+		dirty := "no"
+		if r.Dirty {
+			dirty = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n", dir, r.Formatted, r.Tag, r.CommitCount, commit, dirty, r.Error)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,513 @@
+// Package s3 is a deploy backend for Amazon S3 and S3-compatible storage, eg. MinIO or Ceph,
+// for destinations like s3://builds/go-ethereum/v3.5.x
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ETCDEVTeam/janus/deploy"
+)
+
+// Scheme is the URL scheme of S3 destinations.
+const Scheme = "s3"
+
+// DefaultPartSize is the size of the parts of multipart uploads; smaller files are uploaded in one request.
+const DefaultPartSize = 16 << 20
+
+// maxParts is the maximum number of parts of a multipart upload.
+const maxParts = 10000
+
+// DefaultRegion is the region requests are signed for if neither -region nor $AWS_REGION are set.
+const DefaultRegion = "us-east-1"
+
+func init() {
+	deploy.Register(Scheme, Open)
+}
+
+// Backend is a S3 bucket.
+type Backend struct {
+	client    *http.Client
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	pathStyle bool
+	partSize  int64
+	creds     Credentials
+	// retry retries the parts of multipart uploads, so a failed part doesn't restart the upload.
+	retry deploy.Retry
+	// now is the time requests are signed at.
+	now func() time.Time
+}
+
+// Error is an error response of S3.
+type Error struct {
+	StatusCode int    `xml:"-"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("s3: %s: %s (%d)", e.Code, e.Message, e.StatusCode)
+}
+
+// Retryable is whether the request may succeed if retried, see deploy.IsRetryable.
+func (e *Error) Retryable() bool {
+	switch e.Code {
+	case "SlowDown", "RequestTimeout", "InternalError", "ServiceUnavailable":
+		return true
+	}
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// isNotFound is whether an object doesn't exist, and not eg. the bucket (NoSuchBucket).
+// Responses to HEAD requests have no body, so no code.
+func isNotFound(e error) bool {
+	se, ok := e.(*Error)
+	return ok && se.StatusCode == http.StatusNotFound && (se.Code == "" || se.Code == "NoSuchKey")
+}
+
+// isPreconditionFailed is whether a conditional write failed, as the object exists.
+func isPreconditionFailed(e error) bool {
+	se, ok := e.(*Error)
+	return ok && (se.StatusCode == http.StatusPreconditionFailed || se.Code == "PreconditionFailed")
+}
+
+// Open opens the S3 bucket of 'l'. Credentials are read from the JSON file 'opts.Key', which may be
+// encrypted, see deploy.DecryptKey, else from $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY and $AWS_SESSION_TOKEN.
+// The endpoint is 'opts.Endpoint', else $AWS_ENDPOINT_URL, else AWS S3 of the region.
+func Open(ctx context.Context, l deploy.Location, opts deploy.Options) (deploy.Backend, error) {
+	creds, e := ReadCredentials(opts.Key, opts.GPG)
+	if e != nil {
+		return nil, e
+	}
+	region := opts.Region
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region == "" {
+			region = os.Getenv(env)
+		}
+	}
+	if region == "" {
+		region = DefaultRegion
+	}
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, e := url.Parse(endpoint)
+	if e != nil {
+		return nil, e
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint: %s", endpoint)
+	}
+	partSize := opts.PartSize
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+	retry := deploy.DefaultRetry
+	if opts.Retry != nil {
+		retry = *opts.Retry
+	}
+	return &Backend{
+		client:    http.DefaultClient,
+		endpoint:  u,
+		bucket:    l.Bucket,
+		region:    region,
+		pathStyle: opts.PathStyle,
+		partSize:  partSize,
+		creds:     creds,
+		retry:     retry,
+		now:       time.Now,
+	}, nil
+}
+
+// ReadCredentials reads credentials from a JSON file, which may be encrypted, see deploy.DecryptKey,
+// eg. {"access_key_id": "...", "secret_access_key": "..."}, or the {"accessKey": "...", "secretKey": "..."}
+// of MinIO. If 'key' is empty they are read from $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY and $AWS_SESSION_TOKEN.
+func ReadCredentials(key string, gpg bool) (Credentials, error) {
+	if key == "" {
+		c := Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+			return Credentials{}, errors.New("s3:// requires -key or env AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		return c, nil
+	}
+
+	p, cleanup, e := deploy.DecryptKey(key, gpg)
+	if e != nil {
+		return Credentials{}, e
+	}
+	// The credentials are kept in memory, so a decrypted file isn't needed anymore.
+	defer cleanup()
+	b, e := ioutil.ReadFile(p)
+	if e != nil {
+		return Credentials{}, e
+	}
+	var c struct {
+		Credentials
+		AccessKey string `json:"accessKey"`
+		SecretKey string `json:"secretKey"`
+	}
+	if e := json.Unmarshal(b, &c); e != nil {
+		return Credentials{}, e
+	}
+	if c.AccessKeyID == "" {
+		c.AccessKeyID = c.AccessKey
+	}
+	if c.SecretAccessKey == "" {
+		c.SecretAccessKey = c.SecretKey
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("%s: no access_key_id or secret_access_key", key)
+	}
+	return c.Credentials, nil
+}
+
+// url gets the URL of an object, or of the bucket if 'name' is empty, eg.
+// https://builds.s3.us-east-1.amazonaws.com/go-ethereum/geth.zip, or
+// https://minio.example.com:9000/builds/go-ethereum/geth.zip with path-style addressing.
+func (b *Backend) url(name string, query url.Values) *url.URL {
+	u := *b.endpoint
+	p := "/" + name
+	if b.pathStyle {
+		p = "/" + b.bucket + p
+	} else {
+		u.Host = b.bucket + "." + u.Host
+	}
+	base := strings.TrimSuffix(u.Path, "/")
+	u.Path = base + p
+	u.RawPath = uriEncode(base+p, true)
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+// do sends a signed request, returning an *Error for error responses.
+// The caller must close the body of the response.
+func (b *Backend) do(ctx context.Context, method, name string, query url.Values, header http.Header, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	req, e := http.NewRequest(method, b.url(name, query).String(), body)
+	if e != nil {
+		return nil, e
+	}
+	req = req.WithContext(ctx)
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	b.creds.sign(req, b.region, payloadHash, b.now())
+
+	res, e := b.client.Do(req)
+	if e != nil {
+		return nil, e
+	}
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		se := &Error{StatusCode: res.StatusCode}
+		if data, _ := ioutil.ReadAll(res.Body); len(data) > 0 {
+			xml.Unmarshal(data, se)
+		}
+		return nil, se
+	}
+	return res, nil
+}
+
+// readResult reads an XML result of a successful response into 'v', which may still be an error,
+// as CompleteMultipartUpload and CopyObject fail after responding 200.
+func readResult(res *http.Response, v interface{}) error {
+	defer res.Body.Close()
+	data, e := ioutil.ReadAll(res.Body)
+	if e != nil {
+		return e
+	}
+	var root struct {
+		XMLName xml.Name
+	}
+	if e := xml.Unmarshal(data, &root); e != nil {
+		return e
+	}
+	if root.XMLName.Local == "Error" {
+		se := &Error{StatusCode: res.StatusCode}
+		xml.Unmarshal(data, se)
+		return se
+	}
+	if v == nil {
+		return nil
+	}
+	return xml.Unmarshal(data, v)
+}
+
+func contentType(name string, opts deploy.PutOptions) string {
+	if opts.ContentType != "" {
+		return opts.ContentType
+	}
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// Put uploads the content of 'r' to 'name', with a multipart upload if it is larger than the part size.
+func (b *Backend) Put(ctx context.Context, name string, r io.ReadSeeker, opts deploy.PutOptions) (*deploy.Object, error) {
+	size, e := r.Seek(0, io.SeekEnd)
+	if e != nil {
+		return nil, e
+	}
+	if _, e := r.Seek(0, io.SeekStart); e != nil {
+		return nil, e
+	}
+	if opts.IfGenerationMatch != 0 {
+		return nil, errors.New("s3:// has no generations of objects to match")
+	}
+	// Conditional writes, of the object or else of the completion of a multipart upload.
+	var cond http.Header
+	if opts.NoClobber {
+		cond = http.Header{"If-None-Match": {"*"}}
+	}
+	header := http.Header{"Content-Type": {contentType(name, opts)}}
+	if size > b.partSize {
+		e := b.putMultipart(ctx, name, r, size, header, cond)
+		if isPreconditionFailed(e) {
+			return nil, deploy.ErrExist
+		}
+		if e != nil {
+			return nil, e
+		}
+		return b.Stat(ctx, name)
+	}
+	for k, vs := range cond {
+		header[k] = vs
+	}
+
+	h := sha256.New()
+	if _, e := io.Copy(h, r); e != nil {
+		return nil, e
+	}
+	if _, e := r.Seek(0, io.SeekStart); e != nil {
+		return nil, e
+	}
+	res, e := b.do(ctx, http.MethodPut, name, nil, header, r, size, hex.EncodeToString(h.Sum(nil)))
+	if isPreconditionFailed(e) {
+		return nil, deploy.ErrExist
+	}
+	if e != nil {
+		return nil, e
+	}
+	res.Body.Close()
+	return b.Stat(ctx, name)
+}
+
+type completedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// putMultipart uploads 'r' in parts of the part size, aborting the upload if a part fails.
+// The headers 'cond' are the preconditions of completing the upload.
+func (b *Backend) putMultipart(ctx context.Context, name string, r io.Reader, size int64, header, cond http.Header) error {
+	partSize := b.partSize
+	if size > partSize*maxParts {
+		partSize = (size + maxParts - 1) / maxParts
+	}
+
+	res, e := b.do(ctx, http.MethodPost, name, url.Values{"uploads": {""}}, header, nil, 0, emptyHash)
+	if e != nil {
+		return e
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if e := readResult(res, &initiated); e != nil {
+		return e
+	}
+
+	var parts []completedPart
+	buf := make([]byte, partSize)
+	for n := 1; ; n++ {
+		m, e := io.ReadFull(r, buf)
+		if e == io.EOF {
+			break
+		}
+		if e != nil && e != io.ErrUnexpectedEOF {
+			b.abort(name, initiated.UploadID)
+			return e
+		}
+		q := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {initiated.UploadID}}
+		hash := sha256Hex(buf[:m])
+		var etag string
+		e = b.retry.Do(ctx, fmt.Sprintf("put %s part %d", name, n), func() error {
+			res, e := b.do(ctx, http.MethodPut, name, q, nil, bytes.NewReader(buf[:m]), int64(m), hash)
+			if e != nil {
+				return e
+			}
+			res.Body.Close()
+			etag = res.Header.Get("ETag")
+			return nil
+		})
+		if e != nil {
+			b.abort(name, initiated.UploadID)
+			return fmt.Errorf("part %d: %v", n, e)
+		}
+		parts = append(parts, completedPart{PartNumber: n, ETag: etag})
+		if m < len(buf) {
+			break
+		}
+	}
+
+	data, e := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if e != nil {
+		return e
+	}
+	q := url.Values{"uploadId": {initiated.UploadID}}
+	res, e = b.do(ctx, http.MethodPost, name, q, cond, bytes.NewReader(data), int64(len(data)), sha256Hex(data))
+	if e != nil {
+		b.abort(name, initiated.UploadID)
+		return e
+	}
+	return readResult(res, nil)
+}
+
+// abort aborts a multipart upload, so its parts aren't stored (and billed).
+// It doesn't use the context of the upload, which may be what failed it.
+func (b *Backend) abort(name, uploadID string) {
+	res, e := b.do(context.Background(), http.MethodDelete, name, url.Values{"uploadId": {uploadID}}, nil, nil, 0, emptyHash)
+	if e != nil {
+		fmt.Println("failed to abort multipart upload:", e)
+		return
+	}
+	res.Body.Close()
+}
+
+// Get gets the content of 'name'.
+func (b *Backend) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	res, e := b.do(ctx, http.MethodGet, name, nil, nil, nil, 0, emptyHash)
+	if isNotFound(e) {
+		return nil, deploy.ErrNotExist
+	}
+	if e != nil {
+		return nil, e
+	}
+	return res.Body, nil
+}
+
+// Stat gets the metadata of 'name'.
+func (b *Backend) Stat(ctx context.Context, name string) (*deploy.Object, error) {
+	res, e := b.do(ctx, http.MethodHead, name, nil, nil, nil, 0, emptyHash)
+	if isNotFound(e) {
+		return nil, deploy.ErrNotExist
+	}
+	if e != nil {
+		return nil, e
+	}
+	res.Body.Close()
+	updated, _ := http.ParseTime(res.Header.Get("Last-Modified"))
+	return &deploy.Object{
+		Name:        name,
+		Size:        res.ContentLength,
+		ContentType: res.Header.Get("Content-Type"),
+		Updated:     updated,
+	}, nil
+}
+
+// List lists the objects with names starting with 'prefix'.
+func (b *Backend) List(ctx context.Context, prefix string) ([]*deploy.Object, error) {
+	var objs []*deploy.Object
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		res, e := b.do(ctx, http.MethodGet, "", q, nil, nil, 0, emptyHash)
+		if e != nil {
+			return nil, e
+		}
+		var list struct {
+			Contents []struct {
+				Key          string
+				Size         int64
+				LastModified time.Time
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		if e := readResult(res, &list); e != nil {
+			return nil, e
+		}
+		for _, c := range list.Contents {
+			objs = append(objs, &deploy.Object{Name: c.Key, Size: c.Size, Updated: c.LastModified})
+		}
+		if !list.IsTruncated || list.NextContinuationToken == "" {
+			return objs, nil
+		}
+		token = list.NextContinuationToken
+	}
+}
+
+// Copy copies 'src' to 'dst' server-side.
+func (b *Backend) Copy(ctx context.Context, src, dst string) (*deploy.Object, error) {
+	header := http.Header{"X-Amz-Copy-Source": {uriEncode("/"+b.bucket+"/"+src, true)}}
+	res, e := b.do(ctx, http.MethodPut, dst, nil, header, nil, 0, emptyHash)
+	if e == nil {
+		e = readResult(res, nil)
+	}
+	if isNotFound(e) {
+		return nil, deploy.ErrNotExist
+	}
+	if e != nil {
+		return nil, e
+	}
+	return b.Stat(ctx, dst)
+}
+
+// Delete deletes 'name'. S3 doesn't tell whether an object existed, so that is checked first.
+func (b *Backend) Delete(ctx context.Context, name string) error {
+	if _, e := b.Stat(ctx, name); e != nil {
+		return e
+	}
+	res, e := b.do(ctx, http.MethodDelete, name, nil, nil, nil, 0, emptyHash)
+	if e != nil {
+		return e
+	}
+	res.Body.Close()
+	return nil
+}
+
+// Close is a no-op; credentials are only held in memory.
+func (b *Backend) Close() error {
+	return nil
+}
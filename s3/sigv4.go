@@ -0,0 +1,137 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptyHash is the SHA256 of an empty payload.
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+const (
+	amzDateFormat = "20060102T150405Z"
+	scopeFormat   = "20060102"
+	algorithm     = "AWS4-HMAC-SHA256"
+)
+
+// Credentials are the access keys requests are signed with.
+type Credentials struct {
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	SessionToken    string `json:"session_token,omitempty"`
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode encodes 's' as per AWS Signature Version 4: all but the unreserved characters
+// A-Z a-z 0-9 - _ . ~ are percent-encoded, and '/' too unless 'path' is set.
+func uriEncode(s string, path bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && path:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery gets the query of a request sorted by key and value, eg. partNumber=1&uploadId=abc
+func canonicalQuery(q url.Values) string {
+	var pairs []string
+	for k, vs := range q {
+		for _, v := range vs {
+			pairs = append(pairs, uriEncode(k, false)+"="+uriEncode(v, false))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// canonicalHeaders gets the lowercased, sorted headers to sign, which are 'host' and all headers
+// set on the request, and the list of their names, eg. host;x-amz-content-sha256;x-amz-date
+func canonicalHeaders(req *http.Request) (string, string) {
+	headers := map[string]string{"host": req.URL.Host}
+	if req.Host != "" {
+		headers["host"] = req.Host
+	}
+	for k, vs := range req.Header {
+		k = strings.ToLower(k)
+		if k == "authorization" {
+			continue
+		}
+		var values []string
+		for _, v := range vs {
+			values = append(values, strings.Join(strings.Fields(v), " "))
+		}
+		headers[k] = strings.Join(values, ",")
+	}
+	var names []string
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, k := range names {
+		b.WriteString(k + ":" + headers[k] + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+// sign signs a request with AWS Signature Version 4, setting its X-Amz-Date, X-Amz-Content-Sha256
+// and Authorization headers. The path of the request URL must already be URI encoded in RawPath.
+func (c Credentials) sign(req *http.Request, region, payloadHash string, t time.Time) {
+	t = t.UTC()
+	req.Header.Set("X-Amz-Date", t.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	headers, signed := canonicalHeaders(req)
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		headers,
+		signed,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{t.Format(scopeFormat), region, "s3", "aws4_request"}, "/")
+	toSign := strings.Join([]string{algorithm, t.Format(amzDateFormat), scope, sha256Hex([]byte(canonical))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), t.Format(scopeFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, c.AccessKeyID, scope, signed, signature))
+}
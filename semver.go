@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ETCDEVTeam/janus/gitvv"
+)
+
+// Exit codes for semver subcommands, meant for use in shell conditionals.
+const (
+	semverExitOK      = 0 // success, or version satisfies range
+	semverExitFalse   = 1 // version does not satisfy range
+	semverExitInvalid = 2 // invalid arguments or versions
+	semverExitLower   = 3 // compare: A has lower precedence than B
+	semverExitHigher  = 4 // compare: A has higher precedence than B
+)
+
+// runSemver runs 'semver compare|satisfies|sort', returning the exit code.
+//
+// compare A B - prints -1, 0 or 1 if A has lower, equal or higher precedence than B, exiting 3, 0 or 4
+// satisfies VERSION RANGE - exits 0 if VERSION is in RANGE, else 1
+// sort [-r] [VERSION...] - prints versions by ascending precedence, reading lines from stdin if none given
+func runSemver(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "'compare', 'satisfies' or 'sort' is required")
+		return semverExitInvalid
+	}
+
+	switch args[0] {
+	case "compare":
+		if len(args) != 3 {
+			fmt.Fprintln(stderr, "usage: janus semver compare A B")
+			return semverExitInvalid
+		}
+		a, e := gitvv.ParseSemver(args[1])
+		if e != nil {
+			fmt.Fprintln(stderr, e)
+			return semverExitInvalid
+		}
+		b, e := gitvv.ParseSemver(args[2])
+		if e != nil {
+			fmt.Fprintln(stderr, e)
+			return semverExitInvalid
+		}
+		c := a.Compare(b)
+		fmt.Fprintln(stdout, c)
+		switch c {
+		case -1:
+			return semverExitLower
+		case 1:
+			return semverExitHigher
+		}
+		return semverExitOK
+
+	case "satisfies":
+		if len(args) != 3 {
+			fmt.Fprintln(stderr, "usage: janus semver satisfies VERSION RANGE")
+			return semverExitInvalid
+		}
+		v, e := gitvv.ParseSemver(args[1])
+		if e != nil {
+			fmt.Fprintln(stderr, e)
+			return semverExitInvalid
+		}
+		r, e := gitvv.ParseRange(args[2])
+		if e != nil {
+			fmt.Fprintln(stderr, e)
+			return semverExitInvalid
+		}
+		if !r.Satisfies(v) {
+			return semverExitFalse
+		}
+		return semverExitOK
+
+	case "sort":
+		sortCommand := flag.NewFlagSet("sort", flag.ContinueOnError)
+		sortCommand.SetOutput(stderr)
+		reverse := sortCommand.Bool("r", false, "sort by descending precedence")
+		if e := sortCommand.Parse(args[1:]); e != nil {
+			return semverExitInvalid
+		}
+		lines := sortCommand.Args()
+		if len(lines) == 0 {
+			s := bufio.NewScanner(stdin)
+			for s.Scan() {
+				if l := strings.TrimSpace(s.Text()); l != "" {
+					lines = append(lines, l)
+				}
+			}
+			if e := s.Err(); e != nil {
+				fmt.Fprintln(stderr, e)
+				return semverExitInvalid
+			}
+		}
+		// Keep input strings, eg. v-prefixed tags, for output.
+		var vs []*gitvv.Semver
+		original := make(map[*gitvv.Semver]string)
+		for _, l := range lines {
+			v, e := gitvv.ParseSemver(l)
+			if e != nil {
+				// Skip non-version input, eg. from 'git tag | janus semver sort'
+				fmt.Fprintf(stderr, "skipping %s: %v\n", l, e)
+				continue
+			}
+			vs = append(vs, v)
+			original[v] = l
+		}
+		gitvv.SortSemvers(vs)
+		for i := range vs {
+			v := vs[i]
+			if *reverse {
+				v = vs[len(vs)-1-i]
+			}
+			fmt.Fprintln(stdout, original[v])
+		}
+		return semverExitOK
+	}
+
+	fmt.Fprintf(stderr, "unknown semver subcommand '%s', want 'compare', 'satisfies' or 'sort'\n", args[0])
+	return semverExitInvalid
+}
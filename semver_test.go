@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunSemver(t *testing.T) {
+	table := []struct {
+		args  []string
+		stdin string
+		code  int
+		out   string
+	}{
+		{[]string{"compare", "v3.5.0", "3.6.0"}, "", semverExitLower, "-1\n"},
+		{[]string{"compare", "3.5.0", "v3.5.0+build.1"}, "", semverExitOK, "0\n"},
+		{[]string{"compare", "3.6.0", "3.6.0-rc.1"}, "", semverExitHigher, "1\n"},
+		{[]string{"compare", "3.6.0", "nope"}, "", semverExitInvalid, ""},
+		{[]string{"compare", "3.6.0"}, "", semverExitInvalid, ""},
+		{[]string{"satisfies", "3.5.1", "~3.5.0"}, "", semverExitOK, ""},
+		{[]string{"satisfies", "3.6.0", "~3.5.0"}, "", semverExitFalse, ""},
+		{[]string{"satisfies", "3.6.0", ">>3"}, "", semverExitInvalid, ""},
+		{[]string{"sort", "v3.6.0", "3.5.0", "3.6.0-rc.1"}, "", semverExitOK, "3.5.0\n3.6.0-rc.1\nv3.6.0\n"},
+		{[]string{"sort", "-r"}, "3.5.0\nnightly\n\nv3.6.0\n", semverExitOK, "v3.6.0\n3.5.0\n"},
+		{[]string{"sort", "-x"}, "", semverExitInvalid, ""},
+		{[]string{"bump"}, "", semverExitInvalid, ""},
+		{nil, "", semverExitInvalid, ""},
+	}
+
+	for _, tt := range table {
+		var stdout, stderr bytes.Buffer
+		code := runSemver(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+		if code != tt.code {
+			t.Errorf("%v: got exit code: %d, want: %d, stderr: %s", tt.args, code, tt.code, stderr.String())
+		}
+		if got := stdout.String(); got != tt.out {
+			t.Errorf("%v: got: %q, want: %q", tt.args, got, tt.out)
+		}
+	}
+}
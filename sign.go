@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ETCDEVTeam/janus/sign"
+)
+
+// signOptions are the flags shared by commands signing files.
+type signOptions struct {
+	signer        string
+	key           string
+	passphraseEnv string
+	ext           string
+	namespace     string
+}
+
+func (f *signOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.signer, "signer", "pgp", `type of -sign-key: `+strings.Join(sign.Types(), ", ")+`
+pgp - OpenPGP secret key (armored or binary), signatures are <file>.asc
+minisign - minisign secret key, signatures are <file>.minisig
+ssh - SSH private key, signatures are <file>.sig as of ssh-keygen -Y sign`)
+	fs.StringVar(&f.key, "sign-key", "", "secret key file to sign files with")
+	fs.StringVar(&f.passphraseEnv, "passphrase-env", sign.DefaultPassphraseEnv, "environment variable of the passphrase of an encrypted -sign-key, eg. GCP_PASSWD")
+	fs.StringVar(&f.ext, "sig-ext", "", "extension of signature files, eg. .sig for OpenPGP signatures verified by get.sh (default by -signer)")
+	fs.StringVar(&f.namespace, "ssh-namespace", sign.DefaultSSHNamespace, "namespace of ssh signatures, as of ssh-keygen -Y sign -n")
+}
+
+// newSigner creates the signer of the flags, or nil without -sign-key.
+func (f *signOptions) newSigner() (sign.Signer, error) {
+	if f.key == "" {
+		return nil, nil
+	}
+	return sign.NewSigner(f.signer, sign.Options{
+		Key:        f.key,
+		Passphrase: os.Getenv(f.passphraseEnv),
+		Ext:        f.ext,
+		Namespace:  f.namespace,
+	})
+}
+
+// runSign writes a detached signature next to each file matching the glob 'files', eg. dist/geth.zip.asc
+func runSign(w io.Writer, s sign.Signer, files string) error {
+	matches, e := filepath.Glob(files)
+	if e != nil {
+		return e
+	}
+	signed := 0
+	for _, f := range matches {
+		fi, e := os.Stat(f)
+		if e != nil {
+			return e
+		}
+		// Don't sign directories, or signatures of an earlier run.
+		if fi.IsDir() || strings.HasSuffix(f, s.Ext()) {
+			continue
+		}
+		out, e := sign.SignFile(s, f)
+		if e != nil {
+			return fmt.Errorf("%s: %v", f, e)
+		}
+		fmt.Fprintf(w, "%s: signed %s\n", f, out)
+		signed++
+	}
+	if signed == 0 {
+		return errors.New("no files matching '-files' pattern were found")
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package sign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/scrypt"
+)
+
+func init() {
+	Register("minisign", newMinisignSigner, newMinisignVerifier)
+}
+
+// Algorithms of minisign keys and signatures.
+const (
+	minisignEd       = "Ed" // Ed25519, also signatures of the content, as by minisign < 0.8
+	minisignPrehash  = "ED" // signatures of the BLAKE2b-512 of the content
+	minisignScrypt   = "Sc"
+	minisignBlake2b  = "B2"
+	minisignKeyIDLen = 8
+)
+
+// minisignSigner creates minisign signatures of the BLAKE2b-512 hash of the content, as minisign -S does.
+type minisignSigner struct {
+	keyID [minisignKeyIDLen]byte
+	key   ed25519.PrivateKey
+	now   func() time.Time
+}
+
+// readMinisignFile reads the base64 line following the untrusted comment of a minisign key file.
+func readMinisignFile(file string) ([]byte, error) {
+	b, e := ioutil.ReadFile(file)
+	if e != nil {
+		return nil, e
+	}
+	lines := strings.Split(strings.Replace(string(b), "\r\n", "\n", -1), "\n")
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "untrusted comment: ") {
+		return nil, errors.New("not a minisign key")
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+}
+
+// scryptParams gets the scrypt parameters of libsodium's crypto_pwhash_scryptsalsa208sha256 limits.
+func scryptParams(opslimit, memlimit uint64) (n, r, p int) {
+	if opslimit < 32768 {
+		opslimit = 32768
+	}
+	r = 8
+	var maxN uint64
+	if opslimit < memlimit/32 {
+		p = 1
+		maxN = opslimit / uint64(r*4)
+	} else {
+		maxN = memlimit / uint64(r*128)
+	}
+	log2 := uint(1)
+	for ; log2 < 63; log2++ {
+		if uint64(1)<<log2 > maxN/2 {
+			break
+		}
+	}
+	if opslimit >= memlimit/32 {
+		maxrp := (opslimit / 4) / (uint64(1) << log2)
+		if maxrp > 0x3fffffff {
+			maxrp = 0x3fffffff
+		}
+		p = int(maxrp) / r
+	}
+	return 1 << log2, r, p
+}
+
+func newMinisignSigner(opts Options) (Signer, error) {
+	b, e := readMinisignFile(opts.Key)
+	if e != nil {
+		return nil, e
+	}
+	// sig alg, kdf alg, checksum alg, kdf salt, opslimit, memlimit, key id, secret key, checksum
+	if len(b) != 2+2+2+32+8+8+minisignKeyIDLen+ed25519.PrivateKeySize+32 {
+		return nil, errors.New("not a minisign secret key")
+	}
+	sigAlg, kdfAlg, cksumAlg := string(b[:2]), string(b[2:4]), string(b[4:6])
+	if sigAlg != minisignEd || cksumAlg != minisignBlake2b {
+		return nil, fmt.Errorf("unsupported minisign key algorithms: %q, %q", sigAlg, cksumAlg)
+	}
+	salt := b[6:38]
+	opslimit, memlimit := binary.LittleEndian.Uint64(b[38:46]), binary.LittleEndian.Uint64(b[46:54])
+	keynum := append([]byte(nil), b[54:]...)
+
+	switch kdfAlg {
+	case minisignScrypt:
+		if opts.Passphrase == "" {
+			return nil, errPassphrase()
+		}
+		n, r, p := scryptParams(opslimit, memlimit)
+		stream, e := scrypt.Key([]byte(opts.Passphrase), salt, n, r, p, len(keynum))
+		if e != nil {
+			return nil, e
+		}
+		for i := range keynum {
+			keynum[i] ^= stream[i]
+		}
+	case "\x00\x00":
+	default:
+		return nil, fmt.Errorf("unsupported minisign key derivation: %q", kdfAlg)
+	}
+
+	s := &minisignSigner{now: time.Now}
+	copy(s.keyID[:], keynum[:minisignKeyIDLen])
+	s.key = ed25519.PrivateKey(keynum[minisignKeyIDLen : minisignKeyIDLen+ed25519.PrivateKeySize])
+	h, _ := blake2b.New256(nil)
+	h.Write([]byte(sigAlg))
+	h.Write(keynum[:minisignKeyIDLen+ed25519.PrivateKeySize])
+	if !bytes.Equal(h.Sum(nil), keynum[minisignKeyIDLen+ed25519.PrivateKeySize:]) {
+		return nil, errors.New("wrong passphrase or corrupted minisign key")
+	}
+	return s, nil
+}
+
+func (s *minisignSigner) Sign(name string, r io.Reader) ([]byte, error) {
+	h, _ := blake2b.New512(nil)
+	if _, e := io.Copy(h, r); e != nil {
+		return nil, e
+	}
+	sig := ed25519.Sign(s.key, h.Sum(nil))
+	trusted := fmt.Sprintf("timestamp:%d\tfile:%s\thashed", s.now().Unix(), filepath.Base(name))
+	global := ed25519.Sign(s.key, append(append([]byte(nil), sig...), trusted...))
+
+	var b bytes.Buffer
+	fmt.Fprintln(&b, "untrusted comment: signature from minisign secret key")
+	fmt.Fprintln(&b, base64.StdEncoding.EncodeToString(append(append([]byte(minisignPrehash), s.keyID[:]...), sig...)))
+	fmt.Fprintln(&b, "trusted comment: "+trusted)
+	fmt.Fprintln(&b, base64.StdEncoding.EncodeToString(global))
+	return b.Bytes(), nil
+}
+
+func (s *minisignSigner) Ext() string {
+	return ".minisig"
+}
+
+// minisignVerifier verifies minisign signatures against public keys.
+type minisignVerifier struct {
+	keys map[[minisignKeyIDLen]byte]ed25519.PublicKey
+}
+
+// minisignKeyID formats a key ID as minisign does.
+func minisignKeyID(id [minisignKeyIDLen]byte) string {
+	return fmt.Sprintf("%X", binary.LittleEndian.Uint64(id[:]))
+}
+
+// newMinisignVerifier reads the public keys of a file, as written by minisign -G or of minisign -P,
+// one per line and optionally following untrusted comments.
+func newMinisignVerifier(opts Options) (Verifier, error) {
+	b, e := ioutil.ReadFile(opts.Key)
+	if e != nil {
+		return nil, e
+	}
+	v := &minisignVerifier{keys: make(map[[minisignKeyIDLen]byte]ed25519.PublicKey)}
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "untrusted comment:") {
+			continue
+		}
+		key, e := base64.StdEncoding.DecodeString(line)
+		if e != nil || len(key) != 2+minisignKeyIDLen+ed25519.PublicKeySize || string(key[:2]) != minisignEd {
+			return nil, fmt.Errorf("not a minisign public key: %s", line)
+		}
+		var id [minisignKeyIDLen]byte
+		copy(id[:], key[2:])
+		v.keys[id] = ed25519.PublicKey(key[2+minisignKeyIDLen:])
+	}
+	if len(v.keys) == 0 {
+		return nil, errors.New("no keys")
+	}
+	return v, nil
+}
+
+func (v *minisignVerifier) Verify(r io.Reader, sig []byte) (string, error) {
+	lines := strings.Split(strings.Replace(string(sig), "\r\n", "\n", -1), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return "", errors.New("not a minisign signature")
+	}
+	b, e := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if e != nil || len(b) != 2+minisignKeyIDLen+ed25519.SignatureSize {
+		return "", errors.New("not a minisign signature")
+	}
+	global, e := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if e != nil || len(global) != ed25519.SignatureSize {
+		return "", errors.New("not a minisign signature")
+	}
+	var id [minisignKeyIDLen]byte
+	copy(id[:], b[2:])
+	key, ok := v.keys[id]
+	if !ok {
+		return "", fmt.Errorf("signed by untrusted key %s", minisignKeyID(id))
+	}
+
+	var msg []byte
+	switch string(b[:2]) {
+	case minisignPrehash:
+		h, _ := blake2b.New512(nil)
+		if _, e := io.Copy(h, r); e != nil {
+			return "", e
+		}
+		msg = h.Sum(nil)
+	case minisignEd:
+		if msg, e = ioutil.ReadAll(r); e != nil {
+			return "", e
+		}
+	default:
+		return "", fmt.Errorf("unsupported minisign signature algorithm: %q", b[:2])
+	}
+	sigBytes := b[2+minisignKeyIDLen:]
+	if !ed25519.Verify(key, msg, sigBytes) {
+		return "", errors.New("invalid signature")
+	}
+	trusted := strings.TrimPrefix(lines[2], "trusted comment: ")
+	if !ed25519.Verify(key, append(append([]byte(nil), sigBytes...), trusted...), global) {
+		return "", errors.New("invalid signature of the trusted comment")
+	}
+	return "key " + minisignKeyID(id) + " (" + trusted + ")", nil
+}
+
+func (v *minisignVerifier) Ext() string {
+	return ".minisig"
+}
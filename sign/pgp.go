@@ -0,0 +1,122 @@
+package sign
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+func init() {
+	Register("pgp", newPGPSigner, newPGPVerifier)
+}
+
+// pgpSigner creates ASCII armored OpenPGP signatures, as gpg --armor --detach-sign does.
+type pgpSigner struct {
+	entity *openpgp.Entity
+}
+
+// readEntity reads the first key with a private key of an armored or binary keyring,
+// decrypting it with 'passphrase' if it is encrypted.
+func readEntity(key, passphrase string) (*openpgp.Entity, error) {
+	b, e := ioutil.ReadFile(key)
+	if e != nil {
+		return nil, e
+	}
+	keyring, e := openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+	if e != nil {
+		keyring, e = openpgp.ReadKeyRing(bytes.NewReader(b))
+		if e != nil {
+			return nil, e
+		}
+	}
+	for _, entity := range keyring {
+		if entity.PrivateKey == nil {
+			continue
+		}
+		if entity.PrivateKey.Encrypted {
+			if passphrase == "" {
+				return nil, errPassphrase()
+			}
+			if e := entity.PrivateKey.Decrypt([]byte(passphrase)); e != nil {
+				return nil, e
+			}
+		}
+		for _, sub := range entity.Subkeys {
+			if sub.PrivateKey != nil && sub.PrivateKey.Encrypted {
+				if e := sub.PrivateKey.Decrypt([]byte(passphrase)); e != nil {
+					return nil, e
+				}
+			}
+		}
+		return entity, nil
+	}
+	return nil, errors.New("no private key")
+}
+
+func newPGPSigner(opts Options) (Signer, error) {
+	entity, e := readEntity(opts.Key, opts.Passphrase)
+	if e != nil {
+		return nil, e
+	}
+	return &pgpSigner{entity: entity}, nil
+}
+
+func (s *pgpSigner) Sign(name string, r io.Reader) ([]byte, error) {
+	var b bytes.Buffer
+	if e := openpgp.ArmoredDetachSign(&b, s.entity, r, nil); e != nil {
+		return nil, e
+	}
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
+
+func (s *pgpSigner) Ext() string {
+	return ".asc"
+}
+
+// pgpVerifier verifies armored or binary OpenPGP signatures against a keyring.
+type pgpVerifier struct {
+	keyring openpgp.EntityList
+}
+
+func newPGPVerifier(opts Options) (Verifier, error) {
+	b, e := ioutil.ReadFile(opts.Key)
+	if e != nil {
+		return nil, e
+	}
+	keyring, e := openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+	if e != nil {
+		keyring, e = openpgp.ReadKeyRing(bytes.NewReader(b))
+		if e != nil {
+			return nil, e
+		}
+	}
+	if len(keyring) == 0 {
+		return nil, errors.New("no keys")
+	}
+	return &pgpVerifier{keyring: keyring}, nil
+}
+
+func (v *pgpVerifier) Verify(r io.Reader, sig []byte) (string, error) {
+	var signer *openpgp.Entity
+	var e error
+	if bytes.HasPrefix(bytes.TrimSpace(sig), []byte("-----BEGIN PGP SIGNATURE-----")) {
+		signer, e = openpgp.CheckArmoredDetachedSignature(v.keyring, r, bytes.NewReader(sig))
+	} else {
+		signer, e = openpgp.CheckDetachedSignature(v.keyring, r, bytes.NewReader(sig))
+	}
+	if e != nil {
+		return "", e
+	}
+	for name := range signer.Identities {
+		return name + " " + signer.PrimaryKey.KeyIdString(), nil
+	}
+	return signer.PrimaryKey.KeyIdString(), nil
+}
+
+func (v *pgpVerifier) Ext() string {
+	return ".asc"
+}
@@ -0,0 +1,112 @@
+// Package sign creates and verifies detached signatures of build artifacts, eg. geth.zip.asc,
+// with OpenPGP, minisign or SSH keys.
+package sign
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DefaultPassphraseEnv is the environment variable of the passphrase of encrypted keys.
+const DefaultPassphraseEnv = "JANUS_SIGN_PASSWD"
+
+// Signer creates detached signatures.
+type Signer interface {
+	// Sign signs the content of 'r', of file 'name'.
+	Sign(name string, r io.Reader) ([]byte, error)
+	// Ext is the extension of signature files, eg. .asc
+	Ext() string
+}
+
+// Options are the key and settings of a signer or verifier.
+type Options struct {
+	// Key is the secret key file of a signer, or the file of trusted keys of a verifier.
+	Key string
+	// Passphrase decrypts an encrypted key.
+	Passphrase string
+	// Ext overrides the extension of signature files, eg. .sig for OpenPGP signatures.
+	Ext string
+	// Namespace is the namespace of SSH signatures, DefaultSSHNamespace if empty.
+	Namespace string
+}
+
+// NewSignerFunc creates a signer with a key.
+type NewSignerFunc func(opts Options) (Signer, error)
+
+// NewVerifierFunc creates a verifier with trusted keys.
+type NewVerifierFunc func(opts Options) (Verifier, error)
+
+var (
+	signers   = make(map[string]NewSignerFunc)
+	verifiers = make(map[string]NewVerifierFunc)
+)
+
+// Register makes a signer and its verifier available by type name.
+func Register(typ string, fn NewSignerFunc, verifier NewVerifierFunc) {
+	signers[typ] = fn
+	verifiers[typ] = verifier
+}
+
+// Types returns the registered signer type names.
+func Types() []string {
+	var ts []string
+	for t := range signers {
+		ts = append(ts, t)
+	}
+	sort.Strings(ts)
+	return ts
+}
+
+// NewSigner creates a signer of a registered type, eg. pgp
+func NewSigner(typ string, opts Options) (Signer, error) {
+	fn, ok := signers[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown signer '%s', want one of: %s", typ, strings.Join(Types(), ", "))
+	}
+	if opts.Key == "" {
+		return nil, fmt.Errorf("signer '%s' requires a key", typ)
+	}
+	s, e := fn(opts)
+	if e != nil {
+		return nil, fmt.Errorf("%s key %s: %v", typ, opts.Key, e)
+	}
+	if opts.Ext != "" {
+		s = &extSigner{Signer: s, ext: "." + strings.TrimPrefix(opts.Ext, ".")}
+	}
+	return s, nil
+}
+
+// extSigner overrides the extension of a signer.
+type extSigner struct {
+	Signer
+	ext string
+}
+
+func (s *extSigner) Ext() string {
+	return s.ext
+}
+
+// errPassphrase is the error of a key which is encrypted, without a passphrase.
+func errPassphrase() error {
+	return fmt.Errorf("key is encrypted, but no passphrase is set, eg. with $%s", DefaultPassphraseEnv)
+}
+
+// SignFile writes the detached signature of 'file' to the file with the signer's extension,
+// eg. geth.zip.asc, and returns its name.
+func SignFile(s Signer, file string) (string, error) {
+	f, e := os.Open(file)
+	if e != nil {
+		return "", e
+	}
+	defer f.Close()
+	sig, e := s.Sign(file, f)
+	if e != nil {
+		return "", e
+	}
+	out := file + s.Ext()
+	return out, ioutil.WriteFile(out, sig, 0644)
+}
@@ -0,0 +1,247 @@
+package sign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/ssh"
+)
+
+// The keys in testdata are encrypted with the passphrase 'secret', except ssh-rsa.
+const testPassphrase = "secret"
+
+func TestNewSigner(t *testing.T) {
+	table := []struct {
+		typ  string
+		opts Options
+		want string
+	}{
+		{"nope", Options{Key: "testdata/ssh-rsa"}, "unknown signer"},
+		{"ssh", Options{}, "requires a key"},
+		{"pgp", Options{Key: "testdata/pgp-encrypted.asc"}, "no passphrase"},
+		{"pgp", Options{Key: "testdata/pgp-encrypted.asc", Passphrase: "wrong"}, "pgp key testdata/pgp-encrypted.asc"},
+		{"pgp", Options{Key: "testdata/pgp-encrypted.pub.asc"}, "no private key"},
+		{"ssh", Options{Key: "testdata/ssh-encrypted"}, "no passphrase"},
+		{"ssh", Options{Key: "testdata/ssh-encrypted", Passphrase: "wrong"}, "ssh key"},
+		{"minisign", Options{Key: "testdata/ssh-rsa"}, "not a minisign key"},
+	}
+	for _, tt := range table {
+		if _, e := NewSigner(tt.typ, tt.opts); e == nil || !strings.Contains(e.Error(), tt.want) {
+			t.Errorf("%s %+v: got: %v, want: %s", tt.typ, tt.opts, e, tt.want)
+		}
+	}
+}
+
+func TestPGPSigner(t *testing.T) {
+	s, e := NewSigner("pgp", Options{Key: "testdata/pgp-encrypted.asc", Passphrase: testPassphrase})
+	if e != nil {
+		t.Fatal(e)
+	}
+	sig, e := s.Sign("geth.zip", strings.NewReader("geth"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if s.Ext() != ".asc" || !bytes.HasPrefix(sig, []byte("-----BEGIN PGP SIGNATURE-----")) {
+		t.Errorf("got: %s, %s", s.Ext(), sig)
+	}
+	pub, e := os.Open("testdata/pgp-encrypted.pub.asc")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer pub.Close()
+	keyring, e := openpgp.ReadArmoredKeyRing(pub)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e := openpgp.CheckArmoredDetachedSignature(keyring, strings.NewReader("geth"), bytes.NewReader(sig)); e != nil {
+		t.Error(e)
+	}
+	if _, e := openpgp.CheckArmoredDetachedSignature(keyring, strings.NewReader("geth!"), bytes.NewReader(sig)); e == nil {
+		t.Error("want error verifying other content")
+	}
+}
+
+// writeMinisignKey writes a minisign secret key, encrypted if 'passphrase' is set.
+func writeMinisignKey(t *testing.T, file, passphrase string) (ed25519.PublicKey, []byte) {
+	pub, priv, e := ed25519.GenerateKey(nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	keynum := append(append([]byte(nil), keyID...), priv...)
+	h, _ := blake2b.New256(nil)
+	h.Write([]byte("Ed"))
+	h.Write(keynum)
+	keynum = h.Sum(keynum)
+
+	kdf := "\x00\x00"
+	salt := bytes.Repeat([]byte{0x42}, 32)
+	// Small limits, for N=1024.
+	opslimit, memlimit := uint64(32768), uint64(16<<20)
+	if passphrase != "" {
+		kdf = "Sc"
+		stream, e := scrypt.Key([]byte(passphrase), salt, 1024, 8, 1, len(keynum))
+		if e != nil {
+			t.Fatal(e)
+		}
+		for i := range keynum {
+			keynum[i] ^= stream[i]
+		}
+	}
+	b := []byte("Ed" + kdf + "B2")
+	b = append(b, salt...)
+	b = append(b, make([]byte, 16)...)
+	binary.LittleEndian.PutUint64(b[38:], opslimit)
+	binary.LittleEndian.PutUint64(b[46:], memlimit)
+	b = append(b, keynum...)
+	content := "untrusted comment: minisign encrypted secret key\n" + base64.StdEncoding.EncodeToString(b) + "\n"
+	if e := ioutil.WriteFile(file, []byte(content), 0600); e != nil {
+		t.Fatal(e)
+	}
+	return pub, keyID
+}
+
+func TestScryptParams(t *testing.T) {
+	table := []struct {
+		opslimit, memlimit uint64
+		n, r, p            int
+	}{
+		// minisign's defaults
+		{33554432, 1073741824, 1 << 20, 8, 1},
+		{32768, 16 << 20, 1024, 8, 1},
+		{1 << 30, 16 << 20, 1 << 14, 8, 2048},
+	}
+	for _, tt := range table {
+		if n, r, p := scryptParams(tt.opslimit, tt.memlimit); n != tt.n || r != tt.r || p != tt.p {
+			t.Errorf("%d, %d: got: %d, %d, %d, want: %d, %d, %d", tt.opslimit, tt.memlimit, n, r, p, tt.n, tt.r, tt.p)
+		}
+	}
+}
+
+func TestMinisignSigner(t *testing.T) {
+	dir, e := ioutil.TempDir("", "janus-sign")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, passphrase := range []string{"", testPassphrase} {
+		key := filepath.Join(dir, "minisign.key")
+		pub, keyID := writeMinisignKey(t, key, passphrase)
+		if passphrase != "" {
+			if _, e := NewSigner("minisign", Options{Key: key, Passphrase: "wrong"}); e == nil {
+				t.Error("want error for wrong passphrase")
+			}
+		}
+		s, e := NewSigner("minisign", Options{Key: key, Passphrase: passphrase})
+		if e != nil {
+			t.Fatal(e)
+		}
+		s.(*minisignSigner).now = func() time.Time { return time.Unix(1500000000, 0) }
+		sig, e := s.Sign("dist/geth.zip", strings.NewReader("geth"))
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		lines := strings.Split(string(sig), "\n")
+		if len(lines) != 5 || lines[2] != "trusted comment: timestamp:1500000000\tfile:geth.zip\thashed" {
+			t.Fatalf("got: %q", sig)
+		}
+		b, _ := base64.StdEncoding.DecodeString(lines[1])
+		global, _ := base64.StdEncoding.DecodeString(lines[3])
+		hash := blake2b.Sum512([]byte("geth"))
+		if len(b) != 74 || string(b[:2]) != "ED" || !bytes.Equal(b[2:10], keyID) || !ed25519.Verify(pub, hash[:], b[10:]) {
+			t.Errorf("invalid signature: %q", lines[1])
+		}
+		if !ed25519.Verify(pub, append(b[10:], strings.TrimPrefix(lines[2], "trusted comment: ")...), global) {
+			t.Errorf("invalid global signature: %q", lines[3])
+		}
+	}
+}
+
+func TestSSHSigner(t *testing.T) {
+	for _, key := range []string{"testdata/ssh-encrypted", "testdata/ssh-rsa"} {
+		s, e := NewSigner("ssh", Options{Key: key, Passphrase: testPassphrase})
+		if e != nil {
+			t.Fatal(e)
+		}
+		sig, e := s.Sign("geth.zip", strings.NewReader("geth"))
+		if e != nil {
+			t.Fatal(e)
+		}
+		armored := strings.TrimSpace(string(sig))
+		if !strings.HasPrefix(armored, "-----BEGIN SSH SIGNATURE-----\n") || !strings.HasSuffix(armored, "\n-----END SSH SIGNATURE-----") {
+			t.Fatalf("%s: got: %s", key, sig)
+		}
+		lines := strings.Split(armored, "\n")
+		blob, e := base64.StdEncoding.DecodeString(strings.Join(lines[1:len(lines)-1], ""))
+		if e != nil || !bytes.HasPrefix(blob, []byte("SSHSIG")) {
+			t.Fatalf("%s: got: %v, %q", key, e, blob)
+		}
+		var parsed struct {
+			Version       uint32
+			PublicKey     []byte
+			Namespace     string
+			Reserved      string
+			HashAlgorithm string
+			Signature     []byte
+		}
+		if e := ssh.Unmarshal(blob[6:], &parsed); e != nil {
+			t.Fatal(e)
+		}
+		pub, e := ssh.ParsePublicKey(parsed.PublicKey)
+		if e != nil {
+			t.Fatal(e)
+		}
+		var signature ssh.Signature
+		if e := ssh.Unmarshal(parsed.Signature, &signature); e != nil {
+			t.Fatal(e)
+		}
+		hash := sha512.Sum512([]byte("geth"))
+		if e := pub.Verify(sshsigSignedData("file", "sha512", hash[:]), &signature); e != nil || parsed.Namespace != "file" {
+			t.Errorf("%s: got: %v, namespace: %s", key, e, parsed.Namespace)
+		}
+		if pub.Type() == ssh.KeyAlgoRSA && signature.Format != ssh.KeyAlgoRSASHA512 {
+			t.Errorf("%s: got: %s, want: %s", key, signature.Format, ssh.KeyAlgoRSASHA512)
+		}
+	}
+}
+
+func TestSignFile(t *testing.T) {
+	dir, e := ioutil.TempDir("", "janus-sign")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "geth.zip")
+	if e := ioutil.WriteFile(file, []byte("geth"), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	s, e := NewSigner("pgp", Options{Key: "testdata/pgp-encrypted.asc", Passphrase: testPassphrase, Ext: "sig"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	out, e := SignFile(s, file)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if out != file+".sig" {
+		t.Errorf("got: %s, want: %s.sig", out, file)
+	}
+	if b, e := ioutil.ReadFile(out); e != nil || !bytes.Contains(b, []byte("PGP SIGNATURE")) {
+		t.Errorf("got: %s, %v", b, e)
+	}
+}
@@ -0,0 +1,281 @@
+package sign
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func init() {
+	Register("ssh", newSSHSigner, newSSHVerifier)
+}
+
+// DefaultSSHNamespace is the namespace of SSH signatures of files, as of ssh-keygen -Y sign -n file.
+const DefaultSSHNamespace = "file"
+
+const (
+	sshsigMagic   = "SSHSIG"
+	sshsigVersion = 1
+	sshsigHash    = "sha512"
+)
+
+// sshSigner creates SSH signatures, as ssh-keygen -Y sign does, see PROTOCOL.sshsig of OpenSSH.
+type sshSigner struct {
+	signer    ssh.Signer
+	namespace string
+}
+
+func newSSHSigner(opts Options) (Signer, error) {
+	b, e := ioutil.ReadFile(opts.Key)
+	if e != nil {
+		return nil, e
+	}
+	signer, e := ssh.ParsePrivateKey(b)
+	if _, ok := e.(*ssh.PassphraseMissingError); ok {
+		if opts.Passphrase == "" {
+			return nil, errPassphrase()
+		}
+		signer, e = ssh.ParsePrivateKeyWithPassphrase(b, []byte(opts.Passphrase))
+	}
+	if e != nil {
+		return nil, e
+	}
+	ns := opts.Namespace
+	if ns == "" {
+		ns = DefaultSSHNamespace
+	}
+	return &sshSigner{signer: signer, namespace: ns}, nil
+}
+
+// sshsigSignedData is the data signed of a SSH signature.
+func sshsigSignedData(namespace, hashAlgorithm string, hash []byte) []byte {
+	return append([]byte(sshsigMagic), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{namespace, "", hashAlgorithm, hash})...)
+}
+
+func (s *sshSigner) Sign(name string, r io.Reader) ([]byte, error) {
+	h := sha512.New()
+	if _, e := io.Copy(h, r); e != nil {
+		return nil, e
+	}
+	data := sshsigSignedData(s.namespace, sshsigHash, h.Sum(nil))
+
+	var sig *ssh.Signature
+	var e error
+	if as, ok := s.signer.(ssh.AlgorithmSigner); ok && s.signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		// SHA-1 RSA signatures aren't accepted.
+		sig, e = as.SignWithAlgorithm(rand.Reader, data, ssh.KeyAlgoRSASHA512)
+	} else {
+		sig, e = s.signer.Sign(rand.Reader, data)
+	}
+	if e != nil {
+		return nil, e
+	}
+
+	blob := append([]byte(sshsigMagic), ssh.Marshal(struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}{sshsigVersion, s.signer.PublicKey().Marshal(), s.namespace, "", sshsigHash, ssh.Marshal(sig)})...)
+
+	var b bytes.Buffer
+	b.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	enc := base64.StdEncoding.EncodeToString(blob)
+	for len(enc) > 70 {
+		b.WriteString(enc[:70] + "\n")
+		enc = enc[70:]
+	}
+	b.WriteString(enc + "\n")
+	b.WriteString("-----END SSH SIGNATURE-----\n")
+	return b.Bytes(), nil
+}
+
+func (s *sshSigner) Ext() string {
+	return ".sig"
+}
+
+// sshAllowedSigner is a trusted key of an allowed signers file.
+type sshAllowedSigner struct {
+	principals string
+	key        ssh.PublicKey
+	// namespaces the key may sign, any if empty.
+	namespaces []string
+}
+
+// sshVerifier verifies SSH signatures, as ssh-keygen -Y verify does.
+type sshVerifier struct {
+	signers   []sshAllowedSigner
+	namespace string
+}
+
+// parseAllowedSigner parses a line of an allowed signers file, see ssh-keygen(1), eg.
+//
+//	janus@example.com namespaces="file" ssh-ed25519 AAAA...
+//
+// Lines of public keys, eg. of id_ed25519.pub, are accepted too.
+func parseAllowedSigner(line string) (sshAllowedSigner, error) {
+	fields := strings.Fields(line)
+	for i := 0; i+1 < len(fields); i++ {
+		b, e := base64.StdEncoding.DecodeString(fields[i+1])
+		if e != nil {
+			continue
+		}
+		key, e := ssh.ParsePublicKey(b)
+		if e != nil || key.Type() != fields[i] {
+			continue
+		}
+		s := sshAllowedSigner{key: key}
+		if i == 0 {
+			s.principals = strings.Join(fields[2:], " ")
+			return s, nil
+		}
+		s.principals = fields[0]
+		for _, opt := range splitOptions(strings.Join(fields[1:i], " ")) {
+			if strings.HasPrefix(strings.ToLower(opt), "namespaces=") {
+				ns := strings.Trim(opt[len("namespaces="):], `"`)
+				s.namespaces = append(s.namespaces, strings.Split(ns, ",")...)
+			}
+		}
+		return s, nil
+	}
+	return sshAllowedSigner{}, fmt.Errorf("no public key: %s", line)
+}
+
+// splitOptions splits comma separated options, except commas in quotes, eg. namespaces="file,git",cert-authority
+func splitOptions(s string) []string {
+	var opts []string
+	quoted, start := false, 0
+	for i, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			opts = append(opts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(opts, s[start:])
+}
+
+func newSSHVerifier(opts Options) (Verifier, error) {
+	b, e := ioutil.ReadFile(opts.Key)
+	if e != nil {
+		return nil, e
+	}
+	v := &sshVerifier{namespace: opts.Namespace}
+	if v.namespace == "" {
+		v.namespace = DefaultSSHNamespace
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		s, e := parseAllowedSigner(line)
+		if e != nil {
+			return nil, e
+		}
+		v.signers = append(v.signers, s)
+	}
+	if len(v.signers) == 0 {
+		return nil, errors.New("no keys")
+	}
+	return v, nil
+}
+
+func (v *sshVerifier) Verify(r io.Reader, sig []byte) (string, error) {
+	armored := strings.TrimSpace(string(sig))
+	if !strings.HasPrefix(armored, "-----BEGIN SSH SIGNATURE-----") || !strings.HasSuffix(armored, "-----END SSH SIGNATURE-----") {
+		return "", errors.New("not an SSH signature")
+	}
+	lines := strings.Split(armored, "\n")
+	blob, e := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(strings.Join(lines[1:len(lines)-1], "")), ""))
+	if e != nil || !bytes.HasPrefix(blob, []byte(sshsigMagic)) {
+		return "", errors.New("not an SSH signature")
+	}
+	var s struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}
+	if e := ssh.Unmarshal(blob[len(sshsigMagic):], &s); e != nil {
+		return "", e
+	}
+	if s.Version != sshsigVersion {
+		return "", fmt.Errorf("unsupported SSH signature version %d", s.Version)
+	}
+	if s.Namespace != v.namespace {
+		return "", fmt.Errorf("signature of namespace '%s', want '%s'", s.Namespace, v.namespace)
+	}
+	key, e := ssh.ParsePublicKey(s.PublicKey)
+	if e != nil {
+		return "", e
+	}
+	var signer *sshAllowedSigner
+	for i := range v.signers {
+		if bytes.Equal(v.signers[i].key.Marshal(), key.Marshal()) {
+			signer = &v.signers[i]
+			break
+		}
+	}
+	if signer == nil {
+		return "", fmt.Errorf("signed by untrusted key %s", ssh.FingerprintSHA256(key))
+	}
+	if len(signer.namespaces) > 0 && !contains(signer.namespaces, s.Namespace) {
+		return "", fmt.Errorf("key %s is not allowed to sign namespace '%s'", ssh.FingerprintSHA256(key), s.Namespace)
+	}
+
+	var h hash.Hash
+	switch s.HashAlgorithm {
+	case "sha512":
+		h = sha512.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return "", fmt.Errorf("unsupported SSH signature hash: %s", s.HashAlgorithm)
+	}
+	if _, e := io.Copy(h, r); e != nil {
+		return "", e
+	}
+	var signature ssh.Signature
+	if e := ssh.Unmarshal(s.Signature, &signature); e != nil {
+		return "", e
+	}
+	if e := key.Verify(sshsigSignedData(s.Namespace, s.HashAlgorithm, h.Sum(nil)), &signature); e != nil {
+		return "", e
+	}
+	return strings.TrimSpace(signer.principals + " " + ssh.FingerprintSHA256(key)), nil
+}
+
+func (v *sshVerifier) Ext() string {
+	return ".sig"
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
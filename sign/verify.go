@@ -0,0 +1,61 @@
+package sign
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Verifier verifies detached signatures against trusted keys.
+type Verifier interface {
+	// Verify verifies the signature 'sig' of the content of 'r', returning the signer, eg. a user ID.
+	Verify(r io.Reader, sig []byte) (string, error)
+	// Ext is the extension of signature files, eg. .asc
+	Ext() string
+}
+
+// NewVerifier creates a verifier of a registered type, eg. pgp, trusting the keys of the file 'opts.Key'.
+func NewVerifier(typ string, opts Options) (Verifier, error) {
+	fn, ok := verifiers[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown signer '%s', want one of: %s", typ, strings.Join(Types(), ", "))
+	}
+	if opts.Key == "" {
+		return nil, fmt.Errorf("signer '%s' requires trusted keys", typ)
+	}
+	v, e := fn(opts)
+	if e != nil {
+		return nil, fmt.Errorf("%s keys %s: %v", typ, opts.Key, e)
+	}
+	if opts.Ext != "" {
+		v = &extVerifier{Verifier: v, ext: "." + strings.TrimPrefix(opts.Ext, ".")}
+	}
+	return v, nil
+}
+
+// extVerifier overrides the extension of a verifier.
+type extVerifier struct {
+	Verifier
+	ext string
+}
+
+func (v *extVerifier) Ext() string {
+	return v.ext
+}
+
+// VerifyFile verifies the detached signature next to 'file', eg. geth.zip.asc, returning the signer.
+// A missing signature file is reported as an error satisfying os.IsNotExist.
+func VerifyFile(v Verifier, file string) (string, error) {
+	sig, e := ioutil.ReadFile(file + v.Ext())
+	if e != nil {
+		return "", e
+	}
+	f, e := os.Open(file)
+	if e != nil {
+		return "", e
+	}
+	defer f.Close()
+	return v.Verify(f, sig)
+}
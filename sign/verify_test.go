@@ -0,0 +1,131 @@
+package sign
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestVerifyFile verifies signatures made by gpg and ssh-keygen.
+func TestVerifyFile(t *testing.T) {
+	table := []struct {
+		typ  string
+		opts Options
+		want string
+	}{
+		{"pgp", Options{Key: "testdata/pgp-encrypted.pub.asc", Ext: ".gpg"}, "Janus Test <janus@example.com>"},
+		{"ssh", Options{Key: "testdata/allowed_signers", Ext: ".sshsig"}, "janus@example.com SHA256:"},
+	}
+	for _, tt := range table {
+		v, e := NewVerifier(tt.typ, tt.opts)
+		if e != nil {
+			t.Fatal(e)
+		}
+		signer, e := VerifyFile(v, "testdata/artifact.txt")
+		if e != nil || !strings.HasPrefix(signer, tt.want) {
+			t.Errorf("%s: got: %s, %v, want: %s", tt.typ, signer, e, tt.want)
+		}
+	}
+
+	v, _ := NewVerifier("pgp", Options{Key: "testdata/pgp-encrypted.pub.asc"})
+	if _, e := VerifyFile(v, "testdata/artifact.txt"); !os.IsNotExist(e) {
+		t.Errorf("got: %v, want not exist", e)
+	}
+	// Another namespace.
+	v, _ = NewVerifier("ssh", Options{Key: "testdata/allowed_signers", Ext: ".sshsig", Namespace: "git"})
+	if _, e := VerifyFile(v, "testdata/artifact.txt"); e == nil {
+		t.Error("want error for another namespace")
+	}
+}
+
+// TestVerifier signs and verifies with each signer, and checks tampered content and untrusted keys are rejected.
+func TestVerifier(t *testing.T) {
+	dir, e := ioutil.TempDir("", "janus-verify")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	minisignKey := filepath.Join(dir, "minisign.key")
+	pub, keyID := writeMinisignKey(t, minisignKey, "")
+	minisignPub := filepath.Join(dir, "minisign.pub")
+	content := "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), pub...)) + "\n"
+	if e := ioutil.WriteFile(minisignPub, []byte(content), 0644); e != nil {
+		t.Fatal(e)
+	}
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	otherMinisignPub := filepath.Join(dir, "other.pub")
+	content = base64.StdEncoding.EncodeToString(append([]byte("Ed12345678"), otherPub...)) + "\n"
+	if e := ioutil.WriteFile(otherMinisignPub, []byte(content), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	table := []struct {
+		typ                     string
+		key, trusted, untrusted string
+	}{
+		{"pgp", "testdata/pgp-encrypted.asc", "testdata/pgp-encrypted.pub.asc", ""},
+		{"minisign", minisignKey, minisignPub, otherMinisignPub},
+		{"ssh", "testdata/ssh-encrypted", "testdata/ssh-encrypted.pub", "testdata/allowed_signers"},
+		{"ssh", "testdata/ssh-rsa", "testdata/allowed_signers", "testdata/ssh-encrypted.pub"},
+	}
+	for _, tt := range table {
+		s, e := NewSigner(tt.typ, Options{Key: tt.key, Passphrase: testPassphrase})
+		if e != nil {
+			t.Fatal(e)
+		}
+		sig, e := s.Sign("geth.zip", strings.NewReader("geth"))
+		if e != nil {
+			t.Fatal(e)
+		}
+		v, e := NewVerifier(tt.typ, Options{Key: tt.trusted})
+		if e != nil {
+			t.Fatal(e)
+		}
+		if v.Ext() != s.Ext() {
+			t.Errorf("%s: got: %s, want: %s", tt.typ, v.Ext(), s.Ext())
+		}
+		if signer, e := v.Verify(strings.NewReader("geth"), sig); e != nil || signer == "" {
+			t.Errorf("%s %s: got: %q, %v", tt.typ, tt.key, signer, e)
+		}
+		if _, e := v.Verify(strings.NewReader("geth!"), sig); e == nil {
+			t.Errorf("%s %s: want error for tampered content", tt.typ, tt.key)
+		}
+		if tt.untrusted == "" {
+			continue
+		}
+		v, e = NewVerifier(tt.typ, Options{Key: tt.untrusted})
+		if e != nil {
+			t.Fatal(e)
+		}
+		if _, e := v.Verify(strings.NewReader("geth"), sig); e == nil || !strings.Contains(e.Error(), "untrusted") {
+			t.Errorf("%s %s: got: %v, want untrusted key", tt.typ, tt.key, e)
+		}
+	}
+}
+
+func TestParseAllowedSigner(t *testing.T) {
+	key := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIE9FJ7QxTb0eBNu4lgGnDtqPWkZFmVn0zGmCQ6PpOiBi"
+	table := []struct {
+		line       string
+		principals string
+		namespaces []string
+	}{
+		{key + " janus@example.com", "janus@example.com", nil},
+		{"janus@example.com,ci@example.com " + key, "janus@example.com,ci@example.com", nil},
+		{`janus@example.com namespaces="file,git" ` + key, "janus@example.com", []string{"file", "git"}},
+	}
+	for _, tt := range table {
+		s, e := parseAllowedSigner(tt.line)
+		if e != nil || s.principals != tt.principals || strings.Join(s.namespaces, ",") != strings.Join(tt.namespaces, ",") {
+			t.Errorf("%s: got: %+v, %v", tt.line, s, e)
+		}
+	}
+	if _, e := parseAllowedSigner("janus@example.com ssh-ed25519 nope"); e == nil {
+		t.Error("want error for invalid key")
+	}
+}
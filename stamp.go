@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/ETCDEVTeam/janus/gitvv"
+	"github.com/ETCDEVTeam/janus/stamp"
+)
+
+// errStampMismatch is returned by runStamp in check mode if any file disagrees with its version.
+var errStampMismatch = errors.New("files disagree with the git version")
+
+// runStamp writes the version of 'dir' into each target, or with 'check' only verifies them.
+// Targets use 'format' unless they have their own; relative files are resolved against 'dir'.
+// Versions with unknown segments, eg. v?.?.? without a tag, fail before any file is written.
+func runStamp(w io.Writer, targets []stamp.Target, dir string, config *gitvv.Config, format string, check bool) error {
+	if len(targets) == 0 {
+		return errors.New("no files to stamp, use -file or the stamp section of the config")
+	}
+	if _, e := gitvv.GetVersionInfo(dir, config); e != nil {
+		return e
+	}
+	targets = append([]stamp.Target(nil), targets...)
+	versions := make([]string, len(targets))
+	for i := range targets {
+		t := &targets[i]
+		if t.Format == "" {
+			t.Format = format
+		}
+		if dir != "" && !filepath.IsAbs(t.File) {
+			t.File = filepath.Join(dir, t.File)
+		}
+		versions[i] = gitvv.GetVersionWithConfig(t.Format, dir, config)
+		if strings.Contains(versions[i], "?") {
+			return fmt.Errorf("%s: version '%s' has unknown segments, is HEAD above a version tag?", t.File, versions[i])
+		}
+	}
+
+	mismatch := false
+	for i, t := range targets {
+		v := versions[i]
+		if check {
+			e := stamp.Check(t, v)
+			if _, ok := e.(*stamp.MismatchError); ok {
+				fmt.Fprintln(w, e)
+				mismatch = true
+				continue
+			}
+			if e != nil {
+				return e
+			}
+			fmt.Fprintf(w, "%s: ok %s\n", t.File, v)
+			continue
+		}
+
+		changed, e := stamp.Stamp(t, v)
+		if e != nil {
+			return e
+		}
+		if changed {
+			fmt.Fprintf(w, "%s: stamped %s\n", t.File, v)
+		} else {
+			fmt.Fprintf(w, "%s: unchanged %s\n", t.File, v)
+		}
+	}
+	if mismatch {
+		return errStampMismatch
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package stamp
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+)
+
+func init() {
+	Register("go", newGoUpdater)
+}
+
+// goUpdater updates a string or integer constant or variable by name, eg. Version or VersionMajor.
+// Only the literal is replaced, so the file stays gofmt'd.
+type goUpdater struct {
+	name string
+}
+
+func newGoUpdater(key string) (Updater, error) {
+	if !token.IsIdentifier(key) {
+		return nil, fmt.Errorf("'%s' is not a Go identifier", key)
+	}
+	return &goUpdater{name: key}, nil
+}
+
+// find gets the literal assigned to the constant or variable.
+func (u *goUpdater) find(content []byte) (*ast.BasicLit, *token.FileSet, error) {
+	fset := token.NewFileSet()
+	f, e := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if e != nil {
+		return nil, nil, e
+	}
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || (gd.Tok != token.CONST && gd.Tok != token.VAR) {
+			continue
+		}
+		for _, s := range gd.Specs {
+			vs := s.(*ast.ValueSpec)
+			for i, n := range vs.Names {
+				if n.Name != u.name {
+					continue
+				}
+				if i >= len(vs.Values) {
+					return nil, nil, fmt.Errorf("%s has no value", u.name)
+				}
+				lit, ok := vs.Values[i].(*ast.BasicLit)
+				if !ok || (lit.Kind != token.STRING && lit.Kind != token.INT) {
+					return nil, nil, fmt.Errorf("%s is not a string or integer literal", u.name)
+				}
+				return lit, fset, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("const or var %s not found", u.name)
+}
+
+func (u *goUpdater) Get(content []byte) (string, error) {
+	lit, _, e := u.find(content)
+	if e != nil {
+		return "", e
+	}
+	if lit.Kind == token.STRING {
+		return strconv.Unquote(lit.Value)
+	}
+	return lit.Value, nil
+}
+
+func (u *goUpdater) Set(content []byte, version string) ([]byte, error) {
+	lit, fset, e := u.find(content)
+	if e != nil {
+		return nil, e
+	}
+	v := strconv.Quote(version)
+	if lit.Kind == token.INT {
+		if _, e := strconv.ParseUint(version, 10, 64); e != nil {
+			return nil, fmt.Errorf("%s is an integer, but version '%s' is not", u.name, version)
+		}
+		v = version
+	}
+	start := fset.Position(lit.Pos()).Offset
+	end := fset.Position(lit.End()).Offset
+	return splice(content, start, end, v), nil
+}
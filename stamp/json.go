@@ -0,0 +1,133 @@
+package stamp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register("json", newJSONUpdater)
+}
+
+// jsonUpdater updates a string value at a dotted path, eg. version or packages.0.version,
+// leaving the rest of the document's formatting untouched.
+type jsonUpdater struct {
+	path []string
+}
+
+func newJSONUpdater(key string) (Updater, error) {
+	return &jsonUpdater{path: strings.Split(strings.TrimPrefix(key, "$."), ".")}, nil
+}
+
+func (u *jsonUpdater) Get(content []byte) (string, error) {
+	start, end, e := u.find(content)
+	if e != nil {
+		return "", e
+	}
+	var s string
+	if e := json.Unmarshal(content[start:end], &s); e != nil {
+		return "", e
+	}
+	return s, nil
+}
+
+func (u *jsonUpdater) Set(content []byte, version string) ([]byte, error) {
+	start, end, e := u.find(content)
+	if e != nil {
+		return nil, e
+	}
+	b := &bytes.Buffer{}
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	if e := enc.Encode(version); e != nil {
+		return nil, e
+	}
+	return splice(content, start, end, strings.TrimSpace(b.String())), nil
+}
+
+// find gets the byte span of the quoted string at the path.
+func (u *jsonUpdater) find(content []byte) (int, int, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	start, end, e := findJSON(dec, content, u.path)
+	if e != nil {
+		return 0, 0, fmt.Errorf("json path '%s': %v", strings.Join(u.path, "."), e)
+	}
+	return start, end, nil
+}
+
+func findJSON(dec *json.Decoder, content []byte, path []string) (int, int, error) {
+	before := dec.InputOffset()
+	tok, e := dec.Token()
+	if e != nil {
+		return 0, 0, e
+	}
+	if len(path) == 0 {
+		if _, ok := tok.(string); !ok {
+			return 0, 0, fmt.Errorf("value is not a string: %v", tok)
+		}
+		end := int(dec.InputOffset())
+		start := bytes.IndexByte(content[before:end], '"')
+		if start < 0 {
+			return 0, 0, errors.New("string value not found")
+		}
+		return int(before) + start, end, nil
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		for dec.More() {
+			k, e := dec.Token()
+			if e != nil {
+				return 0, 0, e
+			}
+			if k == path[0] {
+				return findJSON(dec, content, path[1:])
+			}
+			if e := skipJSON(dec); e != nil {
+				return 0, 0, e
+			}
+		}
+	case json.Delim('['):
+		idx, e := strconv.Atoi(path[0])
+		if e != nil {
+			return 0, 0, fmt.Errorf("'%s' is not an array index", path[0])
+		}
+		for i := 0; dec.More(); i++ {
+			if i == idx {
+				return findJSON(dec, content, path[1:])
+			}
+			if e := skipJSON(dec); e != nil {
+				return 0, 0, e
+			}
+		}
+	}
+	return 0, 0, fmt.Errorf("key '%s' not found", path[0])
+}
+
+// skipJSON skips the next value.
+func skipJSON(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, e := dec.Token()
+		if e == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if e != nil {
+			return e
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// Package stamp writes versions into source and manifest files,
+// eg. package.json, params/version.go or Info.plist.
+package stamp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Updater reads and writes a version in file content.
+type Updater interface {
+	// Get returns the version currently in 'content'.
+	Get(content []byte) (string, error)
+	// Set returns 'content' with the version replaced by 'version'.
+	Set(content []byte, version string) ([]byte, error)
+}
+
+// multiGetter is an Updater of a version written in several places of a file, eg. every match of a regex,
+// which Check compares each of.
+type multiGetter interface {
+	// GetAll returns the versions currently in 'content', in order.
+	GetAll(content []byte) ([]string, error)
+}
+
+// NewUpdaterFunc creates an updater for a selector, eg. a JSON path or Go const name.
+type NewUpdaterFunc func(key string) (Updater, error)
+
+var updaters = make(map[string]NewUpdaterFunc)
+
+// Register makes an updater available by type name.
+func Register(typ string, fn NewUpdaterFunc) {
+	updaters[typ] = fn
+}
+
+// Types returns the registered updater type names.
+func Types() []string {
+	var ts []string
+	for t := range updaters {
+		ts = append(ts, t)
+	}
+	sort.Strings(ts)
+	return ts
+}
+
+// NewUpdater creates an updater of a registered type.
+func NewUpdater(typ, key string) (Updater, error) {
+	fn, ok := updaters[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown stamp type '%s', want one of: %s", typ, strings.Join(Types(), ", "))
+	}
+	if key == "" {
+		return nil, fmt.Errorf("stamp type '%s' requires a key", typ)
+	}
+	return fn(key)
+}
+
+// Target is a file to stamp.
+type Target struct {
+	File string `json:"file"`
+	// Type is the updater type, eg. json, toml, yaml, go, regex, xml, plist
+	Type string `json:"type"`
+	// Key selects the version in the file, eg. a JSON path, Go const name or regex
+	Key string `json:"key"`
+	// Format is the gitvv format of the version for this file, if not the default.
+	Format string `json:"format,omitempty"`
+}
+
+// ParseTarget parses a target of the form TYPE:FILE:KEY, eg. json:package.json:version
+// FILE may be a Windows path with a drive, eg. regex:C:\geth\geth.rc:FILEVERSION (\d+,\d+,\d+,\d+)
+func ParseTarget(s string) (Target, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return Target{}, fmt.Errorf("invalid stamp target '%s', want TYPE:FILE:KEY", s)
+	}
+	typ, rest := parts[0], parts[1]
+	drive := ""
+	if len(rest) > 2 && rest[1] == ':' && (rest[2] == '\\' || rest[2] == '/') &&
+		('a' <= rest[0] && rest[0] <= 'z' || 'A' <= rest[0] && rest[0] <= 'Z') {
+		drive, rest = rest[:2], rest[2:]
+	}
+	parts = strings.SplitN(rest, ":", 2)
+	if typ == "" || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Target{}, fmt.Errorf("invalid stamp target '%s', want TYPE:FILE:KEY", s)
+	}
+	return Target{Type: typ, File: drive + parts[0], Key: parts[1]}, nil
+}
+
+// String returns the target in the form TYPE:FILE:KEY
+func (t Target) String() string {
+	return t.Type + ":" + t.File + ":" + t.Key
+}
+
+// MismatchError is returned by Check if a file disagrees with the expected version.
+type MismatchError struct {
+	Target Target
+	Got    string
+	Want   string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("%s: version is '%s', want '%s'", e.Target, e.Got, e.Want)
+}
+
+// Stamp writes 'version' into the target file, reporting whether it changed.
+func Stamp(t Target, version string) (bool, error) {
+	u, e := NewUpdater(t.Type, t.Key)
+	if e != nil {
+		return false, e
+	}
+	b, e := ioutil.ReadFile(t.File)
+	if e != nil {
+		return false, e
+	}
+	out, e := u.Set(b, version)
+	if e != nil {
+		return false, fmt.Errorf("%s: %v", t, e)
+	}
+	if string(out) == string(b) {
+		return false, nil
+	}
+	return true, writeFileAtomic(t.File, out)
+}
+
+// Check verifies the target file has 'version', everywhere it is written, returning a *MismatchError if not.
+func Check(t Target, version string) error {
+	u, e := NewUpdater(t.Type, t.Key)
+	if e != nil {
+		return e
+	}
+	b, e := ioutil.ReadFile(t.File)
+	if e != nil {
+		return e
+	}
+	var got []string
+	if m, ok := u.(multiGetter); ok {
+		got, e = m.GetAll(b)
+	} else {
+		var v string
+		v, e = u.Get(b)
+		got = []string{v}
+	}
+	if e != nil {
+		return fmt.Errorf("%s: %v", t, e)
+	}
+	for _, v := range got {
+		if v != version {
+			return &MismatchError{Target: t, Got: v, Want: version}
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic replaces a file by renaming a temporary file into place, keeping its mode.
+func writeFileAtomic(path string, b []byte) error {
+	fi, e := os.Stat(path)
+	if e != nil {
+		return e
+	}
+	f, e := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if e != nil {
+		return e
+	}
+	tmp := f.Name()
+	if _, e := f.Write(b); e != nil {
+		f.Close()
+		os.Remove(tmp)
+		return e
+	}
+	if e := f.Close(); e != nil {
+		os.Remove(tmp)
+		return e
+	}
+	if e := os.Chmod(tmp, fi.Mode()); e != nil {
+		os.Remove(tmp)
+		return e
+	}
+	if e := os.Rename(tmp, path); e != nil {
+		os.Remove(tmp)
+		return e
+	}
+	return nil
+}
+
+// splice replaces content[start:end] with 'v'.
+func splice(content []byte, start, end int, v string) []byte {
+	out := make([]byte, 0, len(content)-(end-start)+len(v))
+	out = append(out, content[:start]...)
+	out = append(out, v...)
+	return append(out, content[end:]...)
+}
@@ -0,0 +1,246 @@
+package stamp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdaters(t *testing.T) {
+	table := []struct {
+		typ, key, content string
+		get               string
+		want              string
+	}{
+		{"json", "version", `{
+  "name": "geth",
+  "version": "3.5.0",
+  "deps": {"version": "1.0.0"}
+}
+`, "3.5.0", `{
+  "name": "geth",
+  "version": "3.6.0-beta.1",
+  "deps": {"version": "1.0.0"}
+}
+`},
+		{"json", "packages.1.version", `{"packages": [{"version": "1"}, {"version": "2"}]}`, "2",
+			`{"packages": [{"version": "1"}, {"version": "3.6.0-beta.1"}]}`},
+		{"toml", "package.version", `version = "0.0.0"
+
+[package]
+name = "geth"
+version = '3.5.0' # comment
+`, "3.5.0", `version = "0.0.0"
+
+[package]
+name = "geth"
+version = '3.6.0-beta.1' # comment
+`},
+		{"yaml", "app.version", `name: geth
+app:
+  name: geth
+  version: 3.5.0 # comment
+version: "0.0.0"
+`, "3.5.0", `name: geth
+app:
+  name: geth
+  version: 3.6.0-beta.1 # comment
+version: "0.0.0"
+`},
+		{"yaml", "version", `version: "3.5.0"
+`, "3.5.0", `version: "3.6.0-beta.1"
+`},
+		{"go", "Version", `package params
+
+// Version is the version of geth.
+const Version = "3.5.0"
+`, "3.5.0", `package params
+
+// Version is the version of geth.
+const Version = "3.6.0-beta.1"
+`},
+		{"regex", `VALUE "ProductVersion", "([^"]*)"`, `VALUE "ProductVersion", "3.5.0"
+VALUE "FileVersion", "3.5.0"
+VALUE "ProductVersion", "3.5.0"
+`, "3.5.0", `VALUE "ProductVersion", "3.6.0-beta.1"
+VALUE "FileVersion", "3.5.0"
+VALUE "ProductVersion", "3.6.0-beta.1"
+`},
+		{"xml", "project/version", `<?xml version="1.0"?>
+<project xmlns="http://maven.apache.org/POM/4.0.0">
+  <parent><version>1.0</version></parent>
+  <version>3.5.0</version>
+</project>
+`, "3.5.0", `<?xml version="1.0"?>
+<project xmlns="http://maven.apache.org/POM/4.0.0">
+  <parent><version>1.0</version></parent>
+  <version>3.6.0-beta.1</version>
+</project>
+`},
+		{"plist", "CFBundleShortVersionString", `<plist version="1.0">
+<dict>
+	<key>CFBundleName</key>
+	<string>Geth</string>
+	<key>CFBundleShortVersionString</key>
+	<string>3.5.0</string>
+</dict>
+</plist>
+`, "3.5.0", `<plist version="1.0">
+<dict>
+	<key>CFBundleName</key>
+	<string>Geth</string>
+	<key>CFBundleShortVersionString</key>
+	<string>3.6.0-beta.1</string>
+</dict>
+</plist>
+`},
+	}
+	for _, tt := range table {
+		u, e := NewUpdater(tt.typ, tt.key)
+		if e != nil {
+			t.Fatalf("%s %s: %v", tt.typ, tt.key, e)
+		}
+		got, e := u.Get([]byte(tt.content))
+		if e != nil || got != tt.get {
+			t.Errorf("%s %s: got: %q, %v, want: %q", tt.typ, tt.key, got, e, tt.get)
+		}
+		b, e := u.Set([]byte(tt.content), "3.6.0-beta.1")
+		if e != nil {
+			t.Errorf("%s %s: %v", tt.typ, tt.key, e)
+			continue
+		}
+		if string(b) != tt.want {
+			t.Errorf("%s %s: got:\n%s\nwant:\n%s", tt.typ, tt.key, b, tt.want)
+		}
+	}
+}
+
+func TestUpdaters_errors(t *testing.T) {
+	table := []struct {
+		typ, key, content string
+	}{
+		{"json", "missing", `{"version": "3.5.0"}`},
+		{"json", "version", `{"version": 3}`},
+		{"toml", "version", `[package]
+version = "3.5.0"
+`},
+		{"yaml", "app", `app:
+  version: 3.5.0
+`},
+		{"go", "Version", `package params
+
+var Version = fmt.Sprint(3)
+`},
+		{"go", "VersionMajor", `package params
+
+const VersionMajor = 3
+`},
+		{"regex", `version=(\d+)`, `version=x`},
+		{"xml", "project/version", `<project></project>`},
+	}
+	for _, tt := range table {
+		u, e := NewUpdater(tt.typ, tt.key)
+		if e != nil {
+			t.Fatalf("%s %s: %v", tt.typ, tt.key, e)
+		}
+		if _, e := u.Set([]byte(tt.content), "3.6.0-beta.1"); e == nil {
+			t.Errorf("%s %s: want error", tt.typ, tt.key)
+		}
+	}
+	for _, s := range [][2]string{{"unknown", "version"}, {"json", ""}, {"regex", "no-group"}, {"go", "not-ident"}} {
+		if _, e := NewUpdater(s[0], s[1]); e == nil {
+			t.Errorf("%s %s: want error", s[0], s[1])
+		}
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	got, e := ParseTarget(`regex:res/geth.rc:FILEVERSION (\d+,\d+,\d+):0`)
+	if e != nil {
+		t.Fatal(e)
+	}
+	want := Target{Type: "regex", File: "res/geth.rc", Key: `FILEVERSION (\d+,\d+,\d+):0`}
+	if got != want {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+	table := []struct {
+		s    string
+		want Target
+	}{
+		{`regex:C:\geth\geth.rc:FILEVERSION (\d+):0`, Target{Type: "regex", File: `C:\geth\geth.rc`, Key: `FILEVERSION (\d+):0`}},
+		{`json:d:/geth/package.json:version`, Target{Type: "json", File: `d:/geth/package.json`, Key: "version"}},
+		{`json:C:version`, Target{Type: "json", File: "C", Key: "version"}},
+	}
+	for _, tt := range table {
+		got, e := ParseTarget(tt.s)
+		if e != nil || got != tt.want {
+			t.Errorf("%s: got: %+v, %v, want: %+v", tt.s, got, e, tt.want)
+		}
+	}
+	for _, s := range []string{"json", "json:package.json", "json::version", ":package.json:version", `json:C:\package.json`} {
+		if _, e := ParseTarget(s); e == nil {
+			t.Errorf("%s: want error", s)
+		}
+	}
+}
+
+func TestStampAndCheck(t *testing.T) {
+	dir, e := ioutil.TempDir("", "janus-stamp")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "package.json")
+	if e := ioutil.WriteFile(file, []byte(`{"version": "3.5.0"}`), 0640); e != nil {
+		t.Fatal(e)
+	}
+	target := Target{Type: "json", File: file, Key: "version"}
+
+	if e := Check(target, "3.5.0"); e != nil {
+		t.Errorf("check: %v", e)
+	}
+	e = Check(target, "3.6.0")
+	if m, ok := e.(*MismatchError); !ok || m.Got != "3.5.0" || m.Want != "3.6.0" {
+		t.Errorf("check: got: %v, want mismatch", e)
+	}
+
+	changed, e := Stamp(target, "3.6.0")
+	if e != nil || !changed {
+		t.Fatalf("stamp: got: %v, %v, want changed", changed, e)
+	}
+	if e := Check(target, "3.6.0"); e != nil {
+		t.Errorf("check after stamp: %v", e)
+	}
+	if fi, e := os.Stat(file); e != nil || fi.Mode().Perm() != 0640 {
+		t.Errorf("mode not kept: %v, %v", fi.Mode(), e)
+	}
+	if changed, e := Stamp(target, "3.6.0"); e != nil || changed {
+		t.Errorf("restamp: got: %v, %v, want unchanged", changed, e)
+	}
+}
+
+func TestCheck_regexMatches(t *testing.T) {
+	dir, e := ioutil.TempDir("", "janus-stamp")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "geth.rc")
+	if e := ioutil.WriteFile(file, []byte("FILEVERSION 3,6,0,0\nPRODUCTVERSION 3,5,0,0\n"), 0644); e != nil {
+		t.Fatal(e)
+	}
+	target := Target{Type: "regex", File: file, Key: `VERSION (\d+,\d+,\d+,\d+)`}
+
+	// A stale later match fails the check.
+	e = Check(target, "3,6,0,0")
+	if m, ok := e.(*MismatchError); !ok || m.Got != "3,5,0,0" {
+		t.Errorf("check: got: %v, want mismatch of PRODUCTVERSION", e)
+	}
+	if _, e := Stamp(target, "3,6,0,0"); e != nil {
+		t.Fatal(e)
+	}
+	if e := Check(target, "3,6,0,0"); e != nil {
+		t.Errorf("check after stamp: %v", e)
+	}
+}
@@ -0,0 +1,284 @@
+package stamp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register("toml", newTOMLUpdater)
+	Register("yaml", newYAMLUpdater)
+	Register("regex", newRegexUpdater)
+}
+
+// line is a line of content without its line ending, at byte offset 'start'.
+type line struct {
+	start int
+	text  string
+}
+
+func splitLines(content []byte) []line {
+	var ls []line
+	start := 0
+	for start < len(content) {
+		end := bytes.IndexByte(content[start:], '\n')
+		if end < 0 {
+			end = len(content)
+		} else {
+			end += start
+		}
+		ls = append(ls, line{start, strings.TrimSuffix(string(content[start:end]), "\r")})
+		start = end + 1
+	}
+	return ls
+}
+
+// span is the byte range of a value, and whether and how it is quoted.
+type span struct {
+	start, end int
+	quote      byte // '"', '\'' or 0 for plain values
+}
+
+func (s span) get(content []byte) (string, error) {
+	v := string(content[s.start:s.end])
+	switch s.quote {
+	case '"':
+		return strconv.Unquote(v)
+	case '\'':
+		return strings.Trim(v, "'"), nil
+	}
+	return v, nil
+}
+
+func (s span) set(content []byte, version string) ([]byte, error) {
+	switch s.quote {
+	case '"':
+		return splice(content, s.start, s.end, strconv.Quote(version)), nil
+	case '\'':
+		if strings.Contains(version, "'") {
+			return nil, fmt.Errorf("cannot write '%s' as a single-quoted string", version)
+		}
+		return splice(content, s.start, s.end, "'"+version+"'"), nil
+	}
+	if !rePlainValue.MatchString(version) {
+		return splice(content, s.start, s.end, strconv.Quote(version)), nil
+	}
+	return splice(content, s.start, s.end, version), nil
+}
+
+// rePlainValue matches values safe to write unquoted.
+var rePlainValue = regexp.MustCompile(`^[A-Za-z0-9._+-]+$`)
+
+// quotedSpan gets the span of a quoted or plain value at the start of 's', found at byte offset 'offset'.
+func quotedSpan(s string, offset int, allowPlain bool) (span, error) {
+	if s == "" {
+		return span{}, errors.New("empty value")
+	}
+	switch q := s[0]; q {
+	case '"':
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+				continue
+			}
+			if s[i] == '"' {
+				return span{offset, offset + i + 1, q}, nil
+			}
+		}
+		return span{}, errors.New("unterminated string")
+	case '\'':
+		if i := strings.IndexByte(s[1:], '\''); i >= 0 {
+			return span{offset, offset + i + 2, q}, nil
+		}
+		return span{}, errors.New("unterminated string")
+	}
+	if !allowPlain {
+		return span{}, fmt.Errorf("value is not a string: %s", s)
+	}
+	// Plain value, up to a comment.
+	v := s
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = v[:i]
+	}
+	v = strings.TrimRight(v, " \t")
+	return span{offset, offset + len(v), 0}, nil
+}
+
+// tomlUpdater updates a string value by dotted key, eg. version or package.version for [package]
+type tomlUpdater struct {
+	key string
+}
+
+var (
+	reTOMLTable = regexp.MustCompile(`^\s*\[\[?\s*([^\[\]]+?)\s*\]\]?\s*(#.*)?$`)
+	reTOMLKey   = regexp.MustCompile(`^\s*([A-Za-z0-9_.-]+|"[^"]*")\s*=\s*`)
+)
+
+func newTOMLUpdater(key string) (Updater, error) {
+	return &tomlUpdater{key: key}, nil
+}
+
+func (u *tomlUpdater) find(content []byte) (span, error) {
+	table := ""
+	for _, l := range splitLines(content) {
+		if m := reTOMLTable.FindStringSubmatch(l.text); m != nil {
+			table = m[1]
+			continue
+		}
+		m := reTOMLKey.FindStringSubmatchIndex(l.text)
+		if m == nil {
+			continue
+		}
+		k := strings.Trim(l.text[m[2]:m[3]], `"`)
+		if table != "" {
+			k = table + "." + k
+		}
+		if k != u.key {
+			continue
+		}
+		return quotedSpan(l.text[m[1]:], l.start+m[1], false)
+	}
+	return span{}, fmt.Errorf("toml key '%s' not found", u.key)
+}
+
+func (u *tomlUpdater) Get(content []byte) (string, error) {
+	s, e := u.find(content)
+	if e != nil {
+		return "", e
+	}
+	return s.get(content)
+}
+
+func (u *tomlUpdater) Set(content []byte, version string) ([]byte, error) {
+	s, e := u.find(content)
+	if e != nil {
+		return nil, e
+	}
+	return s.set(content, version)
+}
+
+// yamlUpdater updates a scalar value of a block mapping by dotted key, eg. version or app.version
+type yamlUpdater struct {
+	path []string
+}
+
+var reYAMLKey = regexp.MustCompile(`^(\s*)([A-Za-z0-9_.-]+|"[^"]*"|'[^']*')\s*:(\s+|$)`)
+
+func newYAMLUpdater(key string) (Updater, error) {
+	return &yamlUpdater{path: strings.Split(key, ".")}, nil
+}
+
+func (u *yamlUpdater) find(content []byte) (span, error) {
+	type entry struct {
+		indent int
+		key    string
+	}
+	var stack []entry
+	for _, l := range splitLines(content) {
+		trimmed := strings.TrimSpace(l.text)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		m := reYAMLKey.FindStringSubmatchIndex(l.text)
+		if m == nil {
+			continue
+		}
+		indent := m[3] - m[2]
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		stack = append(stack, entry{indent, strings.Trim(l.text[m[4]:m[5]], `"'`)})
+		if len(stack) != len(u.path) {
+			continue
+		}
+		match := true
+		for i := range stack {
+			if stack[i].key != u.path[i] {
+				match = false
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+		rest := l.text[m[1]:]
+		if strings.TrimSpace(rest) == "" {
+			return span{}, fmt.Errorf("yaml key '%s' is not a scalar", strings.Join(u.path, "."))
+		}
+		return quotedSpan(rest, l.start+m[1], true)
+	}
+	return span{}, fmt.Errorf("yaml key '%s' not found", strings.Join(u.path, "."))
+}
+
+func (u *yamlUpdater) Get(content []byte) (string, error) {
+	s, e := u.find(content)
+	if e != nil {
+		return "", e
+	}
+	return s.get(content)
+}
+
+func (u *yamlUpdater) Set(content []byte, version string) ([]byte, error) {
+	s, e := u.find(content)
+	if e != nil {
+		return nil, e
+	}
+	return s.set(content, version)
+}
+
+// regexUpdater replaces the first capture group of every match, eg. FILEVERSION (\d+,\d+,\d+,\d+)
+type regexUpdater struct {
+	re *regexp.Regexp
+}
+
+func newRegexUpdater(key string) (Updater, error) {
+	re, e := regexp.Compile(key)
+	if e != nil {
+		return nil, e
+	}
+	if re.NumSubexp() < 1 {
+		return nil, fmt.Errorf("regex '%s' must have a capture group for the version", key)
+	}
+	return &regexUpdater{re: re}, nil
+}
+
+func (u *regexUpdater) Get(content []byte) (string, error) {
+	m := u.re.FindSubmatch(content)
+	if m == nil {
+		return "", fmt.Errorf("regex '%s' does not match", u.re)
+	}
+	return string(m[1]), nil
+}
+
+// GetAll gets the first capture group of every match, see Check.
+func (u *regexUpdater) GetAll(content []byte) ([]string, error) {
+	var vs []string
+	for _, m := range u.re.FindAllSubmatch(content, -1) {
+		if m[1] != nil {
+			vs = append(vs, string(m[1]))
+		}
+	}
+	if vs == nil {
+		return nil, fmt.Errorf("regex '%s' does not match", u.re)
+	}
+	return vs, nil
+}
+
+func (u *regexUpdater) Set(content []byte, version string) ([]byte, error) {
+	ms := u.re.FindAllSubmatchIndex(content, -1)
+	if ms == nil {
+		return nil, fmt.Errorf("regex '%s' does not match", u.re)
+	}
+	// Replace from the end, keeping earlier offsets valid.
+	for i := len(ms) - 1; i >= 0; i-- {
+		if ms[i][2] < 0 {
+			continue
+		}
+		content = splice(content, ms[i][2], ms[i][3], version)
+	}
+	return content, nil
+}
@@ -0,0 +1,161 @@
+package stamp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func init() {
+	Register("xml", newXMLUpdater)
+	Register("plist", newPlistUpdater)
+}
+
+// xmlUpdater updates the text of an element by path from the root, eg. project/version for pom.xml.
+// Namespaces are ignored.
+type xmlUpdater struct {
+	path []string
+}
+
+func newXMLUpdater(key string) (Updater, error) {
+	return &xmlUpdater{path: strings.Split(strings.Trim(key, "/"), "/")}, nil
+}
+
+func (u *xmlUpdater) find(content []byte) (int, int, error) {
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	var stack []string
+	for {
+		tok, e := dec.Token()
+		if e == io.EOF {
+			return 0, 0, fmt.Errorf("xml element '%s' not found", strings.Join(u.path, "/"))
+		}
+		if e != nil {
+			return 0, 0, e
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+			if equalPath(stack, u.path) {
+				return xmlText(dec)
+			}
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+func equalPath(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// xmlText gets the byte span of the text of the element just started.
+func xmlText(dec *xml.Decoder) (int, int, error) {
+	start := int(dec.InputOffset())
+	tok, e := dec.Token()
+	if e != nil {
+		return 0, 0, e
+	}
+	switch tok.(type) {
+	case xml.CharData:
+		return start, int(dec.InputOffset()), nil
+	case xml.EndElement:
+		// Empty element, insert the text.
+		return start, start, nil
+	}
+	return 0, 0, fmt.Errorf("xml element does not contain only text")
+}
+
+func getXMLText(content []byte, start, end int) (string, error) {
+	var s string
+	e := xml.Unmarshal([]byte("<v>"+string(content[start:end])+"</v>"), &s)
+	return strings.TrimSpace(s), e
+}
+
+func setXMLText(content []byte, start, end int, version string) ([]byte, error) {
+	b := &bytes.Buffer{}
+	if e := xml.EscapeText(b, []byte(version)); e != nil {
+		return nil, e
+	}
+	return splice(content, start, end, b.String()), nil
+}
+
+func (u *xmlUpdater) Get(content []byte) (string, error) {
+	start, end, e := u.find(content)
+	if e != nil {
+		return "", e
+	}
+	return getXMLText(content, start, end)
+}
+
+func (u *xmlUpdater) Set(content []byte, version string) ([]byte, error) {
+	start, end, e := u.find(content)
+	if e != nil {
+		return nil, e
+	}
+	return setXMLText(content, start, end, version)
+}
+
+// plistUpdater updates the string following a key of a property list, eg. CFBundleShortVersionString
+type plistUpdater struct {
+	key string
+}
+
+func newPlistUpdater(key string) (Updater, error) {
+	return &plistUpdater{key: key}, nil
+}
+
+func (u *plistUpdater) find(content []byte) (int, int, error) {
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	var inKey, found bool
+	for {
+		tok, e := dec.Token()
+		if e == io.EOF {
+			return 0, 0, fmt.Errorf("plist key '%s' not found", u.key)
+		}
+		if e != nil {
+			return 0, 0, e
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if found {
+				if t.Name.Local != "string" {
+					return 0, 0, fmt.Errorf("plist key '%s' is not a string", u.key)
+				}
+				return xmlText(dec)
+			}
+			inKey = t.Name.Local == "key"
+		case xml.CharData:
+			if inKey && strings.TrimSpace(string(t)) == u.key {
+				found = true
+			}
+		case xml.EndElement:
+			inKey = false
+		}
+	}
+}
+
+func (u *plistUpdater) Get(content []byte) (string, error) {
+	start, end, e := u.find(content)
+	if e != nil {
+		return "", e
+	}
+	return getXMLText(content, start, end)
+}
+
+func (u *plistUpdater) Set(content []byte, version string) ([]byte, error) {
+	start, end, e := u.find(content)
+	if e != nil {
+		return nil, e
+	}
+	return setXMLText(content, start, end, version)
+}
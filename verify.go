@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ETCDEVTeam/janus/deploy"
+	"github.com/ETCDEVTeam/janus/sign"
+)
+
+// errVerifyFailed is returned by runVerify if any file fails verification.
+var errVerifyFailed = errors.New("files failed verification")
+
+// Results of checks of a file.
+const (
+	verifyOK       = "ok"
+	verifyMismatch = "mismatch" // checksum differs from the manifest
+	verifyInvalid  = "invalid"  // signature is invalid or of an untrusted key, or checksum of an unknown algorithm
+	verifyMissing  = "missing"  // no signature, or not in the manifest
+)
+
+// fileVerification is the verification result of a file.
+type fileVerification struct {
+	File      string `json:"file"`
+	Checksum  string `json:"checksum,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Signer    string `json:"signer,omitempty"`
+	Error     string `json:"error,omitempty"`
+	OK        bool   `json:"ok"`
+}
+
+func (r fileVerification) String() string {
+	var details []string
+	switch r.Checksum {
+	case verifyOK:
+		details = append(details, "checksum ok")
+	case verifyMismatch:
+		details = append(details, "checksum mismatch")
+	case verifyMissing:
+		details = append(details, "not in checksums")
+	case verifyInvalid:
+		details = append(details, "invalid checksum: "+r.Error)
+	}
+	switch r.Signature {
+	case verifyOK:
+		details = append(details, "signed by "+r.Signer)
+	case verifyInvalid:
+		details = append(details, "invalid signature: "+r.Error)
+	case verifyMissing:
+		details = append(details, "no signature")
+	}
+	status := "ok"
+	if !r.OK {
+		status = "FAILED"
+	}
+	return fmt.Sprintf("%s: %s (%s)", r.File, status, strings.Join(details, ", "))
+}
+
+// checksumAlgorithm gets the algorithm of a hex checksum by its length.
+func checksumAlgorithm(sum string) (string, error) {
+	switch len(sum) {
+	case 64:
+		return "sha256", nil
+	case 128:
+		return "sha512", nil
+	}
+	return "", fmt.Errorf("unknown algorithm of a checksum of %d hex digits, want sha256 (64) or sha512 (128)", len(sum))
+}
+
+// verified is whether the checks of 'r' verify its file. With 'checksums', the file's checksum must match
+// the manifest, and with 'signatures', the file must have a valid signature of its own, or of the manifest
+// listing it if 'manifestTrusted'. An invalid signature of its own fails the file either way.
+func verified(r fileVerification, checksums, signatures, manifestTrusted bool) bool {
+	return (!checksums || r.Checksum == verifyOK) && r.Signature != verifyInvalid &&
+		(!signatures || r.Signature == verifyOK || r.Checksum == verifyOK && manifestTrusted)
+}
+
+// verifySignature sets the signature result of 'r' verifying the signature next to its file with 'v'.
+func verifySignature(r *fileVerification, v sign.Verifier) {
+	signer, e := sign.VerifyFile(v, r.File)
+	switch {
+	case os.IsNotExist(e):
+		r.Signature = verifyMissing
+	case e != nil:
+		r.Signature = verifyInvalid
+		r.Error = e.Error()
+	default:
+		r.Signature = verifyOK
+		r.Signer = signer
+	}
+}
+
+// runVerify verifies the files matching the glob 'files' against the checksums of the manifest 'checksums',
+// eg. SHA256SUMS, and/or their detached signatures with 'v', and writes the results as text or JSON.
+// With a verifier, each file needs a valid signature of its own or of the manifest listing it.
+func runVerify(w io.Writer, files, checksums string, v sign.Verifier, output string) error {
+	if output != "text" && output != "json" {
+		return fmt.Errorf("unknown output format '%s', want text or json", output)
+	}
+	if checksums == "" && v == nil {
+		return errors.New("nothing to verify against, use -checksums and/or -keyring")
+	}
+
+	results := []fileVerification{}
+	failed := 0
+	var sums deploy.Checksums
+	manifestTrusted := false
+	if checksums != "" {
+		f, e := os.Open(checksums)
+		if e != nil {
+			return e
+		}
+		sums, e = deploy.ParseChecksumsManifest(f)
+		f.Close()
+		if e != nil {
+			return fmt.Errorf("%s: %v", checksums, e)
+		}
+		if v != nil {
+			r := fileVerification{File: checksums}
+			verifySignature(&r, v)
+			// Files may still have signatures of their own.
+			r.OK = r.Signature != verifyInvalid
+			manifestTrusted = r.Signature == verifyOK
+			if !r.OK {
+				failed++
+			}
+			results = append(results, r)
+		}
+	}
+
+	matches, e := filepath.Glob(files)
+	if e != nil {
+		return e
+	}
+	passed, total := 0, 0
+	for _, file := range matches {
+		fi, e := os.Stat(file)
+		if e != nil {
+			return e
+		}
+		if fi.IsDir() || checksums != "" && filepath.Clean(file) == filepath.Clean(checksums) || v != nil && strings.HasSuffix(file, v.Ext()) {
+			continue
+		}
+
+		total++
+		r := fileVerification{File: file}
+		if sums != nil {
+			sum, ok := sums[filepath.Base(file)]
+			alg, algErr := checksumAlgorithm(sum)
+			switch {
+			case !ok:
+				r.Checksum = verifyMissing
+			case algErr != nil:
+				r.Checksum = verifyInvalid
+				r.Error = algErr.Error()
+			default:
+				f, e := os.Open(file)
+				if e != nil {
+					return e
+				}
+				got, e := deploy.Checksum(alg, f)
+				f.Close()
+				if e != nil {
+					return e
+				}
+				r.Checksum = verifyOK
+				if got != sum {
+					r.Checksum = verifyMismatch
+				}
+			}
+		}
+		if v != nil {
+			verifySignature(&r, v)
+		}
+
+		r.OK = verified(r, sums != nil, v != nil, manifestTrusted)
+		if r.OK {
+			passed++
+		} else {
+			failed++
+		}
+		results = append(results, r)
+	}
+	if total == 0 {
+		return errors.New("no files matching '-files' pattern were found")
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if e := enc.Encode(results); e != nil {
+			return e
+		}
+	} else {
+		for _, r := range results {
+			fmt.Fprintln(w, r)
+		}
+		fmt.Fprintf(w, "%d of %d files verified\n", passed, total)
+	}
+	if failed > 0 {
+		return errVerifyFailed
+	}
+	return nil
+}
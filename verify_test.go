@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerified(t *testing.T) {
+	table := []struct {
+		checksum, signature             string
+		checksums, signatures, manTrust bool
+		want                            bool
+	}{
+		// Checksums only.
+		{verifyOK, "", true, false, false, true},
+		{verifyMismatch, "", true, false, false, false},
+		{verifyMissing, "", true, false, false, false},
+		{verifyInvalid, "", true, false, false, false},
+		// Signatures only.
+		{"", verifyOK, false, true, false, true},
+		{"", verifyMissing, false, true, false, false},
+		{"", verifyInvalid, false, true, false, false},
+		// Own signatures, with a manifest signed or not.
+		{verifyOK, verifyOK, true, true, false, true},
+		{verifyOK, verifyOK, true, true, true, true},
+		{verifyMismatch, verifyOK, true, true, false, false},
+		{verifyMissing, verifyOK, true, true, true, false},
+		{verifyOK, verifyInvalid, true, true, true, false},
+		// Signed by the manifest only.
+		{verifyOK, verifyMissing, true, true, true, true},
+		{verifyOK, verifyMissing, true, true, false, false},
+		{verifyMismatch, verifyMissing, true, true, true, false},
+		{verifyMissing, verifyMissing, true, true, true, false},
+		{verifyInvalid, verifyMissing, true, true, true, false},
+	}
+	for _, tt := range table {
+		r := fileVerification{Checksum: tt.checksum, Signature: tt.signature}
+		if got := verified(r, tt.checksums, tt.signatures, tt.manTrust); got != tt.want {
+			t.Errorf("%+v: got: %v, want: %v", tt, got, tt.want)
+		}
+	}
+}
+
+func TestChecksumAlgorithm(t *testing.T) {
+	table := []struct {
+		sum  string
+		want string
+	}{
+		{strings.Repeat("a", 64), "sha256"},
+		{strings.Repeat("a", 128), "sha512"},
+		{strings.Repeat("a", 40), ""},
+		{strings.Repeat("a", 96), ""},
+		{"", ""},
+	}
+	for _, tt := range table {
+		got, e := checksumAlgorithm(tt.sum)
+		if got != tt.want || (e != nil) != (tt.want == "") {
+			t.Errorf("%d hex digits: got: %q, %v, want: %q", len(tt.sum), got, e, tt.want)
+		}
+	}
+}
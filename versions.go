@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/ETCDEVTeam/janus/gitvv"
+)
+
+// listVersions writes all version tags of the line (eg. 3 or 3.5, all if empty) as a table or JSON.
+func listVersions(w io.Writer, dir string, config *gitvv.Config, line, output string) error {
+	tags, e := gitvv.ListVersionTags(dir, config)
+	if e != nil {
+		return e
+	}
+	var filtered []*gitvv.Tag
+	for _, t := range tags {
+		if t.InLine(line) {
+			filtered = append(filtered, t)
+		}
+	}
+
+	switch output {
+	case "json":
+		if filtered == nil {
+			filtered = []*gitvv.Tag{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(filtered)
+	case "table", "":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tTAG\tDATE\tCOMMIT\tTYPE\tSIGNED\tCOMMITS TO NEXT")
+		for _, t := range filtered {
+			kind := "lightweight"
+			if t.Annotated {
+				kind = "annotated"
+			}
+			signed := "no"
+			if t.Signed {
+				signed = "yes"
+			}
+			commit := t.Commit
+			if len(commit) > 7 {
+				commit = commit[:7]
+			}
+			toNext := "-"
+			if t.CommitsToNext >= 0 {
+				toNext = strconv.Itoa(t.CommitsToNext)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				t.Version, t.Name, t.Date.Format("2006-01-02 15:04:05"), commit, kind, signed, toNext)
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown output format '%s', want table or json", output)
+}
@@ -0,0 +1,481 @@
+// Package webdav is a deploy backend for HTTP servers accepting PUT, eg. Artifactory or Nexus "raw" repositories,
+// and WebDAV servers, for destinations like https://repo.example.com/repository/builds/go-ethereum/v3.5.x
+package webdav
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ETCDEVTeam/janus/deploy"
+)
+
+func init() {
+	deploy.Register("https", Open)
+	deploy.Register("http", Open)
+}
+
+// maxRedirects is the number of redirects followed per request.
+const maxRedirects = 10
+
+// Backend is a HTTP server at a base URL, eg. https://repo.example.com
+type Backend struct {
+	client *http.Client
+	base   *url.URL
+	// auth is the Authorization header value, sent to the host of base only.
+	auth  string
+	mkcol bool
+	// mu guards collections.
+	mu sync.Mutex
+	// collections caches the collections known to exist.
+	collections map[string]bool
+}
+
+// Error is an unexpected response status.
+type Error struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Method, e.URL, e.Status)
+}
+
+// Retryable is whether the request may succeed if retried, see deploy.IsRetryable.
+func (e *Error) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// statusIs is whether 'e' is an *Error with one of 'codes'.
+func statusIs(e error, codes ...int) bool {
+	he, ok := e.(*Error)
+	if !ok {
+		return false
+	}
+	for _, c := range codes {
+		if he.StatusCode == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Open opens the HTTP server of 'l', eg. https://repo.example.com/repository/builds
+// Credentials are read from the JSON file 'opts.Key', which may be encrypted, see deploy.DecryptKey,
+// eg. {"username": "...", "password": "..."} for basic auth or {"token": "..."} for a bearer token,
+// else from $JANUS_HTTP_USERNAME and $JANUS_HTTP_PASSWORD, or $JANUS_HTTP_TOKEN. Without any, requests are anonymous.
+// With 'opts.MkCol' missing collections (directories) are created with WebDAV MKCOL.
+func Open(ctx context.Context, l deploy.Location, opts deploy.Options) (deploy.Backend, error) {
+	base, e := url.Parse(l.Scheme + "://" + l.Bucket)
+	if e != nil {
+		return nil, e
+	}
+	auth, e := readAuth(opts.Key, opts.GPG)
+	if e != nil {
+		return nil, e
+	}
+	return &Backend{
+		client: &http.Client{
+			// Redirects are followed by do, keeping the method and body, which http.Client
+			// changes to GET without body for 301 and 302.
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		base:        base,
+		auth:        auth,
+		mkcol:       opts.MkCol,
+		collections: make(map[string]bool),
+	}, nil
+}
+
+// readAuth reads credentials from the JSON file 'key', else from the environment, as an Authorization header value.
+func readAuth(key string, gpg bool) (string, error) {
+	var c struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Token    string `json:"token"`
+	}
+	if key == "" {
+		c.Username = os.Getenv("JANUS_HTTP_USERNAME")
+		c.Password = os.Getenv("JANUS_HTTP_PASSWORD")
+		c.Token = os.Getenv("JANUS_HTTP_TOKEN")
+	} else {
+		p, cleanup, e := deploy.DecryptKey(key, gpg)
+		if e != nil {
+			return "", e
+		}
+		defer cleanup()
+		data, e := ioutil.ReadFile(p)
+		if e != nil {
+			return "", e
+		}
+		if e := json.Unmarshal(data, &c); e != nil {
+			return "", e
+		}
+		if c.Token == "" && c.Username == "" {
+			return "", fmt.Errorf("%s: no username or token", key)
+		}
+	}
+	if c.Token != "" {
+		return "Bearer " + c.Token, nil
+	}
+	if c.Username != "" {
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(c.Username, c.Password)
+		return req.Header.Get("Authorization"), nil
+	}
+	return "", nil
+}
+
+// url gets the URL of an object, or collection if 'name' ends with '/'.
+func (b *Backend) url(name string) string {
+	u := *b.base
+	u.Path = path.Join("/", u.Path, name)
+	if strings.HasSuffix(name, "/") {
+		u.Path += "/"
+	}
+	return u.String()
+}
+
+// do sends a request, following redirects with the same method and body. 'body' may be nil.
+// Responses with other statuses than 'ok' are returned as an *Error, which is retryable
+// for 429 and 5xx statuses, see deploy.WithRetry.
+// The caller must close the body of the response.
+func (b *Backend) do(ctx context.Context, method, u string, header http.Header, body io.ReadSeeker, size int64, ok ...int) (*http.Response, error) {
+	res, e := b.send(ctx, method, u, header, body, size)
+	if e != nil {
+		return nil, e
+	}
+	for _, c := range ok {
+		if res.StatusCode == c {
+			return res, nil
+		}
+	}
+	res.Body.Close()
+	return nil, &Error{Method: method, URL: u, StatusCode: res.StatusCode, Status: res.Status}
+}
+
+// sendsAuth is whether credentials are sent to 'u', which may be redirected to: not to other hosts, eg. a CDN,
+// nor in cleartext to the host of an https:// base.
+func (b *Backend) sendsAuth(u *url.URL) bool {
+	return u.Host == b.base.Host && (u.Scheme == b.base.Scheme || u.Scheme == "https")
+}
+
+// send sends a request, following redirects.
+func (b *Backend) send(ctx context.Context, method, u string, header http.Header, body io.ReadSeeker, size int64) (*http.Response, error) {
+	for redirects := 0; ; redirects++ {
+		var r io.Reader = http.NoBody
+		if body != nil {
+			if _, e := body.Seek(0, io.SeekStart); e != nil {
+				return nil, e
+			}
+			r = ioutil.NopCloser(body)
+		}
+		req, e := http.NewRequest(method, u, r)
+		if e != nil {
+			return nil, e
+		}
+		req = req.WithContext(ctx)
+		if body != nil {
+			req.ContentLength = size
+			if size == 0 {
+				req.Body = http.NoBody
+			}
+		}
+		for k, vs := range header {
+			req.Header[k] = vs
+		}
+		if b.auth != "" && b.sendsAuth(req.URL) {
+			req.Header.Set("Authorization", b.auth)
+		}
+
+		res, e := b.client.Do(req)
+		if e != nil {
+			return nil, e
+		}
+		switch res.StatusCode {
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+			loc, e := res.Location()
+			res.Body.Close()
+			if e != nil {
+				return nil, e
+			}
+			if redirects == maxRedirects {
+				return nil, fmt.Errorf("%s %s: stopped after %d redirects", method, u, maxRedirects)
+			}
+			u = loc.String()
+			continue
+		}
+		return res, nil
+	}
+}
+
+// mkcols creates the collections of 'name' which aren't known to exist, eg. go-ethereum/ and go-ethereum/v3.5.x/
+// Existing collections respond 405 Method Not Allowed.
+func (b *Backend) mkcols(ctx context.Context, name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	dir := ""
+	for _, p := range strings.Split(path.Dir(name), "/") {
+		if p == "." || p == "" {
+			continue
+		}
+		dir += p + "/"
+		if b.collections[dir] {
+			continue
+		}
+		res, e := b.do(ctx, "MKCOL", b.url(dir), nil, nil, 0, http.StatusCreated, http.StatusMethodNotAllowed)
+		if e != nil {
+			return e
+		}
+		res.Body.Close()
+		b.collections[dir] = true
+	}
+	return nil
+}
+
+// checksums gets the X-Checksum-* headers of Artifactory and Nexus of the content of 'r'.
+func checksums(r io.ReadSeeker) (http.Header, error) {
+	s256, s1, m5 := sha256.New(), sha1.New(), md5.New()
+	if _, e := r.Seek(0, io.SeekStart); e != nil {
+		return nil, e
+	}
+	if _, e := io.Copy(io.MultiWriter(s256, s1, m5), r); e != nil {
+		return nil, e
+	}
+	return http.Header{
+		"X-Checksum-Sha256": {hex.EncodeToString(s256.Sum(nil))},
+		"X-Checksum-Sha1":   {hex.EncodeToString(s1.Sum(nil))},
+		"X-Checksum-Md5":    {hex.EncodeToString(m5.Sum(nil))},
+	}, nil
+}
+
+// Put uploads the content of 'r' to 'name' with a PUT request, creating its collections first if enabled.
+func (b *Backend) Put(ctx context.Context, name string, r io.ReadSeeker, opts deploy.PutOptions) (*deploy.Object, error) {
+	if opts.IfGenerationMatch != 0 {
+		return nil, errors.New("http(s):// has no generations of objects to match")
+	}
+	size, e := r.Seek(0, io.SeekEnd)
+	if e != nil {
+		return nil, e
+	}
+	header, e := checksums(r)
+	if e != nil {
+		return nil, e
+	}
+	if opts.NoClobber {
+		// Conditional PUT, see RFC 7232. Servers which ignore it, eg. Artifactory and Nexus "raw" repositories,
+		// are checked for the object first, which an upload at the same time may still race.
+		header.Set("If-None-Match", "*")
+		if _, e := b.Stat(ctx, name); e == nil {
+			return nil, deploy.ErrExist
+		} else if e != deploy.ErrNotExist {
+			return nil, e
+		}
+	}
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(name))
+	}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	if b.mkcol {
+		if e := b.mkcols(ctx, name); e != nil {
+			return nil, e
+		}
+	}
+	res, e := b.do(ctx, http.MethodPut, b.url(name), header, r, size, http.StatusOK, http.StatusCreated, http.StatusNoContent)
+	if opts.NoClobber && statusIs(e, http.StatusPreconditionFailed) {
+		return nil, deploy.ErrExist
+	}
+	if e != nil {
+		return nil, e
+	}
+	res.Body.Close()
+	return &deploy.Object{Name: name, Size: size, ContentType: contentType, Updated: time.Now().UTC()}, nil
+}
+
+// Get gets the content of 'name'.
+func (b *Backend) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	res, e := b.do(ctx, http.MethodGet, b.url(name), nil, nil, 0, http.StatusOK)
+	if statusIs(e, http.StatusNotFound, http.StatusGone) {
+		return nil, deploy.ErrNotExist
+	}
+	if e != nil {
+		return nil, e
+	}
+	return res.Body, nil
+}
+
+// Stat gets the metadata of 'name' with a HEAD request.
+func (b *Backend) Stat(ctx context.Context, name string) (*deploy.Object, error) {
+	res, e := b.do(ctx, http.MethodHead, b.url(name), nil, nil, 0, http.StatusOK)
+	if statusIs(e, http.StatusNotFound, http.StatusGone) {
+		return nil, deploy.ErrNotExist
+	}
+	if e != nil {
+		return nil, e
+	}
+	res.Body.Close()
+	updated, _ := http.ParseTime(res.Header.Get("Last-Modified"))
+	return &deploy.Object{
+		Name:        name,
+		Size:        res.ContentLength,
+		ContentType: res.Header.Get("Content-Type"),
+		Updated:     updated,
+	}, nil
+}
+
+// multistatus is the response of PROPFIND.
+type multistatus struct {
+	Responses []struct {
+		Href string `xml:"href"`
+		Prop struct {
+			ContentLength int64  `xml:"getcontentlength"`
+			ContentType   string `xml:"getcontenttype"`
+			LastModified  string `xml:"getlastmodified"`
+			ResourceType  struct {
+				Collection *struct{} `xml:"collection"`
+			} `xml:"resourcetype"`
+		} `xml:"propstat>prop"`
+	} `xml:"response"`
+}
+
+const propfind = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:getcontentlength/><D:getcontenttype/><D:getlastmodified/><D:resourcetype/></D:prop></D:propfind>`
+
+// List lists the objects with names starting with 'prefix', with WebDAV PROPFIND requests
+// walking the collections from that of 'prefix'.
+func (b *Backend) List(ctx context.Context, prefix string) ([]*deploy.Object, error) {
+	dir := ""
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = prefix[:i+1]
+	}
+	var objs []*deploy.Object
+	e := b.walk(ctx, dir, func(o *deploy.Object) {
+		if strings.HasPrefix(o.Name, prefix) {
+			objs = append(objs, o)
+		}
+	})
+	if statusIs(e, http.StatusNotFound) {
+		return nil, nil
+	}
+	return objs, e
+}
+
+// walk calls 'fn' for the objects in collection 'dir' and its sub-collections.
+func (b *Backend) walk(ctx context.Context, dir string, fn func(*deploy.Object)) error {
+	body := strings.NewReader(propfind)
+	header := http.Header{"Depth": {"1"}, "Content-Type": {"application/xml"}}
+	res, e := b.do(ctx, "PROPFIND", b.url(dir+"/"), header, body, body.Size(), http.StatusMultiStatus)
+	if e != nil {
+		return e
+	}
+	var ms multistatus
+	e = xml.NewDecoder(res.Body).Decode(&ms)
+	res.Body.Close()
+	if e != nil {
+		return e
+	}
+	basePath := path.Join("/", b.base.Path)
+	for _, r := range ms.Responses {
+		href, e := url.Parse(r.Href)
+		if e != nil {
+			return e
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(href.Path, basePath), "/")
+		if strings.TrimSuffix(name, "/") == strings.TrimSuffix(dir, "/") {
+			// The collection itself.
+			continue
+		}
+		if r.Prop.ResourceType.Collection != nil {
+			if e := b.walk(ctx, strings.TrimSuffix(name, "/")+"/", fn); e != nil {
+				return e
+			}
+			continue
+		}
+		updated, _ := http.ParseTime(r.Prop.LastModified)
+		fn(&deploy.Object{Name: name, Size: r.Prop.ContentLength, ContentType: r.Prop.ContentType, Updated: updated})
+	}
+	return nil
+}
+
+// Copy copies 'src' to 'dst' with WebDAV COPY, else by downloading and uploading it
+// if the server doesn't support COPY.
+func (b *Backend) Copy(ctx context.Context, src, dst string) (*deploy.Object, error) {
+	if b.mkcol {
+		if e := b.mkcols(ctx, dst); e != nil {
+			return nil, e
+		}
+	}
+	header := http.Header{"Destination": {b.url(dst)}, "Overwrite": {"T"}}
+	res, e := b.do(ctx, "COPY", b.url(src), header, nil, 0, http.StatusCreated, http.StatusNoContent)
+	if e == nil {
+		res.Body.Close()
+		return b.Stat(ctx, dst)
+	}
+	if statusIs(e, http.StatusNotFound) {
+		return nil, deploy.ErrNotExist
+	}
+	if !statusIs(e, http.StatusMethodNotAllowed, http.StatusNotImplemented, http.StatusBadRequest) {
+		return nil, e
+	}
+
+	res, e = b.do(ctx, http.MethodGet, b.url(src), nil, nil, 0, http.StatusOK)
+	if statusIs(e, http.StatusNotFound, http.StatusGone) {
+		return nil, deploy.ErrNotExist
+	}
+	if e != nil {
+		return nil, e
+	}
+	defer res.Body.Close()
+	f, e := ioutil.TempFile("", "janus-copy-")
+	if e != nil {
+		return nil, e
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, e := io.Copy(f, res.Body); e != nil {
+		return nil, e
+	}
+	return b.Put(ctx, dst, f, deploy.PutOptions{ContentType: res.Header.Get("Content-Type")})
+}
+
+// Delete deletes 'name'.
+func (b *Backend) Delete(ctx context.Context, name string) error {
+	res, e := b.do(ctx, http.MethodDelete, b.url(name), nil, nil, 0, http.StatusOK, http.StatusAccepted, http.StatusNoContent)
+	if statusIs(e, http.StatusNotFound, http.StatusGone) {
+		return deploy.ErrNotExist
+	}
+	if e != nil {
+		return e
+	}
+	res.Body.Close()
+	return nil
+}
+
+// Close closes idle connections.
+func (b *Backend) Close() error {
+	b.client.CloseIdleConnections()
+	return nil
+}
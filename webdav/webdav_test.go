@@ -0,0 +1,273 @@
+package webdav
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ETCDEVTeam/janus/deploy"
+)
+
+// fakeDAV is an in-process WebDAV server requiring basic auth and existing collections for PUT.
+// Requests to /old/... are redirected to /new/..., and the first PUT of each file fails with 503.
+type fakeDAV struct {
+	t           *testing.T
+	mu          sync.Mutex
+	files       map[string][]byte
+	collections map[string]bool
+	failed      map[string]bool
+	puts        int
+	// ignoreIfNoneMatch replaces files despite If-None-Match, like Artifactory and Nexus "raw" repositories.
+	ignoreIfNoneMatch bool
+}
+
+func newFakeDAV(t *testing.T) *fakeDAV {
+	return &fakeDAV{
+		t:           t,
+		files:       make(map[string][]byte),
+		collections: map[string]bool{"/": true},
+		failed:      make(map[string]bool),
+	}
+}
+
+func (f *fakeDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if strings.HasPrefix(r.URL.Path, "/old/") {
+		http.Redirect(w, r, "/new/"+strings.TrimPrefix(r.URL.Path, "/old/"), http.StatusMovedPermanently)
+		return
+	}
+	if u, p, ok := r.BasicAuth(); !ok || u != "ci" || p != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	p := r.URL.Path
+	dir := path.Dir(strings.TrimSuffix(p, "/")) + "/"
+	if dir == "//" {
+		dir = "/"
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+
+	switch r.Method {
+	case "MKCOL":
+		if f.collections[p] {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !f.collections[dir] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.collections[p] = true
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodPut:
+		if !f.failed[p] {
+			f.failed[p] = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if !f.collections[dir] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if _, ok := f.files[p]; ok && r.Header.Get("If-None-Match") == "*" && !f.ignoreIfNoneMatch {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		h := sha256.Sum256(body)
+		if got := r.Header.Get("X-Checksum-Sha256"); got != hex.EncodeToString(h[:]) {
+			f.t.Errorf("%s: X-Checksum-Sha256: got: %s, want: %s", p, got, hex.EncodeToString(h[:]))
+		}
+		f.files[p] = body
+		f.puts++
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodHead, http.MethodGet:
+		data, ok := f.files[p]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Last-Modified", time.Unix(0, 0).UTC().Format(http.TimeFormat))
+		w.Write(data)
+	case http.MethodDelete:
+		if _, ok := f.files[p]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.files, p)
+		w.WriteHeader(http.StatusNoContent)
+	case "COPY":
+		data, ok := f.files[p]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		dst, e := url.Parse(r.Header.Get("Destination"))
+		if e != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.files[dst.Path] = data
+		w.WriteHeader(http.StatusCreated)
+	case "PROPFIND":
+		if r.Header.Get("Depth") != "1" || !f.collections[p] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var names []string
+		for name := range f.collections {
+			if name != "/" && path.Dir(strings.TrimSuffix(name, "/"))+"/" == p || name == p {
+				names = append(names, name)
+			}
+		}
+		for name := range f.files {
+			if path.Dir(name)+"/" == p {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><D:multistatus xmlns:D="DAV:">`)
+		for _, name := range names {
+			if f.collections[name] {
+				fmt.Fprintf(w, `<D:response><D:href>%s</D:href><D:propstat><D:prop><D:resourcetype><D:collection/></D:resourcetype></D:prop></D:propstat></D:response>`, name)
+				continue
+			}
+			fmt.Fprintf(w, `<D:response><D:href>%s</D:href><D:propstat><D:prop><D:getcontentlength>%d</D:getcontentlength><D:resourcetype/></D:prop></D:propstat></D:response>`, name, len(f.files[name]))
+		}
+		fmt.Fprint(w, `</D:multistatus>`)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestBackend(t *testing.T) {
+	f := newFakeDAV(t)
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	for _, env := range []string{"JANUS_HTTP_USERNAME", "JANUS_HTTP_PASSWORD", "JANUS_HTTP_TOKEN"} {
+		defer os.Setenv(env, os.Getenv(env))
+		os.Unsetenv(env)
+	}
+	os.Setenv("JANUS_HTTP_USERNAME", "ci")
+	os.Setenv("JANUS_HTTP_PASSWORD", "secret")
+
+	l, e := deploy.ParseLocation(srv.URL + "/builds")
+	if e != nil {
+		t.Fatal(e)
+	}
+	b, e := Open(context.Background(), l, deploy.Options{MkCol: true})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer b.Close()
+	b = deploy.WithRetry(b, deploy.Retry{Retries: 2, Initial: time.Millisecond, Max: time.Millisecond})
+	ctx := context.Background()
+
+	// Retried after 503, with collections created.
+	o, e := b.Put(ctx, "new/go-ethereum/v3.5.x/geth.zip", strings.NewReader("geth"), deploy.PutOptions{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if o.Size != 4 || string(f.files["/new/go-ethereum/v3.5.x/geth.zip"]) != "geth" {
+		t.Errorf("got: %+v, %v", o, f.files)
+	}
+	if _, e := b.Put(ctx, "new/go-ethereum/v3.5.x/geth.zip", strings.NewReader("geth2"), deploy.PutOptions{NoClobber: true}); e != deploy.ErrExist {
+		t.Errorf("got: %v, want: %v", e, deploy.ErrExist)
+	}
+	// Also of servers ignoring If-None-Match.
+	f.ignoreIfNoneMatch = true
+	if _, e := b.Put(ctx, "new/go-ethereum/v3.5.x/geth.zip", strings.NewReader("geth2"), deploy.PutOptions{NoClobber: true}); e != deploy.ErrExist {
+		t.Errorf("got: %v, want: %v", e, deploy.ErrExist)
+	}
+	f.ignoreIfNoneMatch = false
+	if string(f.files["/new/go-ethereum/v3.5.x/geth.zip"]) != "geth" {
+		t.Errorf("got: %q", f.files["/new/go-ethereum/v3.5.x/geth.zip"])
+	}
+	// Redirected from /old/, keeping the method and body.
+	if _, e := b.Put(ctx, "old/go-ethereum/v3.5.x/geth-osx.zip", strings.NewReader("osx"), deploy.PutOptions{}); e != nil {
+		t.Fatal(e)
+	}
+	if string(f.files["/new/go-ethereum/v3.5.x/geth-osx.zip"]) != "osx" {
+		t.Errorf("got: %v", f.files)
+	}
+
+	o, e = b.Stat(ctx, "new/go-ethereum/v3.5.x/geth.zip")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if o.Size != 4 {
+		t.Errorf("got: %+v", o)
+	}
+	if _, e := b.Stat(ctx, "new/nope.zip"); e != deploy.ErrNotExist {
+		t.Errorf("got: %v, want: %v", e, deploy.ErrNotExist)
+	}
+
+	if _, e := b.Copy(ctx, "new/go-ethereum/v3.5.x/geth.zip", "new/go-ethereum/latest/geth.zip"); e != nil {
+		t.Fatal(e)
+	}
+	objs, e := b.List(ctx, "new/go-ethereum/")
+	if e != nil {
+		t.Fatal(e)
+	}
+	var names []string
+	for _, o := range objs {
+		names = append(names, fmt.Sprintf("%s:%d", o.Name, o.Size))
+	}
+	want := "new/go-ethereum/latest/geth.zip:4 new/go-ethereum/v3.5.x/geth-osx.zip:3 new/go-ethereum/v3.5.x/geth.zip:4"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("got: %s, want: %s", got, want)
+	}
+
+	if e := b.Delete(ctx, "new/go-ethereum/latest/geth.zip"); e != nil {
+		t.Fatal(e)
+	}
+	if e := b.Delete(ctx, "new/go-ethereum/latest/geth.zip"); e != deploy.ErrNotExist {
+		t.Errorf("got: %v, want: %v", e, deploy.ErrNotExist)
+	}
+
+	os.Setenv("JANUS_HTTP_PASSWORD", "wrong")
+	b, e = Open(context.Background(), l, deploy.Options{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	_, e = b.Put(ctx, "new/geth.zip", strings.NewReader("geth"), deploy.PutOptions{})
+	if !statusIs(e, http.StatusUnauthorized) {
+		t.Errorf("got: %v, want 401", e)
+	}
+}
+
+func TestBackend_sendsAuth(t *testing.T) {
+	table := []struct {
+		base, u string
+		want    bool
+	}{
+		{"https://repo.example.com/builds", "https://repo.example.com/new/geth.zip", true},
+		{"https://repo.example.com/builds", "http://repo.example.com/new/geth.zip", false},
+		{"https://repo.example.com/builds", "https://cdn.example.com/geth.zip", false},
+		{"http://repo.example.com/builds", "http://repo.example.com/new/geth.zip", true},
+		{"http://repo.example.com/builds", "https://repo.example.com/new/geth.zip", true},
+	}
+	for _, tt := range table {
+		base, _ := url.Parse(tt.base)
+		u, _ := url.Parse(tt.u)
+		b := &Backend{base: base}
+		if got := b.sendsAuth(u); got != tt.want {
+			t.Errorf("%s -> %s: got: %v, want: %v", tt.base, tt.u, got, tt.want)
+		}
+	}
+}